@@ -0,0 +1,169 @@
+// Package argo is the metric backend counting Argo workflows by phase
+package argo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+// ScalerType is the scalerType metadata value of the argo backend
+const ScalerType = "argo-workflows"
+
+const (
+	metricName = "ArgoWorkflowCount"
+
+	// phaseLabel is set by the workflow controller once it picked up a
+	// workflow, workflows without it are not processed yet
+	phaseLabel   = "workflows.argoproj.io/phase"
+	phasePending = "Pending"
+
+	// pageSize keeps the pages of full workflow objects small
+	pageSize = 50
+)
+
+var knownPhases = map[string]bool{
+	phasePending: true,
+	"Running":    true,
+	"Succeeded":  true,
+	"Failed":     true,
+	"Error":      true,
+}
+
+// argoBackend counts the Argo workflows in the namespace of the ScaledObject
+// whose phase is one of phases, Pending and Running by default, and which
+// match labelSelector. Workflows the controller has not picked up yet count
+// as Pending.
+type argoBackend struct {
+	namespace     string
+	labelSelector string
+	phases        []string
+	kube          backends.KubeClient
+}
+
+type workflowList struct {
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+	Items []struct{} `json:"items"`
+}
+
+// NewBackendFactory returns the factory of argo backends listing workflows
+// with the client of kube
+func NewBackendFactory(kube backends.KubeClientSource) backends.BackendFactory {
+	return func(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+		backend := argoBackend{
+			labelSelector: metadata["labelSelector"],
+			phases:        []string{phasePending, "Running"},
+		}
+
+		var err error
+		backend.namespace, err = backends.Namespace(ref, metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		if val, ok := metadata["phases"]; ok && val != "" {
+			backend.phases = backends.SplitItems(val)
+		}
+
+		for _, phase := range backend.phases {
+			if !knownPhases[phase] {
+				return nil, fmt.Errorf("unknown workflow phase %s", phase)
+			}
+		}
+
+		if strings.Contains(backend.labelSelector, phaseLabel) {
+			return nil, fmt.Errorf("labelSelector must not select %s, use phases", phaseLabel)
+		}
+
+		backend.kube, err = kube()
+		if err != nil {
+			return nil, err
+		}
+
+		return &backend, nil
+	}
+}
+
+// Endpoint returns the namespace of the workflows
+func (a *argoBackend) Endpoint() string {
+	return "argo-workflows/" + a.namespace
+}
+
+// MetricName returns the name of the workflow count metric
+func (a *argoBackend) MetricName() string {
+	return metricName
+}
+
+// GetMetricValue counts the matching workflows
+func (a *argoBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	count, err := a.count(ctx, fmt.Sprintf("%s in (%s)", phaseLabel, strings.Join(a.phases, ",")))
+	if err != nil {
+		return -1, err
+	}
+
+	if a.countsPending() {
+		unprocessed, err := a.count(ctx, "!"+phaseLabel)
+		if err != nil {
+			return -1, err
+		}
+		count += unprocessed
+	}
+
+	return count, nil
+}
+
+func (a *argoBackend) countsPending() bool {
+	for _, phase := range a.phases {
+		if phase == phasePending {
+			return true
+		}
+	}
+	return false
+}
+
+// count lists the workflows matching the phase selector page by page
+func (a *argoBackend) count(ctx context.Context, phaseSelector string) (int64, error) {
+	selector := phaseSelector
+	if a.labelSelector != "" {
+		selector = a.labelSelector + "," + phaseSelector
+	}
+
+	var count int64
+	next := ""
+	for {
+		query := url.Values{
+			"labelSelector": {selector},
+			"limit":         {strconv.Itoa(pageSize)},
+		}
+		if next != "" {
+			query.Set("continue", next)
+		}
+
+		var list workflowList
+		path := fmt.Sprintf("/apis/argoproj.io/v1alpha1/namespaces/%s/workflows?%s", url.PathEscape(a.namespace), query.Encode())
+		if err := a.kube.Do(ctx, http.MethodGet, path, nil, &list); err != nil {
+			return -1, err
+		}
+
+		count += int64(len(list.Items))
+
+		next = list.Metadata.Continue
+		if next == "" {
+			return count, nil
+		}
+	}
+}
+
+// Close is a no-op as the kubernetes client is shared
+func (a *argoBackend) Close() error {
+	return nil
+}
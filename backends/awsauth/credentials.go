@@ -0,0 +1,167 @@
+// Package awsauth loads AWS credentials and signs requests with SigV4 for the
+// backends talking to AWS services
+package awsauth
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Assumed role credentials are renewed this long before they expire
+	credentialsRenewal = 5 * time.Minute
+	roleSessionName    = "keda-external-scaler"
+	timeout            = 10 * time.Second
+	maxBodyBytes       = 64 * 1024
+)
+
+// Credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN, or obtained for AWS_ROLE_ARN with the web identity token
+// in AWS_WEB_IDENTITY_TOKEN_FILE, as set up by IAM roles for service
+// accounts
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+}
+
+// Provider caches the credentials and renews assumed role credentials
+// before they expire
+type Provider struct {
+	region string
+	client *http.Client
+
+	mu    sync.Mutex
+	creds *Credentials
+}
+
+// NewProvider creates a provider assuming roles with the STS endpoint of
+// region
+func NewProvider(region string) *Provider {
+	return &Provider{
+		region: region,
+		client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return fmt.Errorf("sts redirects are not followed")
+			},
+		},
+	}
+}
+
+// regionPattern matches region names such as us-east-1 or us-gov-west-1
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d$`)
+
+// Region returns region, or AWS_REGION when it is empty
+func Region(region string) string {
+	if region == "" {
+		return os.Getenv("AWS_REGION")
+	}
+	return region
+}
+
+// ValidateRegion rejects anything but a region name, as regions end up in
+// host names and signatures
+func ValidateRegion(region string) error {
+	if !regionPattern.MatchString(region) {
+		return fmt.Errorf("invalid AWS region %q", region)
+	}
+	return nil
+}
+
+// Get returns the cached credentials, loading them when there are none or
+// they are about to expire
+func (p *Provider) Get() (*Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.creds == nil || (!p.creds.Expiration.IsZero() && time.Until(p.creds.Expiration) < credentialsRenewal) {
+		creds, err := p.load()
+		if err != nil {
+			return nil, fmt.Errorf("AWS credentials not available %s", err.Error())
+		}
+		p.creds = creds
+	}
+
+	return p.creds, nil
+}
+
+func (p *Provider) load() (*Credentials, error) {
+	if accessKeyID := os.Getenv("AWS_ACCESS_KEY_ID"); accessKeyID != "" {
+		return &Credentials{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	roleARN, tokenFile := os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	if roleARN == "" || tokenFile == "" {
+		return nil, fmt.Errorf("neither AWS_ACCESS_KEY_ID nor AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE are set")
+	}
+
+	return p.assumeRoleWithWebIdentity(roleARN, tokenFile)
+}
+
+type assumeRoleWithWebIdentityResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+// assumeRoleWithWebIdentity exchanges the service account token for
+// temporary credentials of the role, which needs no signed request
+func (p *Provider) assumeRoleWithWebIdentity(roleARN string, tokenFile string) (*Credentials, error) {
+	webIdentityToken, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {roleSessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(webIdentityToken))},
+	}
+
+	resp, err := p.client.Get(fmt.Sprintf("https://sts.%s.amazonaws.com/?%s", p.region, query.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sts returned status %d", resp.StatusCode)
+	}
+
+	var result assumeRoleWithWebIdentityResponse
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("STS response parsing error %s", err.Error())
+	}
+
+	return &Credentials{
+		AccessKeyID:     result.Credentials.AccessKeyID,
+		SecretAccessKey: result.Credentials.SecretAccessKey,
+		SessionToken:    result.Credentials.SessionToken,
+		Expiration:      result.Credentials.Expiration,
+	}, nil
+}
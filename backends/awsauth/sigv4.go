@@ -0,0 +1,119 @@
+package awsauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SigningAlgorithm is the X-Amz-Algorithm of SigV4 signatures
+const SigningAlgorithm = "AWS4-HMAC-SHA256"
+
+// Scope returns the credential scope of a signature made at now
+func Scope(now time.Time, region string, service string) string {
+	return strings.Join([]string{now.UTC().Format("20060102"), region, service, "aws4_request"}, "/")
+}
+
+// Signature signs the canonical request made at now with the secret key
+// derived for region and service
+func Signature(creds *Credentials, canonicalRequest string, now time.Time, region string, service string) string {
+	now = now.UTC()
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		SigningAlgorithm,
+		now.Format("20060102T150405Z"),
+		Scope(now, region, service),
+		hex.EncodeToString(hashedRequest[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{now.Format("20060102"), region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// SignRequest adds the SigV4 Authorization header for body to req. Only the
+// host, X-Amz-Date and X-Amz-Security-Token headers are signed.
+func SignRequest(req *http.Request, body []byte, creds *Credentials, region string, service string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{
+		"host":       req.URL.Host,
+		"x-amz-date": req.Header.Get("X-Amz-Date"),
+	}
+	if creds.SessionToken != "" {
+		headers["x-amz-security-token"] = creds.SessionToken
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	payload := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+
+	req.Header.Set("Authorization", SigningAlgorithm+
+		" Credential="+creds.AccessKeyID+"/"+Scope(now, region, service)+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+Signature(creds, canonicalRequest, now, region, service))
+}
+
+// CanonicalQuery encodes the parameters sorted by name, as SigV4 requires
+func CanonicalQuery(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), params[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, URIEncode(key)+"="+URIEncode(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// URIEncode escapes everything but unreserved characters, as SigV4 requires
+func URIEncode(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,105 @@
+package awsauth
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// Vectors of the AWS Signature Version 4 test suite, whose requests only
+// sign the headers SignRequest signs
+var (
+	suiteCredentials = &Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	suiteTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+)
+
+const suiteSessionToken = "AQoDYXdzEPT//////////wEXAMPLEtc764bNrC9SAPBSM22wDOk4x4HIZ8j4FZTwdQWLWsKWHGBuFqwAeMicRXmxfpSPfIeoIYRqTflfKD8YUuwthAx7mSEI/qkPpKPi/kMcGdQrmGdeehM4IC1NtBmUpp2wUE8phUZampKsburEDy0KPkyQDYwT7WZ0wq5VSXDvp75YU9HFvlRd8Tx6q6fE8YQcHNVXAkiY9q6d+xo0rKwT38xVqr7ZD0u0iPPkUL64lIZbqBAz+scqKmlzm8FDrypNC9Yjc8fPOLn9FX9KSYvKTr4rvx3iSIlTJabIQwj2ICCR/oLxBA=="
+
+func TestSignRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		sessionToken  string
+		authorization string
+	}{
+		{
+			"get-vanilla", "GET", "https://example.amazonaws.com/", "",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			"get-vanilla-empty-query-key", "GET", "https://example.amazonaws.com/?Param1=value1", "",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=a67d582fa61cc504c4bae71f336f98b97f1ea3c7a6bfe1b6e45aec72011b9aeb",
+		},
+		{
+			"get-vanilla-query-order-key-case", "GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			"post-vanilla", "POST", "https://example.amazonaws.com/", "",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+		{
+			"post-vanilla-query", "POST", "https://example.amazonaws.com/?Param1=value1", "",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=28038455d6de14eafc1f9222cf5aa6f1a96197d7deb8263271d420d138af7f11",
+		},
+		{
+			"post-sts-header-before", "POST", "https://example.amazonaws.com/", suiteSessionToken,
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date;x-amz-security-token, Signature=85d96828115b5dc0cfc3bd16ad9e210dd772bbebba041836c64533a82be05ead",
+		},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		creds := *suiteCredentials
+		creds.SessionToken = test.sessionToken
+		SignRequest(req, nil, &creds, "us-east-1", "service", suiteTime)
+
+		if got := req.Header.Get("Authorization"); got != test.authorization {
+			t.Errorf("%s: got Authorization %q, want %q", test.name, got, test.authorization)
+		}
+		if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+			t.Errorf("%s: got X-Amz-Date %q", test.name, got)
+		}
+		if got := req.Header.Get("X-Amz-Security-Token"); got != test.sessionToken {
+			t.Errorf("%s: got X-Amz-Security-Token %q", test.name, got)
+		}
+	}
+}
+
+func TestSignature(t *testing.T) {
+	// Canonical request of get-vanilla
+	canonicalRequest := "GET\n/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	got := Signature(suiteCredentials, canonicalRequest, suiteTime, "us-east-1", "service")
+	if want := "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"; got != want {
+		t.Errorf("got signature %s, want %s", got, want)
+	}
+
+	if got := Scope(suiteTime, "us-east-1", "service"); got != "20150830/us-east-1/service/aws4_request" {
+		t.Errorf("got scope %s", got)
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	params := url.Values{
+		"Param2":  {"value2"},
+		"Param1":  {"value2", "value1"},
+		"a b":     {"c/d~e"},
+		"Action":  {"GetMetricData"},
+		"Version": {""},
+	}
+
+	want := "Action=GetMetricData&Param1=value1&Param1=value2&Param2=value2&Version=&a%20b=c%2Fd~e"
+	if got := CanonicalQuery(params); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
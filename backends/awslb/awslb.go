@@ -0,0 +1,278 @@
+// Package awslb is the metric backend reading the active connection count of
+// an AWS application or network load balancer from CloudWatch, for services
+// holding long-lived connections whose load request rates do not show
+package awslb
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/backends/awsauth"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	// ScalerType is the scalerType metadata value of the awslb backend
+	ScalerType = "aws-lb-connections"
+)
+
+const (
+	connectionsMetricName = "LoadBalancerActiveConnections"
+
+	serviceName   = "monitoring"
+	apiVersion    = "2010-08-01"
+	defaultPeriod = 60
+	// CloudWatch publishes load balancer datapoints with a delay, the latest
+	// datapoint of a few periods is used
+	lookbackPeriods = 5
+
+	defaultTimeout = 10 * time.Second
+	maxBodyBytes   = 1024 * 1024
+)
+
+// loadBalancerMetrics are the CloudWatch namespace, metric and default
+// statistic of a load balancer type, keyed by the prefix of its dimension
+var loadBalancerMetrics = map[string]struct {
+	namespace string
+	metric    string
+	statistic string
+}{
+	"app": {"AWS/ApplicationELB", "ActiveConnectionCount", "Sum"},
+	"net": {"AWS/NetworkELB", "ActiveFlowCount", "Average"},
+}
+
+var statistics = map[string]bool{
+	"Sum":     true,
+	"Average": true,
+	"Maximum": true,
+	"Minimum": true,
+}
+
+// awsLBBackend reports the latest datapoint of the active connections of
+// loadBalancer, such as app/my-alb/50dc6c495c0c9188, optionally limited to
+// targetGroup. Application load balancers report ActiveConnectionCount,
+// network load balancers ActiveFlowCount. Periods without datapoints count
+// zero connections, fractional statistics are rounded with roundingMode.
+type awsLBBackend struct {
+	url        string
+	region     string
+	namespace  string
+	metric     string
+	statistic  string
+	period     int
+	dimensions [][2]string
+	rounding   backends.RoundingMode
+	provider   *awsauth.Provider
+	client     *http.Client
+}
+
+type getMetricStatisticsResponse struct {
+	Datapoints []struct {
+		Timestamp time.Time `xml:"Timestamp"`
+		Sum       float64   `xml:"Sum"`
+		Average   float64   `xml:"Average"`
+		Maximum   float64   `xml:"Maximum"`
+		Minimum   float64   `xml:"Minimum"`
+	} `xml:"GetMetricStatisticsResult>Datapoints>member"`
+}
+
+type errorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// NewBackend creates an awslb backend from the trigger metadata
+func NewBackend(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	loadBalancer := metadata["loadBalancer"]
+	if loadBalancer == "" {
+		return nil, fmt.Errorf("no loadBalancer given")
+	}
+
+	lbType := strings.SplitN(loadBalancer, "/", 2)[0]
+	lbMetrics, ok := loadBalancerMetrics[lbType]
+	if !ok {
+		return nil, fmt.Errorf("loadBalancer must start with app/ or net/")
+	}
+
+	backend := awsLBBackend{
+		region:     awsauth.Region(metadata["awsRegion"]),
+		namespace:  lbMetrics.namespace,
+		metric:     lbMetrics.metric,
+		statistic:  lbMetrics.statistic,
+		period:     defaultPeriod,
+		dimensions: [][2]string{{"LoadBalancer", loadBalancer}},
+	}
+
+	if backend.region == "" {
+		return nil, fmt.Errorf("no awsRegion given")
+	}
+
+	if val, ok := metadata["targetGroup"]; ok && val != "" {
+		if !strings.HasPrefix(val, "targetgroup/") {
+			return nil, fmt.Errorf("targetGroup must start with targetgroup/")
+		}
+		backend.dimensions = append(backend.dimensions, [2]string{"TargetGroup", val})
+	}
+
+	if val, ok := metadata["statistic"]; ok && val != "" {
+		if !statistics[val] {
+			return nil, fmt.Errorf("statistic must be Sum, Average, Maximum or Minimum")
+		}
+		backend.statistic = val
+	}
+
+	if val, ok := metadata["period"]; ok && val != "" {
+		period, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Period parsing error %s", err.Error())
+		}
+
+		// Load balancer metrics have a resolution of one minute
+		if period <= 0 || period%60 != 0 {
+			return nil, fmt.Errorf("period must be a positive multiple of 60")
+		}
+		backend.period = period
+	}
+
+	var err error
+	backend.rounding, err = backends.ParseRoundingMode(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.url = fmt.Sprintf("https://%s.%s.amazonaws.com/", serviceName, backend.region)
+	parsed, err := url.Parse(backend.url)
+	if err != nil {
+		return nil, fmt.Errorf("URL parsing error %s", err.Error())
+	}
+
+	if err := cfg.AllowEgressURL(parsed); err != nil {
+		return nil, err
+	}
+
+	backend.provider = awsauth.NewProvider(backend.region)
+	if _, err := backend.provider.Get(); err != nil {
+		return nil, err
+	}
+
+	backend.client = &http.Client{
+		Timeout: defaultTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return fmt.Errorf("cloudwatch redirects are not followed")
+		},
+	}
+
+	return &backend, nil
+}
+
+// Endpoint returns the CloudWatch endpoint of the region
+func (a *awsLBBackend) Endpoint() string {
+	return serviceName + "." + a.region + ".amazonaws.com"
+}
+
+// MetricName returns the name of the connections metric
+func (a *awsLBBackend) MetricName() string {
+	return connectionsMetricName
+}
+
+// GetMetricValue reads the statistic of the last periods and returns the
+// latest datapoint
+func (a *awsLBBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	creds, err := a.provider.Get()
+	if err != nil {
+		return -1, err
+	}
+
+	now := time.Now().UTC()
+	period := time.Duration(a.period) * time.Second
+
+	params := url.Values{
+		"Action":              {"GetMetricStatistics"},
+		"Version":             {apiVersion},
+		"Namespace":           {a.namespace},
+		"MetricName":          {a.metric},
+		"Period":              {strconv.Itoa(a.period)},
+		"Statistics.member.1": {a.statistic},
+		"StartTime":           {now.Add(-lookbackPeriods * period).Format(time.RFC3339)},
+		"EndTime":             {now.Format(time.RFC3339)},
+	}
+	for i, dimension := range a.dimensions {
+		params.Set(fmt.Sprintf("Dimensions.member.%d.Name", i+1), dimension[0])
+		params.Set(fmt.Sprintf("Dimensions.member.%d.Value", i+1), dimension[1])
+	}
+	body := []byte(params.Encode())
+
+	req, err := http.NewRequest(http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	awsauth.SignRequest(req, body, creds, a.region, serviceName, now)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	if err != nil {
+		return -1, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var result errorResponse
+		if err := xml.Unmarshal(respBody, &result); err == nil && result.Code != "" {
+			return -1, fmt.Errorf("cloudwatch returned %s %s", result.Code, result.Message)
+		}
+		return -1, fmt.Errorf("cloudwatch returned status %d", resp.StatusCode)
+	}
+
+	var result getMetricStatisticsResponse
+	if err := xml.Unmarshal(respBody, &result); err != nil {
+		return -1, fmt.Errorf("CloudWatch response parsing error %s", err.Error())
+	}
+
+	// Load balancers publish no datapoints while there are no connections
+	if len(result.Datapoints) == 0 {
+		return 0, nil
+	}
+
+	latest := result.Datapoints[0]
+	for _, datapoint := range result.Datapoints[1:] {
+		if datapoint.Timestamp.After(latest.Timestamp) {
+			latest = datapoint
+		}
+	}
+
+	var value float64
+	switch a.statistic {
+	case "Sum":
+		value = latest.Sum
+	case "Average":
+		value = latest.Average
+	case "Maximum":
+		value = latest.Maximum
+	case "Minimum":
+		value = latest.Minimum
+	}
+
+	return a.rounding.Round(value), nil
+}
+
+// Close is a no-op as the http client holds no connections of its own
+func (a *awsLBBackend) Close() error {
+	return nil
+}
@@ -0,0 +1,72 @@
+// Package backends holds the interfaces implemented by metric backends and
+// helpers shared by their implementations
+package backends
+
+import (
+	"context"
+
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+// Backend is the source of the metric for a single scaler
+type Backend interface {
+	// MetricName is the name reported to KEDA in the metric spec
+	MetricName() string
+	// GetMetricValue returns the current value of the metric
+	GetMetricValue(ctx context.Context) (int64, error)
+	// Close releases any resources held by the backend
+	Close() error
+}
+
+// ActivityBackend is implemented by backends which decide whether the scaler
+// is active themselves instead of relying on the metric value being above zero
+type ActivityBackend interface {
+	GetMetricAndActivity(ctx context.Context) (int64, bool, error)
+}
+
+// TargetSizeBackend is implemented by backends which know the target value of
+// their metric. It is used when the trigger metadata has no target.
+type TargetSizeBackend interface {
+	TargetSize() int64
+}
+
+// EndpointBackend is implemented by backends which talk to a remote endpoint.
+// The endpoint is used to attribute self-metrics to backend instances.
+type EndpointBackend interface {
+	Endpoint() string
+}
+
+// AuxiliaryMetricsBackend is implemented by backends which collect values in
+// addition to their metric. They are exported with the self-metrics only and
+// never reported to KEDA.
+type AuxiliaryMetricsBackend interface {
+	AuxiliaryMetrics() map[string]float64
+}
+
+// CapabilitiesBackend is implemented by backends which detect the features
+// their endpoint supports. The capabilities are reported by the admin API.
+type CapabilitiesBackend interface {
+	Capabilities() map[string]bool
+}
+
+// WarmUpBackend is implemented by backends which can connect to their
+// endpoint ahead of the first poll
+type WarmUpBackend interface {
+	WarmUp(ctx context.Context) error
+}
+
+// KubeClient sends requests to the Kubernetes API with the service account
+// of the scaler
+type KubeClient interface {
+	// Do sends in as JSON when it is not nil and decodes the response into
+	// out when it is not nil
+	Do(ctx context.Context, method string, path string, in interface{}, out interface{}) error
+}
+
+// KubeClientSource returns the shared Kubernetes client, or an error when the
+// scaler does not run inside a cluster
+type KubeClientSource func() (KubeClient, error)
+
+// BackendFactory creates a backend from the trigger metadata
+type BackendFactory func(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (Backend, error)
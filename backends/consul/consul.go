@@ -0,0 +1,219 @@
+// Package consul is the metric backend reading numeric values and key counts
+// from the Consul KV store
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	// ScalerType is the scalerType metadata value of the consul backend
+	ScalerType = "consul"
+	// DefaultAddress is used by triggers which do not set address
+	DefaultAddress = "http://consul-server.default.svc.cluster.local:8500"
+)
+
+const (
+	valueMetricName    = "ConsulValue"
+	keyCountMetricName = "ConsulKeyCount"
+
+	defaultTimeout = 5 * time.Second
+	maxBodyBytes   = 1024 * 1024
+
+	tokenHeader = "X-Consul-Token"
+)
+
+// consulBackend reports the numeric value stored at key, or with keyPrefix
+// instead of key the number of keys under the prefix. Fractional values are
+// rounded with roundingMode. A missing prefix counts zero keys, a missing key
+// fails the poll.
+type consulBackend struct {
+	address    string
+	key        string
+	keyPrefix  string
+	token      string
+	datacenter string
+	rounding   backends.RoundingMode
+	client     *http.Client
+}
+
+// NewBackend creates a consul backend from the trigger metadata
+func NewBackend(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	backend := consulBackend{
+		address:    DefaultAddress,
+		key:        strings.Trim(metadata["key"], "/"),
+		keyPrefix:  strings.TrimPrefix(metadata["keyPrefix"], "/"),
+		token:      metadata["token"],
+		datacenter: metadata["datacenter"],
+	}
+
+	if backend.key == "" && backend.keyPrefix == "" {
+		return nil, fmt.Errorf("no key or keyPrefix given")
+	}
+
+	if backend.key != "" && backend.keyPrefix != "" {
+		return nil, fmt.Errorf("key and keyPrefix are mutually exclusive")
+	}
+
+	if val, ok := metadata["address"]; ok && val != "" {
+		backend.address = strings.TrimSuffix(val, "/")
+	}
+
+	parsed, err := url.Parse(backend.address)
+	if err != nil {
+		return nil, fmt.Errorf("Address parsing error %s", err.Error())
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("consul address must use http or https")
+	}
+
+	if err := cfg.AllowEgressURL(parsed); err != nil {
+		return nil, err
+	}
+
+	backend.rounding, err = backends.ParseRoundingMode(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := defaultTimeout
+	if val, ok := metadata["timeout"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Timeout parsing error %s", err.Error())
+		}
+
+		if seconds <= 0 {
+			return nil, fmt.Errorf("timeout must be positive")
+		}
+
+		timeout = time.Duration(seconds) * time.Second
+	}
+	backend.client = &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return fmt.Errorf("consul redirects are not followed")
+		},
+	}
+
+	return &backend, nil
+}
+
+// Endpoint returns the host of the consul address
+func (c *consulBackend) Endpoint() string {
+	parsed, err := url.Parse(c.address)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// MetricName returns the name of the value or key count metric
+func (c *consulBackend) MetricName() string {
+	if c.keyPrefix != "" {
+		return keyCountMetricName
+	}
+	return valueMetricName
+}
+
+// GetMetricValue reads the value of the key or counts the keys under the
+// prefix
+func (c *consulBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	if c.keyPrefix != "" {
+		return c.countKeys(ctx)
+	}
+	return c.readValue(ctx)
+}
+
+func (c *consulBackend) readValue(ctx context.Context) (int64, error) {
+	body, found, err := c.get(ctx, c.key, url.Values{"raw": {""}})
+	if err != nil {
+		return -1, err
+	}
+
+	if !found {
+		return -1, fmt.Errorf("consul key %s not found", c.key)
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(string(body)), 64)
+	if err != nil {
+		return -1, fmt.Errorf("Consul value parsing error %s", err.Error())
+	}
+
+	return c.rounding.Round(value), nil
+}
+
+func (c *consulBackend) countKeys(ctx context.Context) (int64, error) {
+	body, found, err := c.get(ctx, c.keyPrefix, url.Values{"keys": {""}})
+	if err != nil {
+		return -1, err
+	}
+
+	if !found {
+		return 0, nil
+	}
+
+	var keys []string
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return -1, fmt.Errorf("Consul key list parsing error %s", err.Error())
+	}
+
+	return int64(len(keys)), nil
+}
+
+// get reads the KV endpoint of key and reports whether it exists
+func (c *consulBackend) get(ctx context.Context, key string, query url.Values) ([]byte, bool, error) {
+	if c.datacenter != "" {
+		query.Set("dc", c.datacenter)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, c.address+"/v1/kv/"+key+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req = req.WithContext(ctx)
+
+	if c.token != "" {
+		req.Header.Set(tokenHeader, c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("consul returned status %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return body, true, nil
+}
+
+// Close is a no-op as the http client is shared through the default transport
+func (c *consulBackend) Close() error {
+	return nil
+}
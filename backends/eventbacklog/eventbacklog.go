@@ -0,0 +1,342 @@
+// Package eventbacklog is the metric backend counting events which were not
+// processed yet, for scaling webhook consumers. The events are read from a
+// provider API or from an events table served by PostgREST.
+package eventbacklog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	// ScalerType is the scalerType metadata value of the eventbacklog backend
+	ScalerType = "event-backlog"
+)
+
+const (
+	unprocessedEventsMetricName = "UnprocessedEvents"
+
+	providerStripe    = "stripe"
+	providerPostgREST = "postgrest"
+
+	stripeURL          = "https://api.stripe.com/v1/events"
+	stripePageSize     = 100
+	defaultMaxEvents   = 10000
+	defaultTable       = "events"
+	defaultQuery       = "processed_at=is.null"
+	defaultTimeout     = 5 * time.Second
+	maxBodyBytes       = 1024 * 1024
+	contentRangeHeader = "Content-Range"
+)
+
+// eventBacklogBackend counts the unprocessed events of provider.
+//
+// stripe counts the events whose webhook deliveries did not all succeed,
+// optionally only those of eventTypes, listing at most maxEvents of them.
+// apiKey is a restricted key with read access to events.
+//
+// postgrest counts the rows of table on the PostgREST server at url matching
+// query, processed_at=is.null by default. The query is a template expanded
+// with the Name and Namespace of the ScaledObject, such as
+// "processed_at=is.null&consumer=eq.{{.Name}}", so consumers can share an
+// events table. apiKey is sent as bearer token.
+type eventBacklogBackend struct {
+	provider  string
+	url       string
+	apiKey    string
+	maxEvents int64
+	client    *http.Client
+}
+
+type queryTemplateData struct {
+	Name      string
+	Namespace string
+}
+
+type stripeEventList struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+	HasMore bool `json:"has_more"`
+}
+
+type stripeError struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewBackend creates an eventbacklog backend from the trigger metadata
+func NewBackend(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	backend := eventBacklogBackend{
+		provider:  metadata["provider"],
+		apiKey:    metadata["apiKey"],
+		maxEvents: defaultMaxEvents,
+	}
+
+	var err error
+	switch backend.provider {
+	case providerStripe:
+		err = backend.parseStripe(metadata)
+	case providerPostgREST:
+		err = backend.parsePostgREST(ref, metadata)
+	default:
+		return nil, fmt.Errorf("provider must be %s or %s", providerStripe, providerPostgREST)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := url.Parse(backend.url)
+	if err != nil {
+		return nil, fmt.Errorf("URL parsing error %s", err.Error())
+	}
+
+	if err := cfg.AllowEgressURL(parsed); err != nil {
+		return nil, err
+	}
+
+	timeout := defaultTimeout
+	if val, ok := metadata["timeout"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Timeout parsing error %s", err.Error())
+		}
+
+		if seconds <= 0 {
+			return nil, fmt.Errorf("timeout must be positive")
+		}
+
+		timeout = time.Duration(seconds) * time.Second
+	}
+	backend.client = &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return fmt.Errorf("event backlog redirects are not followed")
+		},
+	}
+
+	return &backend, nil
+}
+
+func (e *eventBacklogBackend) parseStripe(metadata map[string]string) error {
+	if e.apiKey == "" {
+		return fmt.Errorf("provider %s requires an apiKey", providerStripe)
+	}
+
+	query := url.Values{
+		"delivery_success": {"false"},
+		"limit":            {strconv.Itoa(stripePageSize)},
+	}
+	for _, eventType := range backends.SplitItems(metadata["eventTypes"]) {
+		query.Add("types[]", eventType)
+	}
+	e.url = stripeURL + "?" + query.Encode()
+
+	if val, ok := metadata["maxEvents"]; ok && val != "" {
+		maxEvents, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Max events parsing error %s", err.Error())
+		}
+
+		if maxEvents <= 0 {
+			return fmt.Errorf("max events must be positive")
+		}
+
+		e.maxEvents = maxEvents
+	}
+
+	return nil
+}
+
+func (e *eventBacklogBackend) parsePostgREST(ref *pb.ScaledObjectRef, metadata map[string]string) error {
+	base := strings.TrimSuffix(metadata["url"], "/")
+	if base == "" {
+		return fmt.Errorf("provider %s requires a url", providerPostgREST)
+	}
+
+	parsed, err := url.Parse(base)
+	if err != nil {
+		return fmt.Errorf("URL parsing error %s", err.Error())
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("postgrest url must use http or https")
+	}
+
+	table := defaultTable
+	if val, ok := metadata["table"]; ok && val != "" {
+		table = val
+	}
+
+	queryTemplate := defaultQuery
+	if val, ok := metadata["query"]; ok && val != "" {
+		queryTemplate = val
+	}
+
+	tmpl, err := template.New("query").Option("missingkey=error").Parse(queryTemplate)
+	if err != nil {
+		return fmt.Errorf("Query template parsing error %s", err.Error())
+	}
+
+	var expanded bytes.Buffer
+	if err := tmpl.Execute(&expanded, queryTemplateData{Name: ref.Name, Namespace: ref.Namespace}); err != nil {
+		return fmt.Errorf("Query template parsing error %s", err.Error())
+	}
+
+	// Kubernetes names need no escaping as filter values
+	query, err := url.ParseQuery(expanded.String())
+	if err != nil {
+		return fmt.Errorf("Query parsing error %s", err.Error())
+	}
+
+	e.url = base + "/" + url.PathEscape(table) + "?" + query.Encode()
+	return nil
+}
+
+// Endpoint returns the host of the provider API
+func (e *eventBacklogBackend) Endpoint() string {
+	parsed, err := url.Parse(e.url)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// MetricName returns the name of the unprocessed events metric
+func (e *eventBacklogBackend) MetricName() string {
+	return unprocessedEventsMetricName
+}
+
+// GetMetricValue counts the unprocessed events
+func (e *eventBacklogBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	if e.provider == providerStripe {
+		return e.countStripeEvents(ctx)
+	}
+	return e.countPostgRESTRows(ctx)
+}
+
+// countStripeEvents pages through the events with failed deliveries, newest
+// first, until there are no more or maxEvents were counted
+func (e *eventBacklogBackend) countStripeEvents(ctx context.Context) (int64, error) {
+	var count int64
+	startingAfter := ""
+
+	for count < e.maxEvents {
+		pageURL := e.url
+		if startingAfter != "" {
+			pageURL += "&starting_after=" + url.QueryEscape(startingAfter)
+		}
+
+		var page stripeEventList
+		if err := e.get(ctx, pageURL, &page); err != nil {
+			return -1, err
+		}
+
+		count += int64(len(page.Data))
+		if !page.HasMore || len(page.Data) == 0 {
+			break
+		}
+		startingAfter = page.Data[len(page.Data)-1].ID
+	}
+
+	if count > e.maxEvents {
+		count = e.maxEvents
+	}
+	return count, nil
+}
+
+// countPostgRESTRows asks PostgREST for the exact count of matching rows,
+// which it returns as total of the Content-Range header. A HEAD request
+// leaves out the rows themselves.
+func (e *eventBacklogBackend) countPostgRESTRows(ctx context.Context) (int64, error) {
+	header := http.Header{}
+	header.Set("Prefer", "count=exact")
+
+	resp, err := e.do(ctx, http.MethodHead, e.url, header)
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return -1, fmt.Errorf("postgrest returned status %d", resp.StatusCode)
+	}
+
+	contentRange := resp.Header.Get(contentRangeHeader)
+	index := strings.LastIndex(contentRange, "/")
+	if index < 0 {
+		return -1, fmt.Errorf("postgrest returned no row count")
+	}
+
+	count, err := strconv.ParseInt(contentRange[index+1:], 10, 64)
+	if err != nil {
+		return -1, fmt.Errorf("Row count parsing error %s", err.Error())
+	}
+
+	return count, nil
+}
+
+func (e *eventBacklogBackend) get(ctx context.Context, requestURL string, out interface{}) error {
+	resp, err := e.do(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var result stripeError
+		if err := json.Unmarshal(body, &result); err == nil && result.Error.Message != "" {
+			return fmt.Errorf("%s returned status %d %s", e.provider, resp.StatusCode, result.Error.Message)
+		}
+		return fmt.Errorf("%s returned status %d", e.provider, resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("Event list parsing error %s", err.Error())
+	}
+
+	return nil
+}
+
+func (e *eventBacklogBackend) do(ctx context.Context, method string, requestURL string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	return e.client.Do(req)
+}
+
+// Close is a no-op as the http client holds no connections of its own
+func (e *eventBacklogBackend) Close() error {
+	return nil
+}
@@ -0,0 +1,202 @@
+// Package jolokia is the metric backend reading a numeric JMX attribute
+// through a Jolokia agent, for Java brokers and applications which only
+// expose their queue depth over JMX
+package jolokia
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	// ScalerType is the scalerType metadata value of the jolokia backend
+	ScalerType = "jolokia"
+)
+
+const (
+	attributeMetricName = "JMXAttribute"
+
+	defaultTimeout = 5 * time.Second
+	maxBodyBytes   = 1024 * 1024
+)
+
+// jolokiaBackend reports the value of attribute of mbean, read with a
+// Jolokia read request posted to url. path selects an item of a composite or
+// tabular attribute, such as used of HeapMemoryUsage. Fractional values are
+// rounded with roundingMode, username and password are sent as basic auth.
+type jolokiaBackend struct {
+	url       string
+	request   []byte
+	mbean     string
+	attribute string
+	username  string
+	password  string
+	rounding  backends.RoundingMode
+	client    *http.Client
+}
+
+type readRequest struct {
+	Type      string `json:"type"`
+	MBean     string `json:"mbean"`
+	Attribute string `json:"attribute"`
+	Path      string `json:"path,omitempty"`
+}
+
+type readResponse struct {
+	Status int             `json:"status"`
+	Value  json.RawMessage `json:"value"`
+	Error  string          `json:"error"`
+}
+
+// NewBackend creates a jolokia backend from the trigger metadata
+func NewBackend(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	backend := jolokiaBackend{
+		url:       strings.TrimSuffix(metadata["url"], "/"),
+		mbean:     metadata["mbean"],
+		attribute: metadata["attribute"],
+		username:  metadata["username"],
+		password:  metadata["password"],
+	}
+
+	if backend.url == "" {
+		return nil, fmt.Errorf("no url given")
+	}
+
+	if backend.mbean == "" || backend.attribute == "" {
+		return nil, fmt.Errorf("no mbean and attribute given")
+	}
+
+	if backend.password != "" && backend.username == "" {
+		return nil, fmt.Errorf("password requires a username")
+	}
+
+	parsed, err := url.Parse(backend.url)
+	if err != nil {
+		return nil, fmt.Errorf("URL parsing error %s", err.Error())
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("jolokia url must use http or https")
+	}
+
+	if err := cfg.AllowEgressURL(parsed); err != nil {
+		return nil, err
+	}
+
+	backend.request, err = json.Marshal(readRequest{
+		Type:      "read",
+		MBean:     backend.mbean,
+		Attribute: backend.attribute,
+		Path:      metadata["path"],
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	backend.rounding, err = backends.ParseRoundingMode(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := defaultTimeout
+	if val, ok := metadata["timeout"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Timeout parsing error %s", err.Error())
+		}
+
+		if seconds <= 0 {
+			return nil, fmt.Errorf("timeout must be positive")
+		}
+
+		timeout = time.Duration(seconds) * time.Second
+	}
+	backend.client = &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return fmt.Errorf("jolokia redirects are not followed")
+		},
+	}
+
+	return &backend, nil
+}
+
+// Endpoint returns the host of the jolokia url
+func (j *jolokiaBackend) Endpoint() string {
+	parsed, err := url.Parse(j.url)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// MetricName returns the name of the attribute metric
+func (j *jolokiaBackend) MetricName() string {
+	return attributeMetricName
+}
+
+// GetMetricValue reads the attribute and returns its value
+func (j *jolokiaBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	req, err := http.NewRequest(http.MethodPost, j.url, bytes.NewReader(j.request))
+	if err != nil {
+		return -1, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	if j.username != "" {
+		req.SetBasicAuth(j.username, j.password)
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return -1, fmt.Errorf("jolokia returned status %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	if err != nil {
+		return -1, err
+	}
+
+	// Jolokia reports errors of the request in the body with status 200
+	var result readResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return -1, fmt.Errorf("Jolokia response parsing error %s", err.Error())
+	}
+
+	if result.Status != http.StatusOK {
+		return -1, fmt.Errorf("jolokia read of %s %s failed with status %d %s", j.mbean, j.attribute, result.Status, result.Error)
+	}
+
+	// Numbers are returned as JSON numbers, longs of some agents as strings
+	text := strings.Trim(strings.TrimSpace(string(result.Value)), `"`)
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return -1, fmt.Errorf("%s %s is not numeric", j.mbean, j.attribute)
+	}
+
+	return j.rounding.Round(value), nil
+}
+
+// Close is a no-op as the http client is shared through the default transport
+func (j *jolokiaBackend) Close() error {
+	return nil
+}
@@ -0,0 +1,175 @@
+// Package kubejobs is the metric backend counting the backlog of Kubernetes
+// Jobs, or of their Pending pods
+package kubejobs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+// ScalerType is the scalerType metadata value of the kubejobs backend
+const ScalerType = "kubernetes-jobs"
+
+const (
+	metricName = "KubernetesJobBacklog"
+
+	resourceJobs = "jobs"
+	resourcePods = "pods"
+
+	pageSize = 100
+)
+
+// kubeJobsBackend counts the backlog of jobs in the namespace of the
+// ScaledObject matching labelSelector. With resource jobs, the default, it counts the Jobs which
+// are neither finished nor running a pod yet, that is suspended, not started
+// or without active pods. With resource pods it counts the Pending pods,
+// which also covers pods waiting for an image pull or a node.
+type kubeJobsBackend struct {
+	namespace     string
+	labelSelector string
+	resource      string
+	kube          backends.KubeClient
+}
+
+type jobList struct {
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+	Items []job `json:"items"`
+}
+
+type job struct {
+	Spec struct {
+		Suspend *bool `json:"suspend"`
+	} `json:"spec"`
+	Status struct {
+		StartTime  *string `json:"startTime"`
+		Active     int64   `json:"active"`
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// queued reports whether the job has not finished and runs no pod
+func (j *job) queued() bool {
+	for _, condition := range j.Status.Conditions {
+		if (condition.Type == "Complete" || condition.Type == "Failed") && condition.Status == "True" {
+			return false
+		}
+	}
+
+	if j.Spec.Suspend != nil && *j.Spec.Suspend {
+		return true
+	}
+
+	return j.Status.StartTime == nil || j.Status.Active == 0
+}
+
+type podList struct {
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+	Items []struct{} `json:"items"`
+}
+
+// NewBackendFactory returns the factory of kubejobs backends listing jobs
+// and pods with the client of kube
+func NewBackendFactory(kube backends.KubeClientSource) backends.BackendFactory {
+	return func(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+		backend := kubeJobsBackend{
+			labelSelector: metadata["labelSelector"],
+			resource:      resourceJobs,
+		}
+
+		var err error
+		backend.namespace, err = backends.Namespace(ref, metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		if val, ok := metadata["resource"]; ok && val != "" {
+			if val != resourceJobs && val != resourcePods {
+				return nil, fmt.Errorf("resource must be %s or %s", resourceJobs, resourcePods)
+			}
+			backend.resource = val
+		}
+
+		backend.kube, err = kube()
+		if err != nil {
+			return nil, err
+		}
+
+		return &backend, nil
+	}
+}
+
+// Endpoint returns the namespace of the jobs
+func (k *kubeJobsBackend) Endpoint() string {
+	return "kubernetes-" + k.resource + "/" + k.namespace
+}
+
+// MetricName returns the name of the backlog metric
+func (k *kubeJobsBackend) MetricName() string {
+	return metricName
+}
+
+// GetMetricValue counts the queued jobs or pending pods page by page
+func (k *kubeJobsBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	query := url.Values{
+		"limit": {strconv.Itoa(pageSize)},
+	}
+	if k.labelSelector != "" {
+		query.Set("labelSelector", k.labelSelector)
+	}
+
+	path := fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs", url.PathEscape(k.namespace))
+	if k.resource == resourcePods {
+		path = fmt.Sprintf("/api/v1/namespaces/%s/pods", url.PathEscape(k.namespace))
+		query.Set("fieldSelector", "status.phase=Pending")
+	}
+
+	var count int64
+	for {
+		var next string
+		if k.resource == resourcePods {
+			var list podList
+			if err := k.kube.Do(ctx, http.MethodGet, path+"?"+query.Encode(), nil, &list); err != nil {
+				return -1, err
+			}
+
+			count += int64(len(list.Items))
+			next = list.Metadata.Continue
+		} else {
+			var list jobList
+			if err := k.kube.Do(ctx, http.MethodGet, path+"?"+query.Encode(), nil, &list); err != nil {
+				return -1, err
+			}
+
+			for i := range list.Items {
+				if list.Items[i].queued() {
+					count++
+				}
+			}
+			next = list.Metadata.Continue
+		}
+
+		if next == "" {
+			return count, nil
+		}
+		query.Set("continue", next)
+	}
+}
+
+// Close is a no-op as the kubernetes client is shared
+func (k *kubeJobsBackend) Close() error {
+	return nil
+}
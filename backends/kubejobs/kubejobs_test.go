@@ -0,0 +1,78 @@
+package kubejobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+// fakeKubeClient records the paths requested and answers with empty lists
+type fakeKubeClient struct {
+	paths []string
+}
+
+func (f *fakeKubeClient) Do(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	f.paths = append(f.paths, path)
+	return nil
+}
+
+func TestNamespace(t *testing.T) {
+	ref := &pb.ScaledObjectRef{Name: "worker", Namespace: "team-a"}
+
+	tests := []struct {
+		name      string
+		namespace string
+		wantErr   bool
+	}{
+		{"default", "", false},
+		{"own namespace", "team-a", false},
+		{"foreign namespace", "team-b", true},
+		{"cluster scoped", "kube-system", true},
+	}
+
+	for _, test := range tests {
+		kube := &fakeKubeClient{}
+		factory := NewBackendFactory(func() (backends.KubeClient, error) {
+			return kube, nil
+		})
+
+		metadata := map[string]string{"namespace": test.namespace}
+		backend, err := factory(nil, ref, metadata)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err.Error())
+			continue
+		}
+
+		if _, err := backend.GetMetricValue(context.Background()); err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err.Error())
+			continue
+		}
+
+		for _, path := range kube.paths {
+			if !strings.Contains(path, "/namespaces/team-a/") {
+				t.Errorf("%s: requested %s outside of the ScaledObject's namespace", test.name, path)
+			}
+		}
+	}
+}
+
+func TestKubeClientError(t *testing.T) {
+	factory := NewBackendFactory(func() (backends.KubeClient, error) {
+		return nil, fmt.Errorf("not running inside a kubernetes cluster")
+	})
+
+	ref := &pb.ScaledObjectRef{Name: "worker", Namespace: "team-a"}
+	if _, err := factory(nil, ref, map[string]string{}); err == nil {
+		t.Errorf("expected an error without a kubernetes client")
+	}
+}
@@ -0,0 +1,29 @@
+package backends
+
+import (
+	"fmt"
+	"strings"
+
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+// SplitItems splits a comma separated list of items, dropping empty ones
+func SplitItems(val string) []string {
+	var items []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Namespace returns the namespace of the ScaledObject. The namespace metadata
+// key may only repeat it: the scaler reads objects of every namespace, so a
+// ScaledObject must not see the objects of other tenants.
+func Namespace(ref *pb.ScaledObjectRef, metadata map[string]string) (string, error) {
+	if val, ok := metadata["namespace"]; ok && val != "" && val != ref.Namespace {
+		return "", fmt.Errorf("namespace %s must be the namespace %s of the ScaledObject", val, ref.Namespace)
+	}
+	return ref.Namespace, nil
+}
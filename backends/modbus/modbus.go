@@ -0,0 +1,283 @@
+// Package modbus is the metric backend reading a register of a Modbus TCP
+// device, for backlog counters of machines and PLCs on the factory floor
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	// ScalerType is the scalerType metadata value of the modbus backend
+	ScalerType = "modbus"
+)
+
+const (
+	registerMetricName = "ModbusRegister"
+
+	defaultPort    = "502"
+	defaultUnitID  = 1
+	defaultTimeout = 5 * time.Second
+
+	registerTypeHolding = "holding"
+	registerTypeInput   = "input"
+
+	functionReadHolding = 3
+	functionReadInput   = 4
+
+	// Exception replies set the high bit of the function code
+	exceptionFlag = 0x80
+)
+
+// Register sizes of the supported data types, values spanning two registers
+// are read with the high word first unless wordOrder is little
+var dataTypeRegisters = map[string]uint16{
+	"uint16": 1,
+	"int16":  1,
+	"uint32": 2,
+	"int32":  2,
+}
+
+var exceptionCodes = map[byte]string{
+	1:  "illegal function",
+	2:  "illegal data address",
+	3:  "illegal data value",
+	4:  "server device failure",
+	6:  "server device busy",
+	10: "gateway path unavailable",
+	11: "gateway target device failed to respond",
+}
+
+// modbusBackend reports the value of a holding or input register read with
+// Modbus TCP. Every poll opens its own connection, as many devices only
+// accept a few connections and drop idle ones.
+type modbusBackend struct {
+	address      string
+	unitID       byte
+	function     byte
+	register     uint16
+	dataType     string
+	lowWordFirst bool
+	timeout      time.Duration
+
+	// mu serializes polls so transaction ids stay unique
+	mu            sync.Mutex
+	transactionID uint16
+}
+
+// NewBackend creates a modbus backend from the trigger metadata
+func NewBackend(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	backend := modbusBackend{
+		unitID:   defaultUnitID,
+		function: functionReadHolding,
+		dataType: "uint16",
+		timeout:  defaultTimeout,
+	}
+
+	address, ok := metadata["address"]
+	if !ok || address == "" {
+		return nil, fmt.Errorf("no address given")
+	}
+
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultPort)
+	}
+
+	if err := cfg.AllowEgress(address); err != nil {
+		return nil, err
+	}
+	backend.address = address
+
+	val, ok := metadata["register"]
+	if !ok || val == "" {
+		return nil, fmt.Errorf("no register given")
+	}
+
+	register, err := strconv.ParseUint(val, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("Register parsing error %s", err.Error())
+	}
+	backend.register = uint16(register)
+
+	if val, ok := metadata["registerType"]; ok && val != "" {
+		switch val {
+		case registerTypeHolding:
+			backend.function = functionReadHolding
+		case registerTypeInput:
+			backend.function = functionReadInput
+		default:
+			return nil, fmt.Errorf("registerType must be %s or %s", registerTypeHolding, registerTypeInput)
+		}
+	}
+
+	if val, ok := metadata["dataType"]; ok && val != "" {
+		if _, ok := dataTypeRegisters[val]; !ok {
+			return nil, fmt.Errorf("dataType must be uint16, int16, uint32 or int32")
+		}
+		backend.dataType = val
+	}
+
+	if int(backend.register)+int(dataTypeRegisters[backend.dataType]) > 65536 {
+		return nil, fmt.Errorf("%s at register %d exceeds the register range", backend.dataType, backend.register)
+	}
+
+	if val, ok := metadata["wordOrder"]; ok && val != "" {
+		switch val {
+		case "big":
+		case "little":
+			backend.lowWordFirst = true
+		default:
+			return nil, fmt.Errorf("wordOrder must be big or little")
+		}
+	}
+
+	if val, ok := metadata["unitId"]; ok && val != "" {
+		unitID, err := strconv.ParseUint(val, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("Unit id parsing error %s", err.Error())
+		}
+		backend.unitID = byte(unitID)
+	}
+
+	if val, ok := metadata["timeout"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Timeout parsing error %s", err.Error())
+		}
+
+		if seconds <= 0 {
+			return nil, fmt.Errorf("timeout must be positive")
+		}
+
+		backend.timeout = time.Duration(seconds) * time.Second
+	}
+
+	return &backend, nil
+}
+
+// Endpoint returns the address of the device
+func (m *modbusBackend) Endpoint() string {
+	return m.address
+}
+
+// MetricName returns the name of the register metric
+func (m *modbusBackend) MetricName() string {
+	return registerMetricName
+}
+
+// GetMetricValue reads the register and returns its value
+func (m *modbusBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	registers, err := m.readRegisters(ctx)
+	if err != nil {
+		return -1, fmt.Errorf("modbus %s", err.Error())
+	}
+
+	switch m.dataType {
+	case "int16":
+		return int64(int16(registers[0])), nil
+	case "uint32", "int32":
+		high, low := registers[0], registers[1]
+		if m.lowWordFirst {
+			high, low = low, high
+		}
+
+		value := uint32(high)<<16 | uint32(low)
+		if m.dataType == "int32" {
+			return int64(int32(value)), nil
+		}
+		return int64(value), nil
+	default:
+		return int64(registers[0]), nil
+	}
+}
+
+func (m *modbusBackend) readRegisters(ctx context.Context) ([]uint16, error) {
+	deadline := time.Now().Add(m.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", m.address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	count := dataTypeRegisters[m.dataType]
+	m.transactionID++
+
+	// MBAP header: transaction id, protocol id 0, length of the remaining
+	// bytes and unit id, followed by the function, start and count
+	request := make([]byte, 12)
+	binary.BigEndian.PutUint16(request[0:2], m.transactionID)
+	binary.BigEndian.PutUint16(request[4:6], 6)
+	request[6] = m.unitID
+	request[7] = m.function
+	binary.BigEndian.PutUint16(request[8:10], m.register)
+	binary.BigEndian.PutUint16(request[10:12], count)
+
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+
+	if binary.BigEndian.Uint16(header[0:2]) != m.transactionID {
+		return nil, fmt.Errorf("reply for another transaction from %s", m.address)
+	}
+
+	length := binary.BigEndian.Uint16(header[4:6])
+	if length < 3 || length > 256 {
+		return nil, fmt.Errorf("reply of %d bytes from %s", length, m.address)
+	}
+
+	pdu := make([]byte, length-1)
+	if _, err := io.ReadFull(conn, pdu); err != nil {
+		return nil, err
+	}
+
+	if pdu[0] == m.function|exceptionFlag {
+		reason, ok := exceptionCodes[pdu[1]]
+		if !ok {
+			reason = fmt.Sprintf("exception %d", pdu[1])
+		}
+		return nil, fmt.Errorf("%s returned %s for register %d", m.address, reason, m.register)
+	}
+
+	if pdu[0] != m.function || int(pdu[1]) != int(count)*2 || len(pdu) < 2+int(count)*2 {
+		return nil, fmt.Errorf("malformed reply from %s", m.address)
+	}
+
+	registers := make([]uint16, count)
+	for i := range registers {
+		registers[i] = binary.BigEndian.Uint16(pdu[2+i*2:])
+	}
+
+	return registers, nil
+}
+
+// Close is a no-op as no connection is held between polls
+func (m *modbusBackend) Close() error {
+	return nil
+}
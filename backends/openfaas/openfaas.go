@@ -0,0 +1,200 @@
+// Package openfaas is the metric backend reading the depth of an OpenFaaS
+// async invocation queue from the monitoring endpoint of NATS Streaming
+package openfaas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	// ScalerType is the scalerType metadata value of the openfaas backend
+	ScalerType = "openfaas"
+	// DefaultMonitoringEndpoint is used by triggers which do not set
+	// monitoringEndpoint
+	DefaultMonitoringEndpoint = "http://nats.openfaas.svc.cluster.local:8222"
+	// DefaultQueue is the channel the gateway publishes async invocations to
+	// unless a function names its own queue
+	DefaultQueue = "faas-request"
+	// DefaultQueueGroup is the queue group of the OpenFaaS queue-worker
+	DefaultQueueGroup = "faas"
+)
+
+const (
+	queueDepthMetricName = "OpenFaaSQueueDepth"
+
+	defaultTimeout = 5 * time.Second
+	maxBodyBytes   = 1024 * 1024
+)
+
+// openfaasBackend reports the invocations of queue not yet delivered to the
+// queue-worker, the last sequence of the channel minus the last sequence
+// sent to queueGroup. Without subscriptions of the group every message in
+// the channel is pending. Functions get their own queue, and so their own
+// depth, with the com.openfaas.queue annotation.
+type openfaasBackend struct {
+	endpoint   string
+	queue      string
+	queueGroup string
+	client     *http.Client
+}
+
+type channelResponse struct {
+	Name          string         `json:"name"`
+	Msgs          int64          `json:"msgs"`
+	LastSeq       int64          `json:"last_seq"`
+	Subscriptions []subscription `json:"subscriptions"`
+}
+
+type subscription struct {
+	QueueName string `json:"queue_name"`
+	LastSent  int64  `json:"last_sent"`
+}
+
+// NewBackend creates an openfaas backend from the trigger metadata
+func NewBackend(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	backend := openfaasBackend{
+		endpoint:   DefaultMonitoringEndpoint,
+		queue:      DefaultQueue,
+		queueGroup: DefaultQueueGroup,
+	}
+
+	if val, ok := metadata["monitoringEndpoint"]; ok && val != "" {
+		backend.endpoint = strings.TrimSuffix(val, "/")
+	}
+
+	if val, ok := metadata["queue"]; ok && val != "" {
+		backend.queue = val
+	}
+
+	if val, ok := metadata["queueGroup"]; ok && val != "" {
+		backend.queueGroup = val
+	}
+
+	parsed, err := url.Parse(backend.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("Monitoring endpoint parsing error %s", err.Error())
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("monitoring endpoint must use http or https")
+	}
+
+	if err := cfg.AllowEgressURL(parsed); err != nil {
+		return nil, err
+	}
+
+	timeout := defaultTimeout
+	if val, ok := metadata["timeout"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Timeout parsing error %s", err.Error())
+		}
+
+		if seconds <= 0 {
+			return nil, fmt.Errorf("timeout must be positive")
+		}
+
+		timeout = time.Duration(seconds) * time.Second
+	}
+	backend.client = &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return fmt.Errorf("monitoring endpoint redirects are not followed")
+		},
+	}
+
+	return &backend, nil
+}
+
+// Endpoint returns the host of the monitoring endpoint
+func (o *openfaasBackend) Endpoint() string {
+	parsed, err := url.Parse(o.endpoint)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// MetricName returns the name of the queue depth metric
+func (o *openfaasBackend) MetricName() string {
+	return queueDepthMetricName
+}
+
+// GetMetricValue returns the number of pending invocations of the queue
+func (o *openfaasBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	query := url.Values{
+		"channel": {o.queue},
+		"subs":    {"1"},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, o.endpoint+"/streaming/channelsz?"+query.Encode(), nil)
+	if err != nil {
+		return -1, err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+
+	// The channel is created with the first invocation
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return -1, fmt.Errorf("nats streaming returned status %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	if err != nil {
+		return -1, err
+	}
+
+	var channel channelResponse
+	if err := json.Unmarshal(body, &channel); err != nil {
+		return -1, fmt.Errorf("Channel parsing error %s", err.Error())
+	}
+
+	// Members of a durable queue group are reported as durable:group
+	var lastSent int64 = -1
+	for _, sub := range channel.Subscriptions {
+		group := sub.QueueName
+		if i := strings.LastIndex(group, ":"); i >= 0 {
+			group = group[i+1:]
+		}
+
+		if group == o.queueGroup && sub.LastSent > lastSent {
+			lastSent = sub.LastSent
+		}
+	}
+
+	if lastSent < 0 {
+		return channel.Msgs, nil
+	}
+
+	if pending := channel.LastSeq - lastSent; pending > 0 {
+		return pending, nil
+	}
+	return 0, nil
+}
+
+// Close is a no-op as the http client is shared through the default transport
+func (o *openfaasBackend) Close() error {
+	return nil
+}
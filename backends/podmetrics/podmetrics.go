@@ -0,0 +1,205 @@
+// Package podmetrics is the metric backend aggregating the CPU or memory
+// usage of pods from the Kubernetes metrics API
+package podmetrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+// ScalerType is the scalerType metadata value of the podmetrics backend
+const ScalerType = "pod-metrics"
+
+const (
+	resourceCPU    = "cpu"
+	resourceMemory = "memory"
+
+	aggregationSum     = "sum"
+	aggregationAverage = "average"
+	aggregationMax     = "max"
+)
+
+// quantitySuffixes are the multipliers of the Kubernetes quantity suffixes
+var quantitySuffixes = map[string]float64{
+	"n":  1e-9,
+	"u":  1e-6,
+	"m":  1e-3,
+	"":   1,
+	"k":  1e3,
+	"M":  1e6,
+	"G":  1e9,
+	"T":  1e12,
+	"P":  1e15,
+	"E":  1e18,
+	"Ki": 1 << 10,
+	"Mi": 1 << 20,
+	"Gi": 1 << 30,
+	"Ti": 1 << 40,
+	"Pi": 1 << 50,
+	"Ei": 1 << 60,
+}
+
+// podMetricsBackend reads the CPU usage in millicores or the memory usage
+// in bytes of the pods in the namespace of the ScaledObject matching
+// labelSelector from the metrics API, summed over the containers of a pod, or only container. The pod
+// values are aggregated with sum, the default, average or max.
+type podMetricsBackend struct {
+	namespace     string
+	labelSelector string
+	resource      string
+	container     string
+	aggregation   string
+	kube          backends.KubeClient
+}
+
+type metricsList struct {
+	Items []struct {
+		Containers []struct {
+			Name  string            `json:"name"`
+			Usage map[string]string `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+// NewBackendFactory returns the factory of podmetrics backends reading the
+// metrics API with the client of kube
+func NewBackendFactory(kube backends.KubeClientSource) backends.BackendFactory {
+	return func(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+		backend := podMetricsBackend{
+			labelSelector: metadata["labelSelector"],
+			resource:      metadata["resource"],
+			container:     metadata["container"],
+			aggregation:   aggregationSum,
+		}
+
+		if backend.labelSelector == "" {
+			return nil, fmt.Errorf("no labelSelector given")
+		}
+
+		var err error
+		backend.namespace, err = backends.Namespace(ref, metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		if backend.resource != resourceCPU && backend.resource != resourceMemory {
+			return nil, fmt.Errorf("resource must be %s or %s", resourceCPU, resourceMemory)
+		}
+
+		if val, ok := metadata["aggregation"]; ok && val != "" {
+			switch val {
+			case aggregationSum, aggregationAverage, aggregationMax:
+			default:
+				return nil, fmt.Errorf("aggregation must be %s, %s or %s", aggregationSum, aggregationAverage, aggregationMax)
+			}
+			backend.aggregation = val
+		}
+
+		backend.kube, err = kube()
+		if err != nil {
+			return nil, err
+		}
+
+		return &backend, nil
+	}
+}
+
+// Endpoint returns the namespace of the pods
+func (p *podMetricsBackend) Endpoint() string {
+	return "metrics.k8s.io/" + p.namespace
+}
+
+// MetricName returns the name of the usage metric
+func (p *podMetricsBackend) MetricName() string {
+	if p.resource == resourceCPU {
+		return "PodCPUMillicores"
+	}
+	return "PodMemoryBytes"
+}
+
+// GetMetricValue reads the usage of the matching pods and aggregates it
+func (p *podMetricsBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	query := url.Values{"labelSelector": {p.labelSelector}}
+	path := fmt.Sprintf("/apis/metrics.k8s.io/v1beta1/namespaces/%s/pods?%s", url.PathEscape(p.namespace), query.Encode())
+
+	var list metricsList
+	if err := p.kube.Do(ctx, http.MethodGet, path, nil, &list); err != nil {
+		return -1, err
+	}
+
+	// CPU is reported in millicores
+	unit := 1.0
+	if p.resource == resourceCPU {
+		unit = 1e-3
+	}
+
+	var total, max float64
+	for _, pod := range list.Items {
+		var usage float64
+		for _, container := range pod.Containers {
+			if p.container != "" && container.Name != p.container {
+				continue
+			}
+
+			value, err := parseQuantity(container.Usage[p.resource])
+			if err != nil {
+				return -1, err
+			}
+			usage += value / unit
+		}
+
+		total += usage
+		if usage > max {
+			max = usage
+		}
+	}
+
+	switch p.aggregation {
+	case aggregationMax:
+		return int64(math.Ceil(max)), nil
+	case aggregationAverage:
+		if len(list.Items) == 0 {
+			return 0, nil
+		}
+		return int64(math.Ceil(total / float64(len(list.Items)))), nil
+	default:
+		return int64(math.Ceil(total)), nil
+	}
+}
+
+// parseQuantity parses a Kubernetes quantity such as 250m, 1.5Gi or 12e3.
+// A missing quantity is zero.
+func parseQuantity(quantity string) (float64, error) {
+	if quantity == "" {
+		return 0, nil
+	}
+
+	number := strings.TrimRight(quantity, "numkMGTPEi")
+	suffix := quantity[len(number):]
+
+	multiplier, ok := quantitySuffixes[suffix]
+	if !ok {
+		return 0, fmt.Errorf("unknown quantity suffix in %s", quantity)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Quantity parsing error %s", err.Error())
+	}
+
+	return value * multiplier, nil
+}
+
+// Close is a no-op as the kubernetes client is shared
+func (p *podMetricsBackend) Close() error {
+	return nil
+}
@@ -0,0 +1,156 @@
+// Package push is the metric backend serving the values applications push
+// to the admin API of the scaler
+package push
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+// ScalerType is the scalerType metadata value of the push backend
+const ScalerType = "push"
+
+const (
+	metricName = "PushedValue"
+
+	// minTokenLength keeps tokens from being guessed
+	minTokenLength = 16
+
+	// What a scaler serves when no value was pushed within maxAge
+	staleError = "error"
+	staleZero  = "zero"
+	staleLast  = "last"
+)
+
+// Values holds the pushed values by the namespace/name of their
+// ScaledObject. Every backend acquires the name of its scaler when it is
+// created and releases it when it is closed, so values of removed scalers
+// can be dropped.
+type Values interface {
+	Acquire(name string)
+	Release(name string)
+	// Value returns the last value pushed for the scaler and when it was
+	// received
+	Value(name string) (int64, time.Time, bool)
+}
+
+// Receiver is implemented by push backends. The admin API only accepts a
+// value for a scaler whose backend authorizes the token of the push.
+type Receiver interface {
+	Authorized(token string) bool
+}
+
+// pushBackend serves the value applications push to the /push endpoint of
+// the admin API, so no backend has to be polled at all. Pushes must carry
+// pushToken, usually resolved from a TriggerAuthentication, as a bearer
+// token.
+//
+// With maxAge a value not renewed in time is stale, and staleValue decides
+// whether a stale value fails the poll (error, the default), reports zero or
+// keeps reporting the last value.
+type pushBackend struct {
+	name       string
+	token      []byte
+	maxAge     time.Duration
+	staleValue string
+	values     Values
+
+	closeOnce sync.Once
+}
+
+// NewBackendFactory returns the factory of push backends serving values
+func NewBackendFactory(values Values) backends.BackendFactory {
+	return func(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+		if cfg.AdminPort == 0 {
+			return nil, fmt.Errorf("push backend requires the adminPort to be configured")
+		}
+
+		token := metadata["pushToken"]
+		if len(token) < minTokenLength {
+			return nil, fmt.Errorf("pushToken must have at least %d characters", minTokenLength)
+		}
+
+		backend := pushBackend{
+			name:       ref.Namespace + "/" + ref.Name,
+			token:      []byte(token),
+			staleValue: staleError,
+			values:     values,
+		}
+
+		if val, ok := metadata["maxAge"]; ok && val != "" {
+			maxAge, err := time.ParseDuration(val)
+			if err != nil {
+				return nil, fmt.Errorf("Max age parsing error %s", err.Error())
+			}
+
+			if maxAge <= 0 {
+				return nil, fmt.Errorf("maxAge must be positive")
+			}
+
+			backend.maxAge = maxAge
+		}
+
+		if val, ok := metadata["staleValue"]; ok && val != "" {
+			if backend.maxAge == 0 {
+				return nil, fmt.Errorf("staleValue requires maxAge")
+			}
+
+			switch val {
+			case staleError, staleZero, staleLast:
+			default:
+				return nil, fmt.Errorf("staleValue must be %s, %s or %s", staleError, staleZero, staleLast)
+			}
+			backend.staleValue = val
+		}
+
+		values.Acquire(backend.name)
+
+		return &backend, nil
+	}
+}
+
+// MetricName returns the name of the pushed metric
+func (p *pushBackend) MetricName() string {
+	return metricName
+}
+
+// GetMetricValue returns the last pushed value, applying the stale value
+// policy when it is older than maxAge
+func (p *pushBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	value, received, ok := p.values.Value(p.name)
+	if !ok {
+		return -1, fmt.Errorf("no value pushed for %s", p.name)
+	}
+
+	if age := time.Since(received); p.maxAge > 0 && age > p.maxAge {
+		switch p.staleValue {
+		case staleZero:
+			return 0, nil
+		case staleError:
+			return -1, fmt.Errorf("value pushed for %s is stale, last push %s ago", p.name, age.Truncate(time.Second))
+		}
+	}
+
+	return value, nil
+}
+
+// Authorized reports whether token is the push token of the scaler
+func (p *pushBackend) Authorized(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), p.token) == 1
+}
+
+// Close releases the pushed value, it is kept for a while for the next
+// registration
+func (p *pushBackend) Close() error {
+	p.closeOnce.Do(func() {
+		p.values.Release(p.name)
+	})
+	return nil
+}
@@ -0,0 +1,878 @@
+// Package redis is the metric backend reading lists, keys and modules of
+// redis servers
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	// ScalerType is the scalerType metadata value of the redis backend
+	ScalerType = "redis"
+	// DefaultAddress and DefaultPassword are used by triggers which do not
+	// set address and password
+	DefaultAddress  = "redis-master.default.svc.cluster.local:6379"
+	DefaultPassword = ""
+)
+
+const (
+	listLengthMetricName = "RedisListLength"
+	keyExistsMetricName  = "RedisKeyExists"
+	maxSampleSize        = 1000
+
+	// Redis has 16 databases unless configured otherwise
+	maxDatabaseIndex = 1023
+
+	sampleFromHead = "head"
+	sampleFromTail = "tail"
+
+	pushSideLeft  = "left"
+	pushSideRight = "right"
+)
+
+// redisBackend reports the length of a redis list, or with keyPattern
+// instead of listName the number of keys matching the pattern, or with
+// existsKey 1 when the key exists and 0 otherwise, or a value read through a
+// redis module or INFO (see redis_modules.go and redis_info.go).
+//
+// At registration the backend probes which commands the server allows and
+// falls back to alternatives where it can, such as DBSIZE instead of SCAN to
+// count all keys. The flavor metadata names the server implementation
+// (redis, dragonfly, keydb or valkey), registration fails when the server
+// identifies as another one.
+//
+// With proxyMode the server is a redis proxy such as twemproxy or Envoy.
+// Probing and flavor checks are skipped and only single key commands are
+// used, which rules out keyPattern and modules.
+//
+// With sampleSize set it also reads (never pops) up to that many messages
+// from the head or tail of the list and reports their size distribution and,
+// when timestampField names a unix timestamp field of JSON payloads, their
+// age as auxiliary self-metrics.
+//
+// With minMessageAgeSeconds set only messages older than that are counted.
+// This needs timestampField and assumes messages are pushed in order on the
+// pushSide of the list (left for LPUSH, the default, or right for RPUSH).
+// Messages without a readable timestamp are counted.
+//
+// With addresses instead of address the metric is read from several redis
+// endpoints (see redis_multi.go).
+//
+// When address is a replica, maxReplicaLagSeconds or maxReplicaLagBytes
+// guard against stale replica data (see redis_replica.go). When address is a
+// primary, readFromReplicas reads from its replicas instead (see
+// redis_replica_reads.go).
+//
+// With heartbeatKey set the metric is only trusted while the producer keeps
+// the heartbeat fresh (see redis_heartbeat.go).
+//
+// With pauseKey set the metric is zero while that key exists (see
+// redis_pause.go).
+type redisBackend struct {
+	address        string
+	username       string
+	password       string
+	database       int
+	tlsConfig      *tls.Config
+	listName       string
+	keyPattern     string
+	existsKey      string
+	module         redisModuleQuery
+	rounding       backends.RoundingMode
+	sampleSize     int64
+	sampleFrom     string
+	timestampField string
+	minMessageAge  time.Duration
+	pushSide       string
+	heartbeat      *heartbeatCheck
+	pause          *pauseSwitch
+	breaker        *circuitBreaker
+	replica        *replicaGate
+	replicaReads   *replicaReads
+	cache          *lengthCache
+	proxy          *redisProxy
+	tunnel         *sshTunnel
+	pool           redisPoolOptions
+	keepalive      *redisKeepalive
+	resolver       *addressResolver
+	credentials    credentialSource
+	vault          *vaultCredentials
+	timeouts       redisTimeouts
+
+	// client is created at registration and reused by every poll, it is
+	// only replaced when the node was demoted (see redis_redirect.go) or
+	// its host resolves to new IPs (see redis_resolve.go).
+	// primaryClient is used when a lagging replica falls back to primaryAddress.
+	clientMu      sync.RWMutex
+	client        *goredis.Client
+	primaryClient *goredis.Client
+	redirects     *redisRedirects
+
+	proxyMode      bool
+	flavor         string
+	detectedFlavor string
+	capabilities   map[string]bool
+	useDBSize      bool
+
+	// handshakeServer is the server name of the last HELLO handshake, see
+	// negotiateProtocol
+	handshakeMu     sync.Mutex
+	handshakeServer string
+
+	// The commands are probed once, see probeCommands
+	probeMu  sync.Mutex
+	probed   bool
+	probeErr error
+
+	mu     sync.Mutex
+	sample map[string]float64
+}
+
+// NewBackend creates a redis backend from the trigger metadata
+func NewBackend(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	metadata, err := expandRedisEnv(cfg, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	if val, ok := metadata["addresses"]; ok && val != "" {
+		return parseMultiRedisMetadata(cfg, ref, metadata)
+	}
+
+	if strings.Contains(metadata["address"], ",") {
+		return parseFailoverRedisMetadata(cfg, ref, metadata)
+	}
+
+	metadata, err = expandRedisSRV(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err = expandRedisURL(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err = expandAzureCache(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	backend := redisBackend{}
+
+	backend.listName = metadata["listName"]
+	backend.keyPattern = metadata["keyPattern"]
+	backend.existsKey = metadata["existsKey"]
+
+	module, err := parseRedisModuleQuery(metadata)
+	if err != nil {
+		return nil, err
+	}
+	backend.module = module
+
+	backend.rounding, err = backends.ParseRoundingMode(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	modes := 0
+	for _, set := range []bool{backend.listName != "", backend.keyPattern != "", backend.existsKey != "", backend.module != nil} {
+		if set {
+			modes++
+		}
+	}
+
+	if modes == 0 {
+		return nil, fmt.Errorf("no list name given")
+	}
+
+	if modes > 1 {
+		return nil, fmt.Errorf("listName, keyPattern, existsKey and module keys are mutually exclusive")
+	}
+
+	backend.address = DefaultAddress
+	if val, ok := metadata["address"]; ok && val != "" {
+		backend.address = val
+	}
+
+	if err := cfg.AllowEgress(backend.address); err != nil {
+		return nil, err
+	}
+
+	backend.password = DefaultPassword
+	if val, ok := metadata["password"]; ok && val != "" {
+		backend.password = val
+	}
+
+	passwordFile, err := parsePasswordFile(cfg, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	if passwordFile != nil {
+		if val, ok := metadata["vaultPath"]; ok && val != "" {
+			return nil, fmt.Errorf("passwordFile and vaultPath are mutually exclusive")
+		}
+		backend.credentials = passwordFile
+	}
+
+	// username selects a Redis 6 ACL user instead of the default user
+	if val, ok := metadata["username"]; ok && val != "" {
+		if backend.password == "" && backend.credentials == nil && metadata["vaultPath"] == "" {
+			return nil, fmt.Errorf("username requires a password")
+		}
+		backend.username = val
+	}
+
+	if val, ok := metadata["databaseIndex"]; ok && val != "" {
+		database, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Database index parsing error %s", err.Error())
+		}
+
+		if database < 0 || database > maxDatabaseIndex {
+			return nil, fmt.Errorf("database index must be between 0 and %d", maxDatabaseIndex)
+		}
+
+		backend.database = database
+	}
+
+	backend.tlsConfig, err = parseRedisTLS(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.proxy, err = parseRedisProxy(cfg, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.tunnel, err = parseSSHTunnel(cfg, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	iamToken, err := parseIAMAuthToken(cfg, metadata, backend.tlsConfig != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if iamToken != nil {
+		if backend.credentials != nil || metadata["vaultPath"] != "" {
+			return nil, fmt.Errorf("iamAuth, passwordFile and vaultPath are mutually exclusive")
+		}
+		backend.credentials = iamToken
+	}
+
+	aadToken, err := parseAzureADToken(cfg, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	if aadToken != nil {
+		if backend.credentials != nil || metadata["vaultPath"] != "" {
+			return nil, fmt.Errorf("azureAuth %s, iamAuth and vaultPath are mutually exclusive", azureAuthAAD)
+		}
+		backend.credentials = aadToken
+	}
+
+	if val, ok := metadata["sampleSize"]; ok && val != "" {
+		sampleSize, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Sample size parsing error %s", err.Error())
+		}
+
+		if sampleSize < 0 || sampleSize > maxSampleSize {
+			return nil, fmt.Errorf("sample size must be between 0 and %d", maxSampleSize)
+		}
+
+		backend.sampleSize = sampleSize
+	}
+
+	backend.sampleFrom = sampleFromHead
+	if val, ok := metadata["sampleFrom"]; ok && val != "" {
+		if val != sampleFromHead && val != sampleFromTail {
+			return nil, fmt.Errorf("sampleFrom must be %s or %s", sampleFromHead, sampleFromTail)
+		}
+		backend.sampleFrom = val
+	}
+
+	backend.timestampField = metadata["timestampField"]
+
+	if val, ok := metadata["minMessageAgeSeconds"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Minimum message age parsing error %s", err.Error())
+		}
+
+		if seconds < 0 {
+			return nil, fmt.Errorf("minimum message age must not be negative")
+		}
+
+		if seconds > 0 && backend.timestampField == "" {
+			return nil, fmt.Errorf("minMessageAgeSeconds requires timestampField")
+		}
+
+		backend.minMessageAge = time.Duration(seconds) * time.Second
+	}
+
+	backend.pushSide = pushSideLeft
+	if val, ok := metadata["pushSide"]; ok && val != "" {
+		if val != pushSideLeft && val != pushSideRight {
+			return nil, fmt.Errorf("pushSide must be %s or %s", pushSideLeft, pushSideRight)
+		}
+		backend.pushSide = val
+	}
+
+	backend.heartbeat, err = parseHeartbeatCheck(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.pause = parsePauseSwitch(metadata)
+
+	backend.breaker, err = parseCircuitBreaker(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.replica, err = parseReplicaGate(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.replicaReads, err = parseReplicaReads(cfg, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	if backend.replica != nil && backend.replicaReads != nil {
+		return nil, fmt.Errorf("readFromReplicas needs the primary as address and rules out replica lag checks")
+	}
+
+	if backend.replica != nil && backend.replica.primaryAddress != "" {
+		if err := cfg.AllowEgress(backend.replica.primaryAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	if backend.listName == "" && (backend.sampleSize > 0 || backend.minMessageAge > 0) {
+		return nil, fmt.Errorf("sampling and message age need a listName")
+	}
+
+	backend.flavor, err = parseRedisFlavor(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	if val, ok := metadata["proxyMode"]; ok && val != "" {
+		proxyMode, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("Proxy mode parsing error %s", err.Error())
+		}
+		backend.proxyMode = proxyMode
+	}
+
+	if backend.proxyMode {
+		// Proxies only forward single key commands, admin commands such as
+		// INFO and HELLO or keyspace wide ones such as SCAN are rejected
+		if backend.keyPattern != "" || backend.module != nil {
+			return nil, fmt.Errorf("keyPattern and modules are not supported in proxy mode")
+		}
+
+		if backend.replica != nil || backend.replicaReads != nil {
+			return nil, fmt.Errorf("replica lag checks and reads are not supported in proxy mode")
+		}
+
+		if backend.database != 0 {
+			return nil, fmt.Errorf("databaseIndex is not supported in proxy mode")
+		}
+	}
+
+	backend.cache, err = parseClientCache(&backend, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.pool, err = parseRedisPoolOptions(cfg, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.timeouts, err = parseRedisTimeouts(cfg, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.keepalive, err = parseRedisKeepalive(cfg, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.resolver, err = parseAddressResolver(metadata, backend.address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Vault is read last, the credentials are revoked by Close
+	backend.vault, err = parseVaultCredentials(cfg, metadata)
+	if err != nil {
+		return nil, err
+	}
+	if backend.vault != nil {
+		backend.credentials = backend.vault
+	}
+
+	backend.client = backend.newClient(backend.address)
+	backend.redirects = newRedisRedirects(cfg)
+	if backend.replica != nil && backend.replica.primaryAddress != "" {
+		backend.primaryClient = backend.newClient(backend.replica.primaryAddress)
+	}
+	if backend.keepalive != nil {
+		go backend.keepalive.run(&backend)
+	}
+	if backend.resolver != nil {
+		go backend.resolver.run(&backend)
+	}
+
+	if backend.proxyMode {
+		backend.probed = true
+		backend.capabilities = map[string]bool{
+			"llen":   true,
+			"lrange": true,
+			"lindex": true,
+			"scan":   false,
+			"dbsize": false,
+			"exists": true,
+		}
+
+		return &backend, nil
+	}
+
+	validate, err := parseValidateConnection(metadata)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+
+	if validate {
+		if err := backend.validateConnection(backend.client); err != nil {
+			backend.Close()
+			return nil, err
+		}
+
+		if err := backend.probeCommands(backend.client); err != nil && !isConnectionError(err) {
+			backend.Close()
+			return nil, err
+		}
+	}
+
+	return &backend, nil
+}
+
+// Endpoint returns the redis address
+func (r *redisBackend) Endpoint() string {
+	return r.address
+}
+
+// MetricName returns the name of the list length, key count or key exists
+// metric
+func (r *redisBackend) MetricName() string {
+	if r.module != nil {
+		return r.module.metricName()
+	}
+	if r.keyPattern != "" {
+		return keyCountMetricName
+	}
+	if r.existsKey != "" {
+		return keyExistsMetricName
+	}
+	return listLengthMetricName
+}
+
+// GetMetricValue returns the length of the list. It gives up when ctx is
+// done, see callWithContext.
+func (r *redisBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	read := func() (int64, error) {
+		return callWithContext(ctx, func() (int64, error) {
+			return r.getMetricValue(ctx)
+		})
+	}
+
+	if r.breaker != nil {
+		return r.breaker.call(r.address, read, func() bool {
+			return ctx.Err() != nil
+		})
+	}
+
+	return read()
+}
+
+func (r *redisBackend) getMetricValue(ctx context.Context) (int64, error) {
+	client := r.getClient()
+
+	if err := r.probeCommands(client); err != nil {
+		return -1, err
+	}
+
+	if r.replica != nil {
+		var err error
+		client, err = r.replicaClient(client)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	if r.replicaReads != nil {
+		return r.readFromReplica(ctx, func(client *goredis.Client) (int64, error) {
+			return r.readRedirected(ctx, client)
+		})
+	}
+
+	return r.readRedirected(ctx, client)
+}
+
+// read reads the metric through client, unless the backend is paused or the
+// heartbeat is stale. Every step checks ctx first, so no further commands
+// are sent for a call KEDA gave up on.
+func (r *redisBackend) read(ctx context.Context, client *goredis.Client) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	if r.pause != nil {
+		paused, err := r.pause.check(client, r.address)
+		if err != nil {
+			return -1, err
+		}
+
+		if paused {
+			return 0, nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return -1, err
+		}
+	}
+
+	if r.heartbeat != nil {
+		return r.heartbeat.guard(client, func() (int64, error) {
+			return r.readMetric(ctx, client)
+		})
+	}
+
+	return r.readMetric(ctx, client)
+}
+
+func (r *redisBackend) readMetric(ctx context.Context, client *goredis.Client) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	if r.module != nil {
+		value, err := r.module.query(client)
+		if err != nil {
+			return -1, err
+		}
+		return r.rounding.Round(value), nil
+	}
+
+	if r.keyPattern != "" {
+		return r.countKeys(ctx, client)
+	}
+
+	if r.existsKey != "" {
+		return client.Exists(r.existsKey).Result()
+	}
+
+	var length int64
+	var err error
+	if r.cache != nil && client == r.getClient() {
+		length, err = r.cache.listLength(r, client)
+	} else {
+		length, err = getRedisListLength(ctx, client, r.listName)
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	if r.sampleSize > 0 && ctx.Err() == nil {
+		r.updateSample(client)
+	}
+
+	if r.minMessageAge > 0 && length > 0 {
+		fresh, err := r.countFreshMessages(ctx, client, length)
+		if err != nil {
+			return -1, err
+		}
+		length -= fresh
+	}
+
+	return length, nil
+}
+
+// countFreshMessages binary searches the list from its push side for the
+// first message older than the minimum age. Every message before it is fresh.
+func (r *redisBackend) countFreshMessages(ctx context.Context, client *goredis.Client, length int64) (int64, error) {
+	cutoff := time.Now().Add(-r.minMessageAge)
+
+	index := func(i int64) int64 {
+		if r.pushSide == pushSideRight {
+			return -(i + 1)
+		}
+		return i
+	}
+
+	low, high := int64(0), length
+	for low < high {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		mid := low + (high-low)/2
+
+		message, err := client.LIndex(r.listName, index(mid)).Result()
+		if err == goredis.Nil {
+			// The list shrank while searching
+			high = mid
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		timestamp, ok := messageTimestamp(message, r.timestampField)
+		if ok && timestamp.After(cutoff) {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+
+	return low, nil
+}
+
+// AuxiliaryMetrics returns the statistics of the last sample and the state
+// of the circuit breaker, 1 when open
+func (r *redisBackend) AuxiliaryMetrics() map[string]float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.breaker == nil {
+		return r.sample
+	}
+
+	metrics := make(map[string]float64, len(r.sample)+1)
+	for key, value := range r.sample {
+		metrics[key] = value
+	}
+
+	metrics["breaker_open"] = 0
+	if r.breaker.isOpen() {
+		metrics["breaker_open"] = 1
+	}
+
+	return metrics
+}
+
+// WarmUp opens a pooled connection and checks the server answers a PING
+func (r *redisBackend) WarmUp(ctx context.Context) error {
+	_, err := callWithContext(ctx, func() (int64, error) {
+		return 0, r.getClient().Ping().Err()
+	})
+	return err
+}
+
+// Close closes the connection pools of the backend
+func (r *redisBackend) Close() error {
+	if r.replicaReads != nil {
+		r.replicaReads.close()
+	}
+	r.redirects.close()
+	if r.cache != nil {
+		r.cache.close()
+	}
+	if r.vault != nil {
+		r.vault.close()
+	}
+	if r.keepalive != nil {
+		r.keepalive.close()
+	}
+	if r.resolver != nil {
+		r.resolver.close()
+	}
+
+	err := r.getClient().Close()
+	if r.primaryClient != nil {
+		if closeErr := r.primaryClient.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// updateSample reads messages from the list without removing them and
+// records their statistics. Sampling failures are not fatal to the metric.
+func (r *redisBackend) updateSample(client *goredis.Client) {
+	start, stop := int64(0), r.sampleSize-1
+	if r.sampleFrom == sampleFromTail {
+		start, stop = -r.sampleSize, -1
+	}
+
+	messages, err := client.LRange(r.listName, start, stop).Result()
+	if err != nil {
+		return
+	}
+
+	stats := map[string]float64{
+		"sample_count": float64(len(messages)),
+	}
+
+	if len(messages) > 0 {
+		sizes := make([]float64, len(messages))
+		total := 0.0
+		for i, message := range messages {
+			sizes[i] = float64(len(message))
+			total += sizes[i]
+		}
+		sort.Float64s(sizes)
+
+		stats["sample_avg_size_bytes"] = total / float64(len(sizes))
+		stats["sample_p50_size_bytes"] = sizes[(len(sizes)-1)/2]
+		stats["sample_p95_size_bytes"] = sizes[int(float64(len(sizes)-1)*0.95)]
+		stats["sample_max_size_bytes"] = sizes[len(sizes)-1]
+	}
+
+	if r.timestampField != "" {
+		now := time.Now()
+		count, totalAge, maxAge := 0, 0.0, 0.0
+
+		for _, message := range messages {
+			timestamp, ok := messageTimestamp(message, r.timestampField)
+			if !ok {
+				continue
+			}
+
+			age := now.Sub(timestamp).Seconds()
+			count++
+			totalAge += age
+			if age > maxAge {
+				maxAge = age
+			}
+		}
+
+		if count > 0 {
+			stats["sample_avg_age_seconds"] = totalAge / float64(count)
+			stats["sample_max_age_seconds"] = maxAge
+		}
+	}
+
+	r.mu.Lock()
+	r.sample = stats
+	r.mu.Unlock()
+}
+
+// messageTimestamp reads a unix timestamp in seconds from a field of a JSON
+// message
+func messageTimestamp(message string, field string) (time.Time, bool) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &payload); err != nil {
+		return time.Time{}, false
+	}
+
+	value, ok := payload[field].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	seconds := int64(value)
+	nanos := int64((value - float64(seconds)) * float64(time.Second))
+	return time.Unix(seconds, nanos), true
+}
+
+// newClient creates a client for address, which is the backend's address or
+// another node of the same deployment, with the backend's connection settings
+func (r *redisBackend) newClient(address string) *goredis.Client {
+	var tlsConfig *tls.Config
+	if r.tlsConfig != nil {
+		tlsConfig = r.tlsConfig.Clone()
+	}
+
+	options := &goredis.Options{
+		Addr:         address,
+		Password:     r.password,
+		DB:           r.database,
+		TLSConfig:    tlsConfig,
+		PoolSize:     r.pool.size,
+		MinIdleConns: r.pool.minIdle,
+		IdleTimeout:  r.pool.idleTimeout,
+		DialTimeout:  r.timeouts.dial,
+		ReadTimeout:  r.timeouts.read,
+		WriteTimeout: r.timeouts.write,
+	}
+
+	// The client dials itself unless connections go through a proxy or an
+	// SSH tunnel
+	if r.proxy != nil || r.tunnel != nil {
+		options.Dialer = func() (net.Conn, error) {
+			return r.dialRedis(address)
+		}
+	}
+
+	// The client only knows AUTH with a fixed password, an ACL user or a
+	// password read from a file authenticates on every new connection
+	// instead. The database is selected afterwards as SELECT needs an
+	// authenticated user.
+	customAuth := r.username != "" || r.credentials != nil
+	if customAuth {
+		options.Password = ""
+		options.DB = 0
+	}
+
+	// Every new connection of the pool negotiates the protocol, after
+	// authenticating as HELLO requires an authenticated user
+	options.OnConnect = func(conn *goredis.Conn) error {
+		if customAuth {
+			if err := r.authenticate(conn); err != nil {
+				return err
+			}
+
+			if r.database != 0 {
+				if err := conn.Do("select", r.database).Err(); err != nil {
+					return err
+				}
+			}
+		}
+
+		if r.proxyMode {
+			return nil
+		}
+		return r.negotiateProtocol(conn)
+	}
+
+	return goredis.NewClient(options)
+}
+
+func getRedisListLength(ctx context.Context, client *goredis.Client, listName string) (int64, error) {
+	cmd := client.LLen(listName)
+
+	if cmd.Err() != nil {
+		return -1, cmd.Err()
+	}
+
+	return cmd.Result()
+}
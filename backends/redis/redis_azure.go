@@ -0,0 +1,286 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const (
+	// Azure Cache for Redis only serves TLS on this port by default
+	azureCacheTLSPort = "6380"
+
+	azureAuthAccessKey = "accessKey"
+	azureAuthAAD       = "aad"
+
+	// Tokens are renewed this long before they expire
+	azureTokenRenewal = 5 * time.Minute
+	azureTimeout      = 10 * time.Second
+	maxAzureBodyBytes = 64 * 1024
+
+	azureRedisScope        = "https://redis.azure.com/.default"
+	azureRedisResource     = "https://redis.azure.com"
+	azureDefaultAuthority  = "https://login.microsoftonline.com/"
+	azureIMDSTokenEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// azureCacheHosts are the hosts of Azure Cache for Redis, which AAD tokens
+// are sent to without being listed in the azureAD allowedHosts
+var azureCacheHosts = []string{"*.redis.cache.windows.net", "*.redisenterprise.cache.azure.net"}
+
+// expandAzureCache applies the defaults of Azure Cache for Redis when
+// azureCache is set: the address gets the TLS port 6380 when it has none and
+// TLS is enabled unless enableTLS is set explicitly. With the default
+// azureAuth of accessKey the access key is the password, so it can come
+// from password, passwordFromEnv or passwordFile.
+func expandAzureCache(metadata map[string]string) (map[string]string, error) {
+	val, ok := metadata["azureCache"]
+	if !ok || val == "" {
+		if auth, ok := metadata["azureAuth"]; ok && auth != "" {
+			return nil, fmt.Errorf("azureAuth requires azureCache")
+		}
+		return metadata, nil
+	}
+
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		return nil, fmt.Errorf("Azure cache parsing error %s", err.Error())
+	}
+
+	if !enabled {
+		return metadata, nil
+	}
+
+	expanded := make(map[string]string, len(metadata)+1)
+	for key, value := range metadata {
+		expanded[key] = value
+	}
+
+	address := expanded["address"]
+	if address == "" {
+		return nil, fmt.Errorf("azureCache requires an address")
+	}
+
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		expanded["address"] = net.JoinHostPort(address, azureCacheTLSPort)
+	}
+
+	if val, ok := expanded["enableTLS"]; !ok || val == "" {
+		expanded["enableTLS"] = "true"
+	}
+
+	switch expanded["azureAuth"] {
+	case "", azureAuthAccessKey:
+	case azureAuthAAD:
+		if expanded["password"] != "" || expanded["passwordFile"] != "" {
+			return nil, fmt.Errorf("azureAuth %s rules out password and passwordFile", azureAuthAAD)
+		}
+	default:
+		return nil, fmt.Errorf("azureAuth must be %s or %s", azureAuthAccessKey, azureAuthAAD)
+	}
+
+	return expanded, nil
+}
+
+// azureADToken authenticates with a Microsoft Entra ID (AAD) access token for
+// Azure Cache for Redis. The username is azureObjectId, the object id of the
+// identity the cache granted access to. The token is obtained with workload
+// identity when AZURE_FEDERATED_TOKEN_FILE is set, and from the managed
+// identity of the node otherwise, for which azureClientId selects a user
+// assigned identity.
+type azureADToken struct {
+	objectID string
+	clientID string
+	client   *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+type azureTokenResponse struct {
+	AccessToken string      `json:"access_token"`
+	ExpiresIn   json.Number `json:"expires_in"`
+	ExpiresOn   json.Number `json:"expires_on"`
+}
+
+// parseAzureADToken reads azureObjectId and azureClientId. It returns nil
+// unless azureCache is set with azureAuth aad. The token of the scaler's
+// identity is only sent to the hosts the azureAD server config allows.
+func parseAzureADToken(cfg *config.Config, metadata map[string]string) (*azureADToken, error) {
+	if enabled, _ := strconv.ParseBool(metadata["azureCache"]); !enabled || metadata["azureAuth"] != azureAuthAAD {
+		return nil, nil
+	}
+
+	if !cfg.AzureAD.Enabled {
+		return nil, fmt.Errorf("azureAuth %s is not enabled in the server config", azureAuthAAD)
+	}
+
+	address := metadata["address"]
+	if !config.MatchHost(azureCacheHosts, address) && !config.MatchHost(cfg.AzureAD.AllowedHosts, address) {
+		return nil, fmt.Errorf("azureAuth %s is not allowed for %s, it is not an Azure Cache for Redis host", azureAuthAAD, address)
+	}
+
+	token := &azureADToken{
+		objectID: metadata["azureObjectId"],
+		clientID: metadata["azureClientId"],
+		client:   &http.Client{Timeout: azureTimeout},
+	}
+
+	if token.objectID == "" {
+		return nil, fmt.Errorf("azureAuth %s requires azureObjectId", azureAuthAAD)
+	}
+
+	if err := token.refresh(); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// credentials returns the object id and the access token, renewing the
+// token when it is about to expire
+func (t *azureADToken) credentials() (string, string) {
+	t.mu.Lock()
+	stale := time.Until(t.expires) < azureTokenRenewal
+	t.mu.Unlock()
+
+	if stale {
+		if err := t.refresh(); err != nil {
+			log.Printf("Could not renew AAD token %s", err.Error())
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.objectID, t.token
+}
+
+// refresh obtains a new access token
+func (t *azureADToken) refresh() error {
+	var (
+		req *http.Request
+		err error
+	)
+
+	if tokenFile := os.Getenv("AZURE_FEDERATED_TOKEN_FILE"); tokenFile != "" {
+		req, err = t.workloadIdentityRequest(tokenFile)
+	} else {
+		req, err = t.managedIdentityRequest()
+	}
+	if err != nil {
+		return fmt.Errorf("AAD token not available %s", err.Error())
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("AAD token not available %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxAzureBodyBytes))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("AAD token request returned status %d", resp.StatusCode)
+	}
+
+	var result azureTokenResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("AAD token parsing error %s", err.Error())
+	}
+
+	if result.AccessToken == "" {
+		return fmt.Errorf("AAD token response holds no access token")
+	}
+
+	// The token endpoint returns expires_in, IMDS also expires_on
+	expires := time.Now().Add(time.Hour)
+	if seconds, err := result.ExpiresIn.Int64(); err == nil {
+		expires = time.Now().Add(time.Duration(seconds) * time.Second)
+	} else if unix, err := result.ExpiresOn.Int64(); err == nil {
+		expires = time.Unix(unix, 0)
+	}
+
+	t.mu.Lock()
+	t.token = result.AccessToken
+	t.expires = expires
+	t.mu.Unlock()
+
+	return nil
+}
+
+// workloadIdentityRequest exchanges the federated service account token for
+// an access token of the application in AZURE_CLIENT_ID
+func (t *azureADToken) workloadIdentityRequest(tokenFile string) (*http.Request, error) {
+	assertion, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantID := os.Getenv("AZURE_TENANT_ID")
+	clientID := t.clientID
+	if clientID == "" {
+		clientID = os.Getenv("AZURE_CLIENT_ID")
+	}
+
+	if tenantID == "" || clientID == "" {
+		return nil, fmt.Errorf("workload identity requires AZURE_TENANT_ID and AZURE_CLIENT_ID")
+	}
+
+	authority := os.Getenv("AZURE_AUTHORITY_HOST")
+	if authority == "" {
+		authority = azureDefaultAuthority
+	}
+
+	form := url.Values{
+		"grant_type":            {"client_credentials"},
+		"client_id":             {clientID},
+		"scope":                 {azureRedisScope},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {strings.TrimSpace(string(assertion))},
+	}
+
+	endpoint := strings.TrimSuffix(authority, "/") + "/" + url.PathEscape(tenantID) + "/oauth2/v2.0/token"
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return req, nil
+}
+
+// managedIdentityRequest asks the instance metadata service of the node
+func (t *azureADToken) managedIdentityRequest() (*http.Request, error) {
+	query := url.Values{
+		"api-version": {"2018-02-01"},
+		"resource":    {azureRedisResource},
+	}
+	if t.clientID != "" {
+		query.Set("client_id", t.clientID)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, azureIMDSTokenEndpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+
+	return req, nil
+}
@@ -0,0 +1,156 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const defaultBreakerOpenDuration = 30 * time.Second
+
+// circuitBreaker stops polling a redis which failed breakerFailures times in
+// a row. While open, polls fail fast, or report breakerFallbackValue when it
+// is set, until breakerOpenSeconds passed. A single poll then probes the
+// server and closes the breaker when it succeeds.
+type circuitBreaker struct {
+	failures     int
+	openDuration time.Duration
+	fallback     *int64
+
+	mu          sync.Mutex
+	consecutive int
+	openedAt    time.Time
+	open        bool
+	probing     bool
+}
+
+func parseCircuitBreaker(metadata map[string]string) (*circuitBreaker, error) {
+	val, ok := metadata["breakerFailures"]
+	if !ok || val == "" {
+		return nil, nil
+	}
+
+	failures, err := strconv.Atoi(val)
+	if err != nil {
+		return nil, fmt.Errorf("Breaker failures parsing error %s", err.Error())
+	}
+
+	if failures <= 0 {
+		return nil, fmt.Errorf("breaker failures must be positive")
+	}
+
+	breaker := &circuitBreaker{
+		failures:     failures,
+		openDuration: defaultBreakerOpenDuration,
+	}
+
+	if val, ok := metadata["breakerOpenSeconds"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Breaker open seconds parsing error %s", err.Error())
+		}
+
+		if seconds <= 0 {
+			return nil, fmt.Errorf("breaker open seconds must be positive")
+		}
+
+		breaker.openDuration = time.Duration(seconds) * time.Second
+	}
+
+	if val, ok := metadata["breakerFallbackValue"]; ok && val != "" {
+		fallback, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Breaker fallback value parsing error %s", err.Error())
+		}
+
+		if fallback < 0 {
+			return nil, fmt.Errorf("breaker fallback value must not be negative")
+		}
+
+		breaker.fallback = &fallback
+	}
+
+	return breaker, nil
+}
+
+// call runs read unless the breaker is open. Failures of polls cancelled by
+// the caller are not held against the server.
+func (b *circuitBreaker) call(address string, read func() (int64, error), cancelled func() bool) (int64, error) {
+	if !b.allow() {
+		if b.fallback != nil {
+			return *b.fallback, nil
+		}
+		return -1, fmt.Errorf("circuit breaker for %s is open", address)
+	}
+
+	value, err := read()
+	if err != nil && cancelled() {
+		b.release()
+		return value, err
+	}
+
+	b.record(address, err)
+	return value, err
+}
+
+// allow reports whether a call may go to the server, letting a single probe
+// through once the breaker was open for openDuration
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return true
+	}
+
+	if b.probing || time.Since(b.openedAt) < b.openDuration {
+		return false
+	}
+
+	b.probing = true
+	return true
+}
+
+// release lets another call probe the server after an inconclusive probe
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *circuitBreaker) record(address string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if err == nil {
+		if b.open {
+			log.Printf("Circuit breaker for %s closed", address)
+		}
+		b.open = false
+		b.consecutive = 0
+		return
+	}
+
+	b.consecutive++
+	if b.open {
+		b.openedAt = time.Now()
+		log.Printf("Circuit breaker for %s stays open after a failed probe %s", address, err.Error())
+	} else if b.consecutive >= b.failures {
+		b.open = true
+		b.openedAt = time.Now()
+		log.Printf("Circuit breaker for %s opened after %d failures %s", address, b.consecutive, err.Error())
+	}
+}
+
+// isOpen reports the breaker state for the self-metrics
+func (b *circuitBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.open
+}
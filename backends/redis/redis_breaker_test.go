@@ -0,0 +1,105 @@
+package redis
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		wantErr  bool
+	}{
+		{"invalid failures", map[string]string{"breakerFailures": "many"}, true},
+		{"zero failures", map[string]string{"breakerFailures": "0"}, true},
+		{"invalid open seconds", map[string]string{"breakerFailures": "3", "breakerOpenSeconds": "soon"}, true},
+		{"zero open seconds", map[string]string{"breakerFailures": "3", "breakerOpenSeconds": "0"}, true},
+		{"invalid fallback", map[string]string{"breakerFailures": "3", "breakerFallbackValue": "none"}, true},
+		{"negative fallback", map[string]string{"breakerFailures": "3", "breakerFallbackValue": "-1"}, true},
+		{"valid", map[string]string{"breakerFailures": "3", "breakerOpenSeconds": "10", "breakerFallbackValue": "0"}, false},
+	}
+
+	for _, test := range tests {
+		_, err := parseCircuitBreaker(test.metadata)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want error %t", test.name, err, test.wantErr)
+		}
+	}
+
+	breaker, err := parseCircuitBreaker(map[string]string{})
+	if err != nil || breaker != nil {
+		t.Errorf("got %v %v, want no breaker without breakerFailures", breaker, err)
+	}
+
+	breaker, _ = parseCircuitBreaker(map[string]string{"breakerFailures": "3", "breakerOpenSeconds": "10", "breakerFallbackValue": "5"})
+	if breaker.failures != 3 || breaker.openDuration != 10*time.Second || *breaker.fallback != 5 {
+		t.Errorf("got breaker %+v", breaker)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := &circuitBreaker{failures: 2, openDuration: time.Hour}
+	notCancelled := func() bool { return false }
+
+	reads := 0
+	failing := func() (int64, error) {
+		reads++
+		return -1, errors.New("connection refused")
+	}
+	succeeding := func() (int64, error) {
+		reads++
+		return 7, nil
+	}
+
+	// Failures of cancelled polls are not counted
+	breaker.call("redis:6379", failing, func() bool { return true })
+	breaker.call("redis:6379", failing, notCancelled)
+	if breaker.isOpen() {
+		t.Fatalf("expected the breaker to stay closed after one counted failure")
+	}
+
+	breaker.call("redis:6379", failing, notCancelled)
+	if !breaker.isOpen() {
+		t.Fatalf("expected the breaker to open after two failures")
+	}
+
+	reads = 0
+	if _, err := breaker.call("redis:6379", succeeding, notCancelled); err == nil || reads != 0 {
+		t.Errorf("expected the open breaker to fail fast, got %v after %d reads", err, reads)
+	}
+
+	fallback := int64(3)
+	breaker.fallback = &fallback
+	if value, err := breaker.call("redis:6379", succeeding, notCancelled); err != nil || value != 3 {
+		t.Errorf("got %d %v, want the fallback value 3", value, err)
+	}
+	breaker.fallback = nil
+
+	// A failed probe keeps the breaker open for another openDuration
+	breaker.openedAt = time.Now().Add(-2 * time.Hour)
+	if _, err := breaker.call("redis:6379", failing, notCancelled); err == nil || !breaker.isOpen() {
+		t.Errorf("expected the failed probe to keep the breaker open")
+	}
+	if breaker.allow() {
+		t.Errorf("expected no probe right after a failed one")
+	}
+
+	// Only one call probes at a time, a successful probe closes the breaker
+	breaker.openedAt = time.Now().Add(-2 * time.Hour)
+	if !breaker.allow() {
+		t.Fatalf("expected a probe after openDuration")
+	}
+	if breaker.allow() {
+		t.Errorf("expected a single probe at a time")
+	}
+	breaker.release()
+
+	if value, err := breaker.call("redis:6379", succeeding, notCancelled); err != nil || value != 7 {
+		t.Errorf("got %d %v, want the probe to read 7", value, err)
+	}
+	if breaker.isOpen() || breaker.consecutive != 0 {
+		t.Errorf("expected the successful probe to close the breaker")
+	}
+}
@@ -0,0 +1,197 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	goredis "github.com/go-redis/redis"
+)
+
+const (
+	keyCountMetricName = "RedisKeyCount"
+	scanBatchSize      = 1000
+)
+
+// probeRedisCommands runs a harmless invocation of every command a redis
+// backend may use and reports which ones the server allows. Managed tiers
+// and ACL restricted users reject some of them. Commands failing on their
+// input are assumed to be allowed. The probes stop at the first connection
+// error, which is returned, so an unreachable server costs one timeout.
+func probeRedisCommands(client *goredis.Client, key string, module redisModuleQuery) (map[string]bool, error) {
+	probes := map[string][]interface{}{
+		"llen":   {"llen", key},
+		"lrange": {"lrange", key, 0, 0},
+		"lindex": {"lindex", key, 0},
+		"scan":   {"scan", 0, "count", 1},
+		"dbsize": {"dbsize"},
+		"exists": {"exists", key},
+	}
+
+	// Module commands are called without arguments, which fails with an
+	// arity error when the module is loaded and unknown command otherwise
+	if module != nil {
+		probes[module.command()] = []interface{}{module.command()}
+	}
+
+	capabilities := make(map[string]bool)
+	for command, args := range probes {
+		err := client.Do(args...).Err()
+		if isConnectionError(err) {
+			return nil, err
+		}
+		capabilities[command] = err == nil || err == goredis.Nil || !isCommandRejected(err)
+	}
+
+	return capabilities, nil
+}
+
+// isConnectionError reports whether err means redis could not be reached
+// rather than that it answered with an error
+func isConnectionError(err error) bool {
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	return err == io.EOF
+}
+
+// probeCommands detects the flavor of the server and the commands it allows
+// once, and adapts the backend to them. New probes when the connection is
+// validated, otherwise the first poll does, so registering with
+// validateConnection disabled never waits for an unreachable server. A
+// connection error leaves the backend unprobed for the next poll to retry, a
+// rejected command or a server of another flavor than the declared one fails
+// every poll.
+func (r *redisBackend) probeCommands(client *goredis.Client) error {
+	r.probeMu.Lock()
+	defer r.probeMu.Unlock()
+
+	if r.probed {
+		return r.probeErr
+	}
+
+	key := r.listName
+	if r.existsKey != "" {
+		key = r.existsKey
+	}
+
+	capabilities, err := probeRedisCommands(client, key, r.module)
+	if err != nil {
+		return err
+	}
+
+	err = r.checkRedisFlavor(client)
+	if isConnectionError(err) {
+		return err
+	}
+
+	r.probed = true
+	r.probeErr = err
+	if r.probeErr == nil {
+		r.probeErr = r.selectRedisCommands(capabilities)
+	}
+	return r.probeErr
+}
+
+// isCommandRejected reports whether an error means the command is disabled,
+// renamed or not permitted for the user rather than failing on its input
+func isCommandRejected(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.HasPrefix(message, "noperm") ||
+		strings.Contains(message, "unknown command") ||
+		strings.Contains(message, "no permissions") ||
+		strings.Contains(message, "command is disabled")
+}
+
+// selectRedisCommands adapts the backend to the commands the server allows
+func (r *redisBackend) selectRedisCommands(capabilities map[string]bool) error {
+	r.capabilities = capabilities
+
+	if r.keyPattern != "" && !capabilities["scan"] {
+		if r.keyPattern != "*" {
+			return errCommandRejected("scan", "keyPattern "+r.keyPattern)
+		}
+
+		if !capabilities["dbsize"] {
+			return errCommandRejected("scan and dbsize", "keyPattern *")
+		}
+
+		log.Printf("SCAN is not allowed on %s, counting keys with DBSIZE", r.address)
+		r.useDBSize = true
+	}
+
+	if r.module != nil && !capabilities[r.module.command()] {
+		return fmt.Errorf("redis does not allow %s, is the command permitted and its module loaded", r.module.command())
+	}
+
+	if r.existsKey != "" && !capabilities["exists"] {
+		return errCommandRejected("exists", "existsKey")
+	}
+
+	if r.listName != "" && !capabilities["llen"] {
+		return errCommandRejected("llen", "listName")
+	}
+
+	if r.sampleSize > 0 && !capabilities["lrange"] {
+		log.Printf("LRANGE is not allowed on %s, sampling is disabled", r.address)
+		r.sampleSize = 0
+	}
+
+	if r.minMessageAge > 0 && !capabilities["lindex"] {
+		return errCommandRejected("lindex", "minMessageAgeSeconds")
+	}
+
+	return nil
+}
+
+func errCommandRejected(command string, feature string) error {
+	return fmt.Errorf("redis does not allow %s which is needed for %s", command, feature)
+}
+
+// countKeys counts the keys matching the pattern, with DBSIZE when every key
+// matches and SCAN is not allowed
+func (r *redisBackend) countKeys(ctx context.Context, client *goredis.Client) (int64, error) {
+	if r.useDBSize {
+		return client.DBSize().Result()
+	}
+
+	var count int64
+	var cursor uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return -1, err
+		}
+
+		keys, next, err := client.Scan(cursor, r.keyPattern, scanBatchSize).Result()
+		if err != nil {
+			return -1, err
+		}
+
+		count += int64(len(keys))
+		cursor = next
+		if cursor == 0 {
+			return count, nil
+		}
+	}
+}
+
+// Capabilities returns the commands detected as allowed at registration and
+// the flavor the server identified as
+func (r *redisBackend) Capabilities() map[string]bool {
+	r.probeMu.Lock()
+	defer r.probeMu.Unlock()
+
+	capabilities := make(map[string]bool, len(r.capabilities)+1)
+	for command, allowed := range r.capabilities {
+		capabilities[command] = allowed
+	}
+
+	if r.detectedFlavor != "" {
+		capabilities["flavor:"+r.detectedFlavor] = true
+	}
+
+	return capabilities
+}
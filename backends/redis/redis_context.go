@@ -0,0 +1,32 @@
+package redis
+
+import "context"
+
+// callWithContext runs call and returns the context's error as soon as ctx is
+// done. The vendored client cannot cancel a command in flight, so a command
+// abandoned this way completes in the background, bounded by the client's
+// read timeout, before its connection returns to the pool. Reads check the
+// context between commands and send no further commands once it is done.
+func callWithContext(ctx context.Context, call func() (int64, error)) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	type result struct {
+		value int64
+		err   error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		value, err := call()
+		done <- result{value, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	case res := <-done:
+		return res.value, res.err
+	}
+}
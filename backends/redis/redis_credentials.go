@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	goredis "github.com/go-redis/redis"
+)
+
+// credentialSource provides credentials which change over the lifetime of a
+// backend, such as a mounted password file or short-lived Vault credentials.
+// New connections authenticate with the current credentials.
+type credentialSource interface {
+	// credentials returns the username, empty to use the username metadata,
+	// and the password
+	credentials() (string, string)
+	// refresh fetches the credentials again after redis rejected them
+	refresh() error
+}
+
+// authenticate sends AUTH on a new connection. Credentials from a source
+// which redis rejects are fetched again and retried once.
+func (r *redisBackend) authenticate(conn *goredis.Conn) error {
+	err := r.auth(conn)
+	if err == nil || r.credentials == nil || !isAuthError(err) {
+		return err
+	}
+
+	if refreshErr := r.credentials.refresh(); refreshErr != nil {
+		log.Printf("Credentials of %s rejected and not refreshed %s", r.address, refreshErr.Error())
+		return err
+	}
+
+	log.Printf("Credentials of %s rejected, retrying with refreshed credentials", r.address)
+	return r.auth(conn)
+}
+
+func (r *redisBackend) auth(conn *goredis.Conn) error {
+	args := r.authArgs()
+	if args == nil {
+		return nil
+	}
+	return conn.Do(args...).Err()
+}
+
+// authArgs returns the AUTH command for the current credentials, or nil when
+// no authentication is needed
+func (r *redisBackend) authArgs() []interface{} {
+	username, password := r.username, r.password
+	if r.credentials != nil {
+		var sourceUsername string
+		sourceUsername, password = r.credentials.credentials()
+		if sourceUsername != "" {
+			username = sourceUsername
+		}
+	}
+
+	if username != "" {
+		return []interface{}{"auth", username, password}
+	}
+
+	// A server without a password configured rejects AUTH, which an empty
+	// password opts out of
+	if password == "" {
+		return nil
+	}
+	return []interface{}{"auth", password}
+}
+
+func isAuthError(err error) bool {
+	message := err.Error()
+	return strings.HasPrefix(message, "WRONGPASS") ||
+		strings.Contains(message, "invalid password") ||
+		strings.Contains(message, "invalid username-password pair")
+}
@@ -0,0 +1,50 @@
+package redis
+
+import (
+	"fmt"
+
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+// metadataFromEnv maps the metadata keys which may be read from the scaler's
+// environment to the key naming the environment variable
+var metadataFromEnv = map[string]string{
+	"address":  "addressFromEnv",
+	"password": "passwordFromEnv",
+}
+
+// expandRedisEnv resolves passwordFromEnv and addressFromEnv, like the redis
+// scaler built into KEDA, into the password and address metadata keys. The
+// resolved address may still be a URL or be combined with addresses.
+func expandRedisEnv(cfg *config.Config, metadata map[string]string) (map[string]string, error) {
+	var expanded map[string]string
+	for key, envKey := range metadataFromEnv {
+		name, ok := metadata[envKey]
+		if !ok || name == "" {
+			continue
+		}
+
+		if val, ok := metadata[key]; ok && val != "" {
+			return nil, fmt.Errorf("%s and %s are mutually exclusive", key, envKey)
+		}
+
+		val, err := cfg.LookupMetadataEnv(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s", envKey, err.Error())
+		}
+
+		if expanded == nil {
+			expanded = make(map[string]string, len(metadata))
+			for k, v := range metadata {
+				expanded[k] = v
+			}
+		}
+		expanded[key] = val
+		delete(expanded, envKey)
+	}
+
+	if expanded == nil {
+		return metadata, nil
+	}
+	return expanded, nil
+}
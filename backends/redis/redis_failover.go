@@ -0,0 +1,180 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	addressFailoverOrdered = "ordered"
+	addressFailoverSticky  = "sticky"
+)
+
+// failoverRedisBackend reads the metric from the first endpoint of a comma
+// separated address which answers, for a manual failover or a DNS split
+// deployment without Sentinel. With addressFailover ordered every poll
+// starts with the first endpoint, so the scaler returns to it once it is
+// back. With sticky the endpoint which answered last is kept until it fails.
+type failoverRedisBackend struct {
+	backends []*redisBackend
+	sticky   bool
+
+	mu      sync.Mutex
+	current int
+}
+
+// parseFailoverRedisMetadata creates a redis backend for every endpoint of
+// the address, the remaining metadata applies to all of them. With
+// validateConnection only one endpoint has to be reachable.
+func parseFailoverRedisMetadata(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	addresses := backends.SplitItems(metadata["address"])
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no address given")
+	}
+
+	failover := failoverRedisBackend{}
+	switch metadata["addressFailover"] {
+	case "", addressFailoverOrdered:
+	case addressFailoverSticky:
+		failover.sticky = true
+	default:
+		return nil, fmt.Errorf("addressFailover must be %s or %s", addressFailoverOrdered, addressFailoverSticky)
+	}
+
+	validate, err := parseValidateConnection(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, address := range addresses {
+		endpointMetadata := make(map[string]string, len(metadata))
+		for key, value := range metadata {
+			endpointMetadata[key] = value
+		}
+		delete(endpointMetadata, "addressFailover")
+		endpointMetadata["address"] = address
+		endpointMetadata["validateConnection"] = "false"
+
+		backend, err := NewBackend(cfg, ref, endpointMetadata)
+		if err != nil {
+			failover.Close()
+			if s, ok := status.FromError(err); ok {
+				return nil, status.Errorf(s.Code(), "redis %s %s", address, s.Message())
+			}
+			return nil, fmt.Errorf("redis %s %s", address, err.Error())
+		}
+		failover.backends = append(failover.backends, backend.(*redisBackend))
+	}
+
+	if validate {
+		if err := failover.validate(); err != nil {
+			failover.Close()
+			return nil, err
+		}
+	}
+
+	return &failover, nil
+}
+
+// validate requires one endpoint to be reachable. A key of the wrong type
+// or a rejected command fails regardless of the other endpoints, as it is a
+// misconfiguration.
+func (f *failoverRedisBackend) validate() error {
+	var err error
+	for _, backend := range f.backends {
+		err = backend.validateConnection(backend.getClient())
+		if err == nil {
+			if probeErr := backend.probeCommands(backend.getClient()); probeErr != nil && !isConnectionError(probeErr) {
+				return probeErr
+			}
+			return nil
+		}
+
+		if status.Code(err) == codes.InvalidArgument {
+			return err
+		}
+	}
+	return err
+}
+
+// Endpoint returns the redis addresses in failover order
+func (f *failoverRedisBackend) Endpoint() string {
+	addresses := make([]string, len(f.backends))
+	for i, backend := range f.backends {
+		addresses[i] = backend.address
+	}
+	return strings.Join(addresses, ",")
+}
+
+// MetricName returns the metric name shared by all endpoints
+func (f *failoverRedisBackend) MetricName() string {
+	return f.backends[0].MetricName()
+}
+
+// GetMetricValue reads the endpoints in failover order until one answers
+func (f *failoverRedisBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	f.mu.Lock()
+	start := 0
+	if f.sticky {
+		start = f.current
+	}
+	previous := f.current
+	f.mu.Unlock()
+
+	var errs []string
+	for i := range f.backends {
+		index := (start + i) % len(f.backends)
+		backend := f.backends[index]
+
+		value, err := backend.GetMetricValue(ctx)
+		if err == nil {
+			if index != previous {
+				log.Printf("Redis failover from %s to %s", f.backends[previous].address, backend.address)
+			}
+
+			f.mu.Lock()
+			f.current = index
+			f.mu.Unlock()
+
+			return value, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s %s", backend.address, err.Error()))
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return -1, fmt.Errorf("no redis endpoint answered: %s", strings.Join(errs, "; "))
+}
+
+// WarmUp checks one endpoint answers
+func (f *failoverRedisBackend) WarmUp(ctx context.Context) error {
+	var err error
+	for _, backend := range f.backends {
+		if err = backend.WarmUp(ctx); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("no redis endpoint answered %s", err.Error())
+}
+
+// Close closes the backends of all endpoints
+func (f *failoverRedisBackend) Close() error {
+	var err error
+	for _, backend := range f.backends {
+		if closeErr := backend.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,176 @@
+package redis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	goredis "github.com/go-redis/redis"
+)
+
+const defaultRedisFlavor = "redis"
+
+// redisFlavor describes a server speaking the redis protocol. The client
+// always speaks RESP2 which every flavor supports, the flavor selects how the
+// server is identified and which of its quirks the backend works around.
+type redisFlavor struct {
+	// markers are substrings of INFO server identifying the flavor
+	markers []string
+	// tracking is set when the server supports CLIENT TRACKING with
+	// REDIRECT, which the client cache relies on
+	tracking bool
+}
+
+var redisFlavors = map[string]redisFlavor{
+	defaultRedisFlavor: {
+		markers:  []string{"redis_version:"},
+		tracking: true,
+	},
+	// Dragonfly only tracks keys for RESP3 connections and has no REDIRECT
+	"dragonfly": {
+		markers: []string{"dragonfly_version:"},
+	},
+	"keydb": {
+		markers:  []string{"keydb"},
+		tracking: true,
+	},
+	"valkey": {
+		markers:  []string{"valkey_version:", "server_name:valkey"},
+		tracking: true,
+	},
+}
+
+// parseRedisFlavor returns the flavor the metadata declares, which is empty
+// when it declares none
+func parseRedisFlavor(metadata map[string]string) (string, error) {
+	val, ok := metadata["flavor"]
+	if !ok || val == "" {
+		return "", nil
+	}
+
+	flavor := strings.ToLower(val)
+
+	if _, ok := redisFlavors[flavor]; !ok {
+		names := make([]string, 0, len(redisFlavors))
+		for name := range redisFlavors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown redis flavor %s, expected one of %s", flavor, strings.Join(names, ", "))
+	}
+
+	return flavor, nil
+}
+
+// negotiateProtocol pins a new connection to RESP2 with HELLO, so every
+// connection of the pool speaks the protocol the client parses, and keeps
+// the server name of the handshake for detectRedisFlavor. Servers which
+// predate HELLO or reject it only speak RESP2, the connection is used as it
+// is. Only a connection error fails the connection.
+func (r *redisBackend) negotiateProtocol(conn *goredis.Conn) error {
+	result, err := conn.Do("hello", 2).Result()
+	if err != nil {
+		if isConnectionError(err) {
+			return err
+		}
+		return nil
+	}
+
+	name := helloServerName(result)
+
+	r.handshakeMu.Lock()
+	r.handshakeServer = name
+	r.handshakeMu.Unlock()
+
+	return nil
+}
+
+// helloServerName returns the lower cased server field of a HELLO reply
+func helloServerName(reply interface{}) string {
+	fields, ok := reply.([]interface{})
+	if !ok {
+		return ""
+	}
+
+	for i := 0; i+1 < len(fields); i += 2 {
+		if key, ok := fields[i].(string); ok && key == "server" {
+			name, _ := fields[i+1].(string)
+			return strings.ToLower(name)
+		}
+	}
+
+	return ""
+}
+
+// detectRedisFlavor identifies the flavor of the server, first from the name
+// it reported in the HELLO handshake of the connections and otherwise from
+// INFO server
+func (r *redisBackend) detectRedisFlavor(client *goredis.Client) (string, error) {
+	// A command makes sure a connection, and so the handshake, exists
+	if err := client.Ping().Err(); err != nil {
+		return "", err
+	}
+
+	r.handshakeMu.Lock()
+	serverName := r.handshakeServer
+	r.handshakeMu.Unlock()
+
+	if _, ok := redisFlavors[serverName]; ok && serverName != defaultRedisFlavor {
+		return serverName, nil
+	}
+
+	info, err := client.Info("server").Result()
+	if err != nil {
+		return "", err
+	}
+
+	return flavorFromInfo(info)
+}
+
+// flavorFromInfo identifies the flavor from INFO server. Flavors are checked
+// most specific first as most of them also report a redis_version for
+// compatibility.
+func flavorFromInfo(info string) (string, error) {
+	info = strings.ToLower(info)
+
+	names := make([]string, 0, len(redisFlavors))
+	for name := range redisFlavors {
+		if name != defaultRedisFlavor {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	names = append(names, defaultRedisFlavor)
+
+	for _, name := range names {
+		for _, marker := range redisFlavors[name].markers {
+			if strings.Contains(info, marker) {
+				return name, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("server does not identify as any known flavor")
+}
+
+// checkRedisFlavor records the flavor the server identifies as and fails
+// when the metadata declared another one. A server which cannot be
+// identified is only logged, unless it could not be reached.
+func (r *redisBackend) checkRedisFlavor(client *goredis.Client) error {
+	detected, err := r.detectRedisFlavor(client)
+	if err != nil {
+		if isConnectionError(err) {
+			return err
+		}
+		log.Printf("Could not detect the flavor of %s %s", r.address, err.Error())
+		return nil
+	}
+
+	r.detectedFlavor = detected
+	if r.flavor != "" && detected != r.flavor {
+		return fmt.Errorf("%s is declared as flavor %s but identifies as %s", r.address, r.flavor, detected)
+	}
+
+	return nil
+}
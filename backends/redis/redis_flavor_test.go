@@ -0,0 +1,276 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedisServer answers HELLO with helloReply on every connection and
+// counts the connections HELLO was sent on. BLPOP holds its connection until
+// release is closed.
+type fakeRedisServer struct {
+	listener   net.Listener
+	helloReply string
+	release    chan struct{}
+
+	mu     sync.Mutex
+	hellos map[int]int
+}
+
+func newFakeRedisServer(t *testing.T, helloReply string) *fakeRedisServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeRedisServer{
+		listener:   listener,
+		helloReply: helloReply,
+		release:    make(chan struct{}),
+		hellos:     make(map[int]int),
+	}
+
+	go func() {
+		for id := 0; ; id++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(id, conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeRedisServer) serve(id int, conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	for {
+		command, err := readRESP(reader)
+		if err != nil {
+			return
+		}
+
+		args, _ := command.([]interface{})
+		if len(args) == 0 {
+			return
+		}
+		name, _ := args[0].(string)
+
+		var reply string
+		switch strings.ToLower(name) {
+		case "hello":
+			s.mu.Lock()
+			s.hellos[id]++
+			s.mu.Unlock()
+			reply = s.helloReply
+		case "ping":
+			reply = "+PONG\r\n"
+		case "blpop":
+			<-s.release
+			reply = "*-1\r\n"
+		case "info":
+			info := "# Server\r\nredis_version:7.2.0\r\n"
+			reply = fmt.Sprintf("$%d\r\n%s\r\n", len(info), info)
+		default:
+			reply = fmt.Sprintf("-ERR unknown command '%s'\r\n", name)
+		}
+
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// connections returns the number of connections HELLO was sent on and
+// whether it was sent more than once on any of them
+func (s *fakeRedisServer) connections() (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repeated := false
+	for _, count := range s.hellos {
+		repeated = repeated || count > 1
+	}
+	return len(s.hellos), repeated
+}
+
+func (s *fakeRedisServer) close() {
+	close(s.release)
+	s.listener.Close()
+}
+
+func newFlavorTestBackend() *redisBackend {
+	return &redisBackend{
+		pool: redisPoolOptions{size: 2},
+		timeouts: redisTimeouts{
+			dial:  time.Second,
+			read:  5 * time.Second,
+			write: time.Second,
+		},
+	}
+}
+
+func TestNegotiateProtocolEveryConnection(t *testing.T) {
+	server := newFakeRedisServer(t, "*4\r\n$6\r\nserver\r\n$9\r\ndragonfly\r\n$5\r\nproto\r\n:2\r\n")
+	defer server.close()
+
+	r := newFlavorTestBackend()
+	client := r.newClient(server.listener.Addr().String())
+	defer client.Close()
+
+	// BLPOP holds the first connection so the PING needs a second one
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- client.BLPop(0, "queue").Err()
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if count, _ := server.connections(); count == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("first connection was not negotiated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := client.Ping().Err(); err != nil {
+		t.Fatalf("unexpected error %s", err.Error())
+	}
+
+	count, repeated := server.connections()
+	if count != 2 {
+		t.Errorf("got HELLO on %d connections, want 2", count)
+	}
+	if repeated {
+		t.Errorf("HELLO was sent more than once on a connection")
+	}
+
+	detected, err := r.detectRedisFlavor(client)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err.Error())
+	}
+	if detected != "dragonfly" {
+		t.Errorf("got flavor %s, want dragonfly", detected)
+	}
+}
+
+func TestNegotiateProtocolFallback(t *testing.T) {
+	server := newFakeRedisServer(t, "-ERR unknown command 'hello'\r\n")
+	defer server.close()
+
+	r := newFlavorTestBackend()
+	client := r.newClient(server.listener.Addr().String())
+	defer client.Close()
+
+	if err := client.Ping().Err(); err != nil {
+		t.Fatalf("expected the connection to fall back to RESP2, got %s", err.Error())
+	}
+
+	if count, _ := server.connections(); count != 1 {
+		t.Errorf("got HELLO on %d connections, want 1", count)
+	}
+
+	// Without a handshake the flavor is detected from INFO
+	detected, err := r.detectRedisFlavor(client)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err.Error())
+	}
+	if detected != defaultRedisFlavor {
+		t.Errorf("got flavor %s, want %s", detected, defaultRedisFlavor)
+	}
+}
+
+func TestParseRedisFlavor(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		want     string
+		wantErr  bool
+	}{
+		{"undeclared", map[string]string{}, "", false},
+		{"empty", map[string]string{"flavor": ""}, "", false},
+		{"redis", map[string]string{"flavor": "redis"}, "redis", false},
+		{"case insensitive", map[string]string{"flavor": "KeyDB"}, "keydb", false},
+		{"unknown", map[string]string{"flavor": "memcached"}, "", true},
+	}
+
+	for _, test := range tests {
+		got, err := parseRedisFlavor(test.metadata)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want error %t", test.name, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got flavor %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFlavorFromInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    string
+		want    string
+		wantErr bool
+	}{
+		{"redis", "# Server\r\nredis_version:7.2.4\r\nredis_mode:standalone\r\n", "redis", false},
+		{"dragonfly", "# Server\r\nredis_version:6.2.11\r\ndragonfly_version:df-v1.14.0\r\n", "dragonfly", false},
+		{"keydb", "# Server\r\nredis_version:6.3.4\r\nexecutable:/usr/local/bin/keydb-server\r\n", "keydb", false},
+		{"valkey version", "# Server\r\nredis_version:7.2.4\r\nvalkey_version:8.0.1\r\n", "valkey", false},
+		{"valkey server name", "# Server\r\nredis_version:7.2.4\r\nserver_name:valkey\r\n", "valkey", false},
+		{"case insensitive", "# Server\r\nRedis_Version:7.2.4\r\n", "redis", false},
+		{"unknown", "# Server\r\nuptime_in_seconds:10\r\n", "", true},
+		{"empty", "", "", true},
+	}
+
+	for _, test := range tests {
+		got, err := flavorFromInfo(test.info)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want error %t", test.name, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got flavor %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCheckRedisFlavor(t *testing.T) {
+	server := newFakeRedisServer(t, "*2\r\n$6\r\nserver\r\n$9\r\ndragonfly\r\n")
+	defer server.close()
+
+	tests := []struct {
+		flavor  string
+		wantErr bool
+	}{
+		{"", false},
+		{"dragonfly", false},
+		{"keydb", true},
+	}
+
+	for _, test := range tests {
+		r := newFlavorTestBackend()
+		r.flavor = test.flavor
+		client := r.newClient(server.listener.Addr().String())
+
+		err := r.checkRedisFlavor(client)
+		client.Close()
+
+		if (err != nil) != test.wantErr {
+			t.Errorf("flavor %q: got error %v, want error %t", test.flavor, err, test.wantErr)
+		}
+		if r.detectedFlavor != "dragonfly" {
+			t.Errorf("flavor %q: got detected flavor %q, want dragonfly", test.flavor, r.detectedFlavor)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+)
+
+const (
+	stalenessPolicyError = "error"
+	stalenessPolicyHold  = "hold"
+)
+
+// heartbeatCheck guards a redis backend against stale producer data. The
+// producer refreshes heartbeatKey, either writing the current unix time in
+// seconds or setting any value with an expiry. When the timestamp is older
+// than maxStalenessSeconds, or the key is missing, the metric is considered
+// unreliable. The default stalenessPolicy error fails the poll so the
+// ScaledObject's fallback applies, hold reports the last fresh value instead.
+type heartbeatCheck struct {
+	key          string
+	maxStaleness time.Duration
+	policy       string
+
+	mu        sync.Mutex
+	lastFresh int64
+	hasFresh  bool
+}
+
+func parseHeartbeatCheck(metadata map[string]string) (*heartbeatCheck, error) {
+	key, ok := metadata["heartbeatKey"]
+	if !ok || key == "" {
+		return nil, nil
+	}
+
+	val, ok := metadata["maxStalenessSeconds"]
+	if !ok || val == "" {
+		return nil, fmt.Errorf("heartbeatKey requires maxStalenessSeconds")
+	}
+
+	seconds, err := strconv.Atoi(val)
+	if err != nil {
+		return nil, fmt.Errorf("Max staleness parsing error %s", err.Error())
+	}
+
+	if seconds <= 0 {
+		return nil, fmt.Errorf("max staleness must be positive")
+	}
+
+	check := heartbeatCheck{
+		key:          key,
+		maxStaleness: time.Duration(seconds) * time.Second,
+		policy:       stalenessPolicyError,
+	}
+
+	if val, ok := metadata["stalenessPolicy"]; ok && val != "" {
+		if val != stalenessPolicyError && val != stalenessPolicyHold {
+			return nil, fmt.Errorf("stalenessPolicy must be %s or %s", stalenessPolicyError, stalenessPolicyHold)
+		}
+		check.policy = val
+	}
+
+	return &check, nil
+}
+
+// stale reads the heartbeat and returns an error describing why the data is
+// stale, or nil when it is fresh
+func (h *heartbeatCheck) stale(client *goredis.Client) error {
+	raw, err := client.Get(h.key).Result()
+	if err == goredis.Nil {
+		return fmt.Errorf("heartbeat key %s is missing", h.key)
+	}
+	if err != nil {
+		return err
+	}
+
+	seconds, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		// Not a timestamp, the producer relies on the key expiring
+		return nil
+	}
+
+	age := time.Since(time.Unix(int64(seconds), 0))
+	if age > h.maxStaleness {
+		return fmt.Errorf("heartbeat key %s is %s old, more than %s", h.key, age.Round(time.Second), h.maxStaleness)
+	}
+
+	return nil
+}
+
+// guard wraps a metric read with the heartbeat check and the staleness policy
+func (h *heartbeatCheck) guard(client *goredis.Client, read func() (int64, error)) (int64, error) {
+	if staleErr := h.stale(client); staleErr != nil {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if h.policy == stalenessPolicyHold && h.hasFresh {
+			return h.lastFresh, nil
+		}
+		return -1, fmt.Errorf("metric is unreliable, %s", staleErr.Error())
+	}
+
+	value, err := read()
+	if err != nil {
+		return -1, err
+	}
+
+	h.mu.Lock()
+	h.lastFresh, h.hasFresh = value, true
+	h.mu.Unlock()
+
+	return value, nil
+}
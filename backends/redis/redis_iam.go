@@ -0,0 +1,210 @@
+package redis
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/patnaikshekhar/keda_external_scaler/backends/awsauth"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const (
+	// ElastiCache accepts a token for 15 minutes, it is only needed while
+	// a connection authenticates so it is renewed a while before
+	iamTokenExpiry  = 15 * time.Minute
+	iamTokenRenewal = 10 * time.Minute
+
+	iamServiceName = "elasticache"
+
+	// Endpoints of ElastiCache replication groups end in this suffix, in the
+	// China regions followed by .cn
+	elastiCacheHostSuffix = ".cache.amazonaws.com"
+)
+
+// iamAuthToken authenticates as an ElastiCache user with IAM. The password
+// is a SigV4 presigned connect request for the replication group, generated
+// from the scaler's AWS credentials, which saves managing AUTH passwords.
+// ElastiCache requires TLS for IAM authentication.
+type iamAuthToken struct {
+	userID           string
+	replicationGroup string
+	region           string
+	provider         *awsauth.Provider
+
+	mu          sync.Mutex
+	token       string
+	generatedAt time.Time
+}
+
+// parseIAMAuthToken reads iamAuth, iamUserId, iamReplicationGroupId and
+// awsRegion. It returns nil when IAM authentication is not enabled. As the
+// token is signed with the scaler's own AWS role, the replication group must
+// be listed in the elastiCacheIAM server config and address must be an
+// endpoint of that group.
+func parseIAMAuthToken(cfg *config.Config, metadata map[string]string, tlsEnabled bool) (*iamAuthToken, error) {
+	val, ok := metadata["iamAuth"]
+	if !ok || val == "" {
+		return nil, nil
+	}
+
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		return nil, fmt.Errorf("IAM auth parsing error %s", err.Error())
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+
+	if !tlsEnabled {
+		return nil, fmt.Errorf("iamAuth requires enableTLS")
+	}
+
+	if password, ok := metadata["password"]; ok && password != "" {
+		return nil, fmt.Errorf("password and iamAuth are mutually exclusive")
+	}
+
+	token := &iamAuthToken{
+		userID:           metadata["iamUserId"],
+		replicationGroup: metadata["iamReplicationGroupId"],
+		region:           awsauth.Region(metadata["awsRegion"]),
+	}
+
+	if token.userID == "" || token.replicationGroup == "" {
+		return nil, fmt.Errorf("iamAuth requires iamUserId and iamReplicationGroupId")
+	}
+
+	if token.region == "" {
+		return nil, fmt.Errorf("iamAuth requires awsRegion")
+	}
+
+	if err := awsauth.ValidateRegion(token.region); err != nil {
+		return nil, err
+	}
+
+	allowed := false
+	for _, group := range cfg.ElastiCacheIAM.ReplicationGroups {
+		if group == token.replicationGroup {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed {
+		return nil, fmt.Errorf("iamAuth is not allowed for replication group %s, it is not in the elastiCacheIAM server config", token.replicationGroup)
+	}
+
+	if !isElastiCacheEndpoint(metadata["address"], token.replicationGroup) {
+		return nil, fmt.Errorf("iamAuth requires the address to be an endpoint of replication group %s", token.replicationGroup)
+	}
+	token.provider = awsauth.NewProvider(token.region)
+
+	if err := token.refresh(); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// isElastiCacheEndpoint reports whether address is an endpoint of the
+// replication group, such as master.group.abc123.use1.cache.amazonaws.com,
+// group.abc123.ng.0001.use1.cache.amazonaws.com or, for serverless caches,
+// group-abc123.serverless.use1.cache.amazonaws.com
+func isElastiCacheEndpoint(address string, replicationGroup string) bool {
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(host, "."), ".cn"))
+	group := strings.ToLower(replicationGroup)
+
+	if !strings.HasSuffix(host, elastiCacheHostSuffix) {
+		return false
+	}
+
+	labels := strings.Split(strings.TrimSuffix(host, elastiCacheHostSuffix), ".")
+	switch {
+	case labels[0] == group:
+		return true
+	case len(labels) > 1 && labels[1] == group:
+		return labels[0] == "master" || labels[0] == "replica" || labels[0] == "clustercfg"
+	case len(labels) > 1 && labels[1] == "serverless":
+		return strings.HasPrefix(labels[0], group+"-")
+	}
+	return false
+}
+
+// credentials returns the user id and a token, generating a new token when
+// the current one is about to expire
+func (t *iamAuthToken) credentials() (string, string) {
+	t.mu.Lock()
+	stale := time.Since(t.generatedAt) > iamTokenRenewal
+	t.mu.Unlock()
+
+	if stale {
+		if err := t.refresh(); err != nil {
+			log.Printf("Could not renew IAM auth token %s", err.Error())
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.userID, t.token
+}
+
+// refresh generates a new token, the provider renews the AWS credentials
+// when they are about to expire
+func (t *iamAuthToken) refresh() error {
+	creds, err := t.provider.Get()
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.generatedAt = time.Now()
+	t.token = presignConnect(creds, t.replicationGroup, t.userID, t.region, t.generatedAt)
+	return nil
+}
+
+// presignConnect returns the SigV4 presigned connect request ElastiCache
+// accepts as IAM auth token, without the scheme
+func presignConnect(creds *awsauth.Credentials, replicationGroup string, userID string, region string, now time.Time) string {
+	params := url.Values{
+		"Action":              {"connect"},
+		"User":                {userID},
+		"X-Amz-Algorithm":     {awsauth.SigningAlgorithm},
+		"X-Amz-Credential":    {creds.AccessKeyID + "/" + awsauth.Scope(now, region, iamServiceName)},
+		"X-Amz-Date":          {now.UTC().Format("20060102T150405Z")},
+		"X-Amz-Expires":       {strconv.Itoa(int(iamTokenExpiry / time.Second))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	if creds.SessionToken != "" {
+		params.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	query := awsauth.CanonicalQuery(params)
+
+	emptyPayload := sha256.Sum256(nil)
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		"/",
+		query,
+		"host:" + replicationGroup + "\n",
+		"host",
+		hex.EncodeToString(emptyPayload[:]),
+	}, "\n")
+
+	signature := awsauth.Signature(creds, canonicalRequest, now, region, iamServiceName)
+	return replicationGroup + "/?" + query + "&X-Amz-Signature=" + signature
+}
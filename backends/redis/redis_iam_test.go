@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+func TestIsElastiCacheEndpoint(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"master.orders.abc123.use1.cache.amazonaws.com:6379", true},
+		{"replica.orders.abc123.use1.cache.amazonaws.com:6379", true},
+		{"clustercfg.orders.abc123.use1.cache.amazonaws.com:6379", true},
+		{"orders.abc123.ng.0001.use1.cache.amazonaws.com:6379", true},
+		{"orders-abc123.serverless.use1.cache.amazonaws.com:6379", true},
+		{"master.orders.abc123.cnn1.cache.amazonaws.com.cn:6379", true},
+		{"ORDERS.abc123.ng.0001.use1.cache.amazonaws.com", true},
+		{"master.billing.abc123.use1.cache.amazonaws.com:6379", false},
+		{"evil.orders.abc123.use1.cache.amazonaws.com:6379", false},
+		{"ordersx-abc123.serverless.use1.cache.amazonaws.com:6379", false},
+		{"orders.attacker.example.com:6379", false},
+		{"orders.cache.amazonaws.com.attacker.example.com:6379", false},
+		{"10.0.0.1:6379", false},
+	}
+
+	for _, test := range tests {
+		if got := isElastiCacheEndpoint(test.address, "orders"); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.address, got, test.want)
+		}
+	}
+}
+
+func TestParseIAMAuthTokenRejects(t *testing.T) {
+	cfg := &config.Config{
+		ElastiCacheIAM: config.ElastiCacheIAMConfig{ReplicationGroups: []string{"orders"}},
+	}
+	valid := map[string]string{
+		"address":               "master.orders.abc123.use1.cache.amazonaws.com:6379",
+		"iamAuth":               "true",
+		"iamUserId":             "scaler",
+		"iamReplicationGroupId": "orders",
+		"awsRegion":             "us-east-1",
+	}
+
+	tests := []struct {
+		name string
+		cfg  *config.Config
+		key  string
+		val  string
+	}{
+		{"not configured", &config.Config{}, "", ""},
+		{"group not listed", cfg, "iamReplicationGroupId", "billing"},
+		{"invalid region", cfg, "awsRegion", "attacker.example.com/"},
+		{"region without number", cfg, "awsRegion", "us-east"},
+		{"foreign address", cfg, "address", "redis.attacker.example.com:6379"},
+		{"missing user", cfg, "iamUserId", ""},
+	}
+
+	for _, test := range tests {
+		metadata := make(map[string]string, len(valid))
+		for key, val := range valid {
+			metadata[key] = val
+		}
+		if test.key != "" {
+			metadata[test.key] = test.val
+		}
+
+		if _, err := parseIAMAuthToken(test.cfg, metadata, true); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+
+	if _, err := parseIAMAuthToken(cfg, valid, false); err == nil {
+		t.Errorf("expected an error without TLS")
+	}
+}
@@ -0,0 +1,149 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	goredis "github.com/go-redis/redis"
+)
+
+const (
+	infoMetricName = "RedisInfoValue"
+
+	// Derived from the slaveN lines a primary reports for its replicas
+	infoReplicaLagBytes   = "max_replica_lag_bytes"
+	infoReplicaLagSeconds = "max_replica_lag_seconds"
+)
+
+// infoQuery reads a numeric field of INFO, such as connected_clients or
+// blocked_clients, from infoSection when given. Besides the fields redis
+// reports, max_replica_lag_bytes and max_replica_lag_seconds give the lag of
+// the slowest replica of a primary. It is parsed alongside the module
+// queries as it reads the metric through a single server command too.
+type infoQuery struct {
+	field   string
+	section string
+}
+
+func parseInfoQuery(metadata map[string]string) (redisModuleQuery, error) {
+	field, ok := metadata["infoField"]
+	if !ok || field == "" {
+		return nil, nil
+	}
+
+	return &infoQuery{field: field, section: metadata["infoSection"]}, nil
+}
+
+func (i *infoQuery) metricName() string {
+	return infoMetricName
+}
+
+func (i *infoQuery) command() string {
+	return "info"
+}
+
+func (i *infoQuery) query(client *goredis.Client) (float64, error) {
+	section := i.section
+	if section == "" && (i.field == infoReplicaLagBytes || i.field == infoReplicaLagSeconds) {
+		section = "replication"
+	}
+
+	var raw string
+	var err error
+	if section != "" {
+		raw, err = client.Info(section).Result()
+	} else {
+		raw, err = client.Info().Result()
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	fields := parseInfo(raw)
+
+	switch i.field {
+	case infoReplicaLagBytes:
+		return replicaLag(fields, func(replica map[string]string) (float64, bool) {
+			offset, err := strconv.ParseFloat(replica["offset"], 64)
+			if err != nil {
+				return 0, false
+			}
+			primary, err := strconv.ParseFloat(fields["master_repl_offset"], 64)
+			if err != nil {
+				return 0, false
+			}
+			return primary - offset, true
+		}), nil
+	case infoReplicaLagSeconds:
+		return replicaLag(fields, func(replica map[string]string) (float64, bool) {
+			lag, err := strconv.ParseFloat(replica["lag"], 64)
+			return lag, err == nil
+		}), nil
+	}
+
+	val, ok := fields[i.field]
+	if !ok {
+		return -1, fmt.Errorf("INFO has no field %s", i.field)
+	}
+
+	value, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return -1, fmt.Errorf("INFO field %s is not a number %s", i.field, val)
+	}
+
+	return value, nil
+}
+
+// parseInfo reads the field:value lines of an INFO reply
+func parseInfo(raw string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 {
+			fields[parts[0]] = parts[1]
+		}
+	}
+	return fields
+}
+
+// replicaLag returns the highest lag of the replicas, zero when there are
+// none
+func replicaLag(fields map[string]string, lag func(map[string]string) (float64, bool)) float64 {
+	max := 0.0
+	for _, replica := range replicaFields(fields) {
+		if value, ok := lag(replica); ok && value > max {
+			max = value
+		}
+	}
+	return max
+}
+
+// replicaFields returns the replicas listed as
+// slaveN:ip=...,port=...,state=online,offset=...,lag=... fields
+func replicaFields(fields map[string]string) []map[string]string {
+	var replicas []map[string]string
+	for key, val := range fields {
+		if !strings.HasPrefix(key, "slave") {
+			continue
+		}
+		if _, err := strconv.Atoi(strings.TrimPrefix(key, "slave")); err != nil {
+			continue
+		}
+
+		replica := make(map[string]string)
+		for _, pair := range strings.Split(val, ",") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) == 2 {
+				replica[parts[0]] = parts[1]
+			}
+		}
+		replicas = append(replicas, replica)
+	}
+	return replicas
+}
@@ -0,0 +1,124 @@
+package redis
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	goredis "github.com/go-redis/redis"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+// redisKeepalive PINGs the pooled connections of a redis backend every
+// interval. A PING failing on a broken connection, such as one to a
+// restarted server or one dropped by a firewall while idle, removes it from
+// the pool, and a replacement is dialed right away. The first poll after an
+// idle period then finds working connections instead of failing.
+type redisKeepalive struct {
+	interval time.Duration
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// parseRedisKeepalive reads keepaliveInterval as a duration like "30s",
+// defaulting to the server wide redisPool keepaliveInterval. It returns nil
+// when the interval is zero.
+func parseRedisKeepalive(cfg *config.Config, metadata map[string]string) (*redisKeepalive, error) {
+	interval := cfg.RedisPool.KeepaliveInterval.Duration
+
+	if val, ok := metadata["keepaliveInterval"]; ok && val != "" {
+		parsed, err := time.ParseDuration(val)
+		if err != nil {
+			return nil, fmt.Errorf("Keepalive interval parsing error %s", err.Error())
+		}
+
+		if parsed < 0 {
+			return nil, fmt.Errorf("keepalive interval must not be negative")
+		}
+
+		interval = parsed
+	}
+
+	if interval == 0 {
+		return nil, nil
+	}
+
+	return &redisKeepalive{
+		interval: interval,
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+// run checks the connections of the backend's clients until the backend is
+// closed. The client is looked up every time as it is replaced when the node
+// was demoted.
+func (k *redisKeepalive) run(r *redisBackend) {
+	ticker := time.NewTicker(k.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-k.stop:
+			return
+		case <-ticker.C:
+		}
+
+		checkPooledConnections(r.getClient())
+		if r.primaryClient != nil {
+			checkPooledConnections(r.primaryClient)
+		}
+	}
+}
+
+// close stops the checks
+func (k *redisKeepalive) close() {
+	k.closeOnce.Do(func() {
+		close(k.stop)
+	})
+}
+
+// checkPooledConnections sends as many concurrent PINGs as the pool has idle
+// connections, so each of them is taken from the pool and checked. The
+// client removes the connections which fail. When any did, one more PING
+// dials a replacement.
+func checkPooledConnections(client *goredis.Client) {
+	idle := int(client.PoolStats().IdleConns)
+	if idle == 0 {
+		idle = 1
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, idle)
+	for i := 0; i < idle; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- client.Ping().Err()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	broken := 0
+	var lastErr error
+	for err := range errs {
+		if err != nil {
+			broken++
+			lastErr = err
+		}
+	}
+
+	if broken == 0 {
+		return
+	}
+
+	addr := client.Options().Addr
+	if err := client.Ping().Err(); err != nil {
+		log.Printf("Redis %s unreachable, %d pooled connections dropped %s", addr, broken, err.Error())
+		return
+	}
+
+	log.Printf("Redis %s reconnected, %d broken pooled connections dropped after %s", addr, broken, lastErr.Error())
+}
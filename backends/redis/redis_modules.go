@@ -0,0 +1,408 @@
+package redis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+)
+
+const (
+	timeSeriesMetricName           = "RedisTimeSeriesValue"
+	jsonMetricName                 = "RedisJSONValue"
+	defaultJSONPath                = "$"
+	countMinSketchMetricName       = "RedisCountMinSketchCount"
+	topKMetricName                 = "RedisTopKCount"
+	searchMetricName               = "RedisSearchCount"
+	defaultSearchQuery             = "*"
+	defaultTimeSeriesWindowSeconds = 60
+)
+
+// redisModuleQuery reads the metric of a redis backend through a command of
+// a redis module
+type redisModuleQuery interface {
+	metricName() string
+	// command is the module command, probed for at registration
+	command() string
+	// query returns the value read, which is rounded by the backend
+	query(client *goredis.Client) (float64, error)
+}
+
+// redisModuleParsers return a query when their metadata keys are set
+var redisModuleParsers = []func(metadata map[string]string) (redisModuleQuery, error){
+	parseTimeSeriesQuery,
+	parseJSONQuery,
+	parseCountMinSketchQuery,
+	parseTopKQuery,
+	parseSearchQuery,
+	parseInfoQuery,
+}
+
+func parseRedisModuleQuery(metadata map[string]string) (redisModuleQuery, error) {
+	var found redisModuleQuery
+	for _, parse := range redisModuleParsers {
+		query, err := parse(metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		if query == nil {
+			continue
+		}
+
+		if found != nil {
+			return nil, fmt.Errorf("only one redis module query can be configured")
+		}
+		found = query
+	}
+
+	return found, nil
+}
+
+// timeSeriesQuery reads a RedisTimeSeries key. Without tsAggregation the
+// latest sample is used (TS.GET), otherwise the samples of the last
+// tsWindowSeconds are aggregated with TS.RANGE.
+type timeSeriesQuery struct {
+	key         string
+	aggregation string
+	window      time.Duration
+}
+
+var timeSeriesAggregations = map[string]bool{
+	"avg": true, "sum": true, "min": true, "max": true, "range": true,
+	"count": true, "first": true, "last": true, "std.p": true, "std.s": true,
+	"var.p": true, "var.s": true,
+}
+
+func parseTimeSeriesQuery(metadata map[string]string) (redisModuleQuery, error) {
+	key, ok := metadata["tsKey"]
+	if !ok || key == "" {
+		return nil, nil
+	}
+
+	query := timeSeriesQuery{key: key}
+
+	if val, ok := metadata["tsAggregation"]; ok && val != "" {
+		query.aggregation = strings.ToLower(val)
+		if !timeSeriesAggregations[query.aggregation] {
+			return nil, fmt.Errorf("unknown time series aggregation %s", val)
+		}
+	}
+
+	window := defaultTimeSeriesWindowSeconds
+	if val, ok := metadata["tsWindowSeconds"]; ok && val != "" {
+		var err error
+		window, err = strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Time series window parsing error %s", err.Error())
+		}
+
+		if window <= 0 {
+			return nil, fmt.Errorf("time series window must be positive")
+		}
+	}
+	query.window = time.Duration(window) * time.Second
+
+	return &query, nil
+}
+
+func (t *timeSeriesQuery) metricName() string {
+	return timeSeriesMetricName
+}
+
+func (t *timeSeriesQuery) command() string {
+	if t.aggregation != "" {
+		return "ts.range"
+	}
+	return "ts.get"
+}
+
+func (t *timeSeriesQuery) query(client *goredis.Client) (float64, error) {
+	if t.aggregation == "" {
+		result, err := client.Do("ts.get", t.key).Result()
+		if err != nil {
+			return -1, err
+		}
+
+		return timeSeriesSampleValue(result)
+	}
+
+	now := time.Now()
+	from := now.Add(-t.window)
+	bucket := t.window.Nanoseconds() / int64(time.Millisecond)
+
+	result, err := client.Do("ts.range", t.key,
+		from.UnixNano()/int64(time.Millisecond), now.UnixNano()/int64(time.Millisecond),
+		"aggregation", t.aggregation, bucket).Result()
+	if err != nil {
+		return -1, err
+	}
+
+	samples, ok := result.([]interface{})
+	if !ok {
+		return -1, fmt.Errorf("unexpected TS.RANGE reply %T", result)
+	}
+
+	if len(samples) == 0 {
+		return 0, nil
+	}
+
+	// The window may straddle two buckets, the last one is the most recent
+	return timeSeriesSampleValue(samples[len(samples)-1])
+}
+
+// timeSeriesSampleValue reads the value of a [timestamp, value] sample
+func timeSeriesSampleValue(reply interface{}) (float64, error) {
+	sample, ok := reply.([]interface{})
+	if !ok {
+		return -1, fmt.Errorf("unexpected time series sample %T", reply)
+	}
+
+	if len(sample) == 0 {
+		return 0, nil
+	}
+
+	if len(sample) != 2 {
+		return -1, fmt.Errorf("time series sample has %d fields", len(sample))
+	}
+
+	raw, ok := sample[1].(string)
+	if !ok {
+		return -1, fmt.Errorf("unexpected time series value %T", sample[1])
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return -1, fmt.Errorf("Time series value parsing error %s", err.Error())
+	}
+
+	return value, nil
+}
+
+// jsonQuery reads a numeric field of a RedisJSON document with JSON.GET.
+// jsonPath may use the JSONPath ($.field) or the legacy (.field) syntax, a
+// JSONPath matching several values uses the first one.
+type jsonQuery struct {
+	key  string
+	path string
+}
+
+func parseJSONQuery(metadata map[string]string) (redisModuleQuery, error) {
+	key, ok := metadata["jsonKey"]
+	if !ok || key == "" {
+		return nil, nil
+	}
+
+	query := jsonQuery{key: key, path: defaultJSONPath}
+	if val, ok := metadata["jsonPath"]; ok && val != "" {
+		query.path = val
+	}
+
+	return &query, nil
+}
+
+func (j *jsonQuery) metricName() string {
+	return jsonMetricName
+}
+
+func (j *jsonQuery) command() string {
+	return "json.get"
+}
+
+func (j *jsonQuery) query(client *goredis.Client) (float64, error) {
+	raw, err := client.Do("json.get", j.key, j.path).String()
+	if err == goredis.Nil {
+		return -1, fmt.Errorf("json key %s does not exist", j.key)
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return -1, fmt.Errorf("JSON value parsing error %s", err.Error())
+	}
+
+	if values, ok := value.([]interface{}); ok {
+		if len(values) == 0 {
+			return -1, fmt.Errorf("json path %s matched nothing in %s", j.path, j.key)
+		}
+		value = values[0]
+	}
+
+	number, ok := value.(float64)
+	if !ok {
+		return -1, fmt.Errorf("json path %s in %s is not a number", j.path, j.key)
+	}
+
+	return number, nil
+}
+
+// countMinSketchQuery sums the estimated counts of cmsItems in a RedisBloom
+// count-min sketch with CMS.QUERY
+type countMinSketchQuery struct {
+	key   string
+	items []string
+}
+
+func parseCountMinSketchQuery(metadata map[string]string) (redisModuleQuery, error) {
+	key, ok := metadata["cmsKey"]
+	if !ok || key == "" {
+		return nil, nil
+	}
+
+	query := countMinSketchQuery{key: key, items: backends.SplitItems(metadata["cmsItems"])}
+	if len(query.items) == 0 {
+		return nil, fmt.Errorf("cmsKey needs at least one item in cmsItems")
+	}
+
+	return &query, nil
+}
+
+func (c *countMinSketchQuery) metricName() string {
+	return countMinSketchMetricName
+}
+
+func (c *countMinSketchQuery) command() string {
+	return "cms.query"
+}
+
+func (c *countMinSketchQuery) query(client *goredis.Client) (float64, error) {
+	args := []interface{}{"cms.query", c.key}
+	for _, item := range c.items {
+		args = append(args, item)
+	}
+
+	result, err := client.Do(args...).Result()
+	if err != nil {
+		return -1, err
+	}
+
+	counts, ok := result.([]interface{})
+	if !ok {
+		return -1, fmt.Errorf("unexpected CMS.QUERY reply %T", result)
+	}
+
+	var total int64
+	for _, count := range counts {
+		value, ok := count.(int64)
+		if !ok {
+			return -1, fmt.Errorf("unexpected CMS.QUERY count %T", count)
+		}
+		total += value
+	}
+
+	return float64(total), nil
+}
+
+// topKQuery sums the counts of a RedisBloom top-k with TOPK.LIST WITHCOUNT,
+// either of every item currently in the top-k or only of topkItems
+type topKQuery struct {
+	key   string
+	items map[string]bool
+}
+
+func parseTopKQuery(metadata map[string]string) (redisModuleQuery, error) {
+	key, ok := metadata["topkKey"]
+	if !ok || key == "" {
+		return nil, nil
+	}
+
+	query := topKQuery{key: key}
+	if items := backends.SplitItems(metadata["topkItems"]); len(items) > 0 {
+		query.items = make(map[string]bool)
+		for _, item := range items {
+			query.items[item] = true
+		}
+	}
+
+	return &query, nil
+}
+
+func (t *topKQuery) metricName() string {
+	return topKMetricName
+}
+
+func (t *topKQuery) command() string {
+	return "topk.list"
+}
+
+func (t *topKQuery) query(client *goredis.Client) (float64, error) {
+	result, err := client.Do("topk.list", t.key, "withcount").Result()
+	if err != nil {
+		return -1, err
+	}
+
+	entries, ok := result.([]interface{})
+	if !ok || len(entries)%2 != 0 {
+		return -1, fmt.Errorf("unexpected TOPK.LIST reply %T", result)
+	}
+
+	var total int64
+	for i := 0; i < len(entries); i += 2 {
+		item, _ := entries[i].(string)
+		if t.items != nil && !t.items[item] {
+			continue
+		}
+
+		count, ok := entries[i+1].(int64)
+		if !ok {
+			return -1, fmt.Errorf("unexpected TOPK.LIST count %T", entries[i+1])
+		}
+		total += count
+	}
+
+	return float64(total), nil
+}
+
+// searchQuery counts the documents of a RediSearch index matching
+// searchQuery with FT.SEARCH ... LIMIT 0 0, which returns only the total
+type searchQuery struct {
+	index  string
+	filter string
+}
+
+func parseSearchQuery(metadata map[string]string) (redisModuleQuery, error) {
+	index, ok := metadata["searchIndex"]
+	if !ok || index == "" {
+		return nil, nil
+	}
+
+	query := searchQuery{index: index, filter: defaultSearchQuery}
+	if val, ok := metadata["searchQuery"]; ok && val != "" {
+		query.filter = val
+	}
+
+	return &query, nil
+}
+
+func (s *searchQuery) metricName() string {
+	return searchMetricName
+}
+
+func (s *searchQuery) command() string {
+	return "ft.search"
+}
+
+func (s *searchQuery) query(client *goredis.Client) (float64, error) {
+	result, err := client.Do("ft.search", s.index, s.filter, "limit", 0, 0).Result()
+	if err != nil {
+		return -1, err
+	}
+
+	reply, ok := result.([]interface{})
+	if !ok || len(reply) == 0 {
+		return -1, fmt.Errorf("unexpected FT.SEARCH reply %T", result)
+	}
+
+	total, ok := reply[0].(int64)
+	if !ok {
+		return -1, fmt.Errorf("unexpected FT.SEARCH total %T", reply[0])
+	}
+
+	return float64(total), nil
+}
@@ -0,0 +1,138 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	addressAggregationSum = "sum"
+	addressAggregationMax = "max"
+)
+
+// multiRedisBackend reads the same metric from several redis endpoints, for
+// example one per region of a globally sharded queue, and reports their sum
+// or maximum. An error on any endpoint fails the poll, as a partial sum would
+// under-report the backlog.
+type multiRedisBackend struct {
+	backends    []*redisBackend
+	aggregation string
+}
+
+// parseMultiRedisMetadata creates a redis backend for every endpoint of the
+// comma separated addresses metadata, the remaining metadata applies to all
+// of them
+func parseMultiRedisMetadata(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	addresses := backends.SplitItems(metadata["addresses"])
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no addresses given")
+	}
+
+	if val, ok := metadata["address"]; ok && val != "" {
+		return nil, fmt.Errorf("address and addresses are mutually exclusive")
+	}
+
+	multi := multiRedisBackend{aggregation: addressAggregationSum}
+	if val, ok := metadata["addressAggregation"]; ok && val != "" {
+		if val != addressAggregationSum && val != addressAggregationMax {
+			return nil, fmt.Errorf("addressAggregation must be %s or %s", addressAggregationSum, addressAggregationMax)
+		}
+		multi.aggregation = val
+	}
+
+	for _, address := range addresses {
+		endpointMetadata := make(map[string]string, len(metadata))
+		for key, value := range metadata {
+			endpointMetadata[key] = value
+		}
+		delete(endpointMetadata, "addresses")
+		endpointMetadata["address"] = address
+
+		backend, err := NewBackend(cfg, ref, endpointMetadata)
+		if err != nil {
+			multi.Close()
+			if s, ok := status.FromError(err); ok {
+				return nil, status.Errorf(s.Code(), "redis %s %s", address, s.Message())
+			}
+			return nil, fmt.Errorf("redis %s %s", address, err.Error())
+		}
+		multi.backends = append(multi.backends, backend.(*redisBackend))
+	}
+
+	return &multi, nil
+}
+
+// Endpoint returns the redis addresses
+func (m *multiRedisBackend) Endpoint() string {
+	addresses := make([]string, len(m.backends))
+	for i, backend := range m.backends {
+		addresses[i] = backend.address
+	}
+	return strings.Join(addresses, ",")
+}
+
+// MetricName returns the metric name shared by all endpoints
+func (m *multiRedisBackend) MetricName() string {
+	return m.backends[0].MetricName()
+}
+
+// GetMetricValue reads every endpoint concurrently and aggregates the values
+func (m *multiRedisBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	values := make([]int64, len(m.backends))
+	errs := make([]error, len(m.backends))
+
+	var wg sync.WaitGroup
+	for i, backend := range m.backends {
+		wg.Add(1)
+		go func(i int, backend *redisBackend) {
+			defer wg.Done()
+			values[i], errs[i] = backend.GetMetricValue(ctx)
+		}(i, backend)
+	}
+	wg.Wait()
+
+	var result int64
+	for i, value := range values {
+		if errs[i] != nil {
+			return -1, fmt.Errorf("redis %s %s", m.backends[i].address, errs[i].Error())
+		}
+
+		if m.aggregation == addressAggregationMax {
+			if i == 0 || value > result {
+				result = value
+			}
+		} else {
+			result += value
+		}
+	}
+
+	return result, nil
+}
+
+// WarmUp checks every endpoint answers
+func (m *multiRedisBackend) WarmUp(ctx context.Context) error {
+	for _, backend := range m.backends {
+		if err := backend.WarmUp(ctx); err != nil {
+			return fmt.Errorf("redis %s %s", backend.address, err.Error())
+		}
+	}
+	return nil
+}
+
+// Close closes the backends of all endpoints
+func (m *multiRedisBackend) Close() error {
+	var err error
+	for _, backend := range m.backends {
+		if closeErr := backend.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
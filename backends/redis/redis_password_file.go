@@ -0,0 +1,72 @@
+package redis
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+// passwordFile is a password read from a file below the secret directory,
+// such as a key of a mounted Secret. It is read again when redis rejects it,
+// so rotated secrets are picked up by new connections.
+type passwordFile struct {
+	path string
+
+	mu       sync.Mutex
+	password string
+}
+
+// parsePasswordFile reads passwordFile, a path relative to the secret
+// directory. It returns nil when no file is given.
+func parsePasswordFile(cfg *config.Config, metadata map[string]string) (*passwordFile, error) {
+	val, ok := metadata["passwordFile"]
+	if !ok || val == "" {
+		return nil, nil
+	}
+
+	if password, ok := metadata["password"]; ok && password != "" {
+		return nil, fmt.Errorf("password and passwordFile are mutually exclusive")
+	}
+
+	path, err := secretDirPath(cfg, "passwordFile", val)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &passwordFile{path: path}
+	if err := file.read(); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// read loads the password, without the trailing newline editors leave
+func (p *passwordFile) read() error {
+	data, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("password file not readable %s", err.Error())
+	}
+
+	p.mu.Lock()
+	p.password = strings.TrimRight(string(data), "\r\n")
+	p.mu.Unlock()
+
+	return nil
+}
+
+// credentials returns the password, the username comes from the metadata
+func (p *passwordFile) credentials() (string, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return "", p.password
+}
+
+// refresh reads the file again
+func (p *passwordFile) refresh() error {
+	return p.read()
+}
@@ -0,0 +1,50 @@
+package redis
+
+import (
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	goredis "github.com/go-redis/redis"
+)
+
+// pauseSwitch is an application level kill switch. While pauseKey exists,
+// set by an operator with any value, the backend reports zero so the scaler
+// is inactive and the consumers scale in, without touching Kubernetes.
+type pauseSwitch struct {
+	key string
+
+	mu     sync.Mutex
+	paused bool
+}
+
+func parsePauseSwitch(metadata map[string]string) *pauseSwitch {
+	key, ok := metadata["pauseKey"]
+	if !ok || key == "" {
+		return nil
+	}
+
+	return &pauseSwitch{key: key}
+}
+
+// check reports whether the pause key exists and logs when that changes
+func (p *pauseSwitch) check(client *goredis.Client, address string) (bool, error) {
+	exists, err := client.Exists(p.key).Result()
+	if err != nil {
+		return false, err
+	}
+	paused := exists > 0
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if paused != p.paused {
+		if paused {
+			log.Printf("Pause key %s set on %s, reporting zero", p.key, address)
+		} else {
+			log.Printf("Pause key %s removed from %s, resuming", p.key, address)
+		}
+		p.paused = paused
+	}
+
+	return paused, nil
+}
@@ -0,0 +1,93 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const maxRedisPoolSize = 100
+
+// redisPoolOptions tune the connection pool a redis backend keeps for its
+// whole lifetime. Zero values keep the go-redis defaults.
+type redisPoolOptions struct {
+	size        int
+	minIdle     int
+	idleTimeout time.Duration
+}
+
+// parseRedisPoolOptions reads poolSize, minIdleConns and idleTimeout as a
+// duration like "5m" or idleTimeoutSeconds, defaulting to the server wide
+// redisPool
+func parseRedisPoolOptions(cfg *config.Config, metadata map[string]string) (redisPoolOptions, error) {
+	options := redisPoolOptions{
+		size:        cfg.RedisPool.PoolSize,
+		minIdle:     cfg.RedisPool.MinIdleConns,
+		idleTimeout: cfg.RedisPool.IdleTimeout.Duration,
+	}
+
+	if val, ok := metadata["poolSize"]; ok && val != "" {
+		size, err := strconv.Atoi(val)
+		if err != nil {
+			return options, fmt.Errorf("Pool size parsing error %s", err.Error())
+		}
+
+		if size <= 0 || size > maxRedisPoolSize {
+			return options, fmt.Errorf("pool size must be between 1 and %d", maxRedisPoolSize)
+		}
+
+		options.size = size
+
+		// The server wide minimum must not exceed a smaller pool
+		if options.minIdle > size {
+			options.minIdle = size
+		}
+	}
+
+	if val, ok := metadata["minIdleConns"]; ok && val != "" {
+		minIdle, err := strconv.Atoi(val)
+		if err != nil {
+			return options, fmt.Errorf("Min idle connections parsing error %s", err.Error())
+		}
+
+		if minIdle < 0 || (options.size > 0 && minIdle > options.size) {
+			return options, fmt.Errorf("min idle connections must be between 0 and the pool size")
+		}
+
+		options.minIdle = minIdle
+	}
+
+	if val, ok := metadata["idleTimeout"]; ok && val != "" {
+		if seconds, ok := metadata["idleTimeoutSeconds"]; ok && seconds != "" {
+			return options, fmt.Errorf("idleTimeout and idleTimeoutSeconds are mutually exclusive")
+		}
+
+		idleTimeout, err := time.ParseDuration(val)
+		if err != nil {
+			return options, fmt.Errorf("Idle timeout parsing error %s", err.Error())
+		}
+
+		if idleTimeout <= 0 {
+			return options, fmt.Errorf("idle timeout must be positive")
+		}
+
+		options.idleTimeout = idleTimeout
+	}
+
+	if val, ok := metadata["idleTimeoutSeconds"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return options, fmt.Errorf("Idle timeout parsing error %s", err.Error())
+		}
+
+		if seconds <= 0 {
+			return options, fmt.Errorf("idle timeout must be positive")
+		}
+
+		options.idleTimeout = time.Duration(seconds) * time.Second
+	}
+
+	return options, nil
+}
@@ -0,0 +1,301 @@
+package redis
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const (
+	defaultRedisDialTimeout = 5 * time.Second
+
+	socks5Version      = 5
+	socks5NoAuth       = 0
+	socks5PasswordAuth = 2
+	socks5Connect      = 1
+	socks5IPv4         = 1
+	socks5Domain       = 3
+	socks5IPv6         = 4
+)
+
+var socks5Errors = map[byte]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// redisProxy tunnels the connections of a redis backend through the egress
+// proxy in proxyURL, socks5:// resolving the redis host locally, socks5h://
+// on the proxy, or http:// with CONNECT. Credentials in the URL authenticate
+// with the proxy.
+type redisProxy struct {
+	url *url.URL
+}
+
+// parseRedisProxy reads proxyURL. It returns nil when no proxy is set.
+func parseRedisProxy(cfg *config.Config, metadata map[string]string) (*redisProxy, error) {
+	val, ok := metadata["proxyURL"]
+	if !ok || val == "" {
+		return nil, nil
+	}
+
+	// The URL may hold credentials, errors must not include it
+	proxyURL, err := url.Parse(val)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("Proxy URL parsing error %s", err.Error())
+	}
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h", "http":
+	default:
+		return nil, fmt.Errorf("proxyURL must use socks5, socks5h or http")
+	}
+
+	if proxyURL.Port() == "" {
+		return nil, fmt.Errorf("proxyURL needs a port")
+	}
+
+	if err := cfg.AllowEgress(proxyURL.Host); err != nil {
+		return nil, err
+	}
+
+	return &redisProxy{url: proxyURL}, nil
+}
+
+// dial connects to address through the proxy
+func (p *redisProxy) dial(address string, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", p.url.Host, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if p.url.Scheme == "http" {
+		err = p.connectHTTP(conn, address)
+	} else {
+		err = p.connectSOCKS5(conn, address)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s %s", p.url.Host, err.Error())
+	}
+	conn.SetDeadline(time.Time{})
+
+	return conn, nil
+}
+
+func (p *redisProxy) connectHTTP(conn net.Conn, address string) error {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+
+	if p.url.User != nil {
+		password, _ := p.url.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(p.url.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("CONNECT returned %s", resp.Status)
+	}
+
+	// Redis only speaks once the client did, nothing may follow the response
+	if reader.Buffered() > 0 {
+		return fmt.Errorf("unexpected data after CONNECT")
+	}
+
+	return nil
+}
+
+func (p *redisProxy) connectSOCKS5(conn net.Conn, address string) error {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %s", portString)
+	}
+
+	method := byte(socks5NoAuth)
+	if p.url.User != nil {
+		method = socks5PasswordAuth
+	}
+
+	if _, err := conn.Write([]byte{socks5Version, 1, method}); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+
+	if reply[0] != socks5Version || reply[1] != method {
+		return fmt.Errorf("authentication method not accepted")
+	}
+
+	if method == socks5PasswordAuth {
+		username := p.url.User.Username()
+		password, _ := p.url.User.Password()
+		if len(username) > 255 || len(password) > 255 {
+			return fmt.Errorf("proxy credentials too long")
+		}
+
+		auth := []byte{1, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+
+		if reply[1] != 0 {
+			return fmt.Errorf("authentication failed")
+		}
+	}
+
+	request := []byte{socks5Version, socks5Connect, 0}
+	ip := net.ParseIP(host)
+	if ip == nil && p.url.Scheme == "socks5" {
+		addrs, err := net.LookupIP(host)
+		if err != nil {
+			return err
+		}
+		ip = addrs[0]
+	}
+
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return fmt.Errorf("host name too long")
+		}
+		request = append(request, socks5Domain, byte(len(host)))
+		request = append(request, host...)
+	case ip.To4() != nil:
+		request = append(request, socks5IPv4)
+		request = append(request, ip.To4()...)
+	default:
+		request = append(request, socks5IPv6)
+		request = append(request, ip.To16()...)
+	}
+	request = append(request, byte(port>>8), byte(port))
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	// Reply: version, status, reserved, then the bound address
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+
+	if header[1] != 0 {
+		reason, ok := socks5Errors[header[1]]
+		if !ok {
+			reason = fmt.Sprintf("error %d", header[1])
+		}
+		return fmt.Errorf("CONNECT failed, %s", reason)
+	}
+
+	var skip int
+	switch header[3] {
+	case socks5IPv4:
+		skip = net.IPv4len
+	case socks5IPv6:
+		skip = net.IPv6len
+	case socks5Domain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		skip = int(length[0])
+	default:
+		return fmt.Errorf("unknown address type %d", header[3])
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
+
+// dialRedis connects to address with the backend's proxy or SSH tunnel, TLS
+// and timeout settings
+func (r *redisBackend) dialRedis(address string) (net.Conn, error) {
+	timeout := r.timeouts.dial
+	if timeout == 0 {
+		timeout = defaultRedisDialTimeout
+	}
+
+	var conn net.Conn
+	var err error
+	if r.proxy != nil {
+		conn, err = r.proxy.dial(address, timeout)
+	} else if r.tunnel != nil {
+		conn, err = r.tunnel.dial(address, timeout)
+	} else {
+		conn, err = net.DialTimeout("tcp", address, timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if r.tlsConfig == nil {
+		return conn, nil
+	}
+
+	tlsConfig := r.tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		tlsConfig.ServerName = host
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	tlsConn.SetDeadline(time.Now().Add(timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+
+	return tlsConn, nil
+}
@@ -0,0 +1,157 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	goredis "github.com/go-redis/redis"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+// redisRedirects follows the MOVED and ASK redirections of a redis cluster
+// node. The client has no cluster support, so the node named in a MOVED
+// error is remembered and read from until it redirects again.
+type redisRedirects struct {
+	allowEgress func(address string) error
+
+	mu      sync.Mutex
+	target  string
+	clients map[string]*goredis.Client
+}
+
+func newRedisRedirects(cfg *config.Config) *redisRedirects {
+	return &redisRedirects{
+		allowEgress: cfg.AllowEgress,
+		clients:     make(map[string]*goredis.Client),
+	}
+}
+
+// redirectAddress returns the node a MOVED or ASK error points to and
+// whether it moved for good
+func redirectAddress(err error) (string, bool, bool) {
+	fields := strings.Fields(err.Error())
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return "", false, false
+	}
+	return fields[2], fields[0] == "MOVED", true
+}
+
+// isReadOnlyError reports whether the node was demoted to a replica while
+// the client kept its connections
+func isReadOnlyError(err error) bool {
+	return strings.HasPrefix(err.Error(), "READONLY ")
+}
+
+// route returns the client of the node the key moved to, or client when it
+// did not move
+func (d *redisRedirects) route(client *goredis.Client) *goredis.Client {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.target == "" {
+		return client
+	}
+	return d.clients[d.target]
+}
+
+// follow returns the client of address, remembering it for the following
+// polls when moved is set
+func (d *redisRedirects) follow(r *redisBackend, address string, moved bool) (*goredis.Client, error) {
+	if address == r.address {
+		d.mu.Lock()
+		d.target = ""
+		d.mu.Unlock()
+		return r.getClient(), nil
+	}
+
+	if err := d.allowEgress(address); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	client, ok := d.clients[address]
+	if !ok {
+		client = r.newClient(address)
+		d.clients[address] = client
+	}
+
+	if moved {
+		d.target = address
+	}
+
+	return client, nil
+}
+
+// close closes the clients of the redirection targets
+func (d *redisRedirects) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for address, client := range d.clients {
+		client.Close()
+		delete(d.clients, address)
+	}
+	d.target = ""
+}
+
+// readRedirected reads through client and retries once when the node
+// redirects the key or was demoted to a replica, instead of failing the poll
+func (r *redisBackend) readRedirected(ctx context.Context, client *goredis.Client) (int64, error) {
+	if client == r.getClient() {
+		client = r.redirects.route(client)
+	}
+
+	value, err := r.read(ctx, client)
+	if err == nil || ctx.Err() != nil {
+		return value, err
+	}
+
+	if address, moved, ok := redirectAddress(err); ok {
+		redirected, followErr := r.redirects.follow(r, address, moved)
+		if followErr != nil {
+			return -1, followErr
+		}
+
+		log.Printf("Redis %s redirected to %s, retrying", r.address, address)
+		return r.read(ctx, redirected)
+	}
+
+	if isReadOnlyError(err) {
+		log.Printf("Redis %s was demoted to a replica, reconnecting", r.address)
+		return r.read(ctx, r.resetClient(client))
+	}
+
+	if r.resolver != nil && client == r.getClient() {
+		r.resolver.connectionFailed(err)
+	}
+
+	return value, err
+}
+
+// getClient returns the client of the configured address
+func (r *redisBackend) getClient() *goredis.Client {
+	r.clientMu.RLock()
+	defer r.clientMu.RUnlock()
+
+	return r.client
+}
+
+// resetClient replaces the client when it is stale, so its connections to a
+// demoted node are dropped and the address is resolved again
+func (r *redisBackend) resetClient(stale *goredis.Client) *goredis.Client {
+	r.clientMu.Lock()
+	defer r.clientMu.Unlock()
+
+	if r.client != stale {
+		return r.client
+	}
+
+	r.client = r.newClient(r.address)
+	stale.Close()
+
+	return r.client
+}
@@ -0,0 +1,138 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+	goredis "github.com/go-redis/redis"
+)
+
+// replicaGate keeps a backend reading from a replica from reporting stale
+// data. Before every read the replica's INFO replication is checked: the
+// link to the primary must be up and, with maxReplicaLagSeconds, the last
+// interaction with the primary recent enough. With maxReplicaLagBytes the
+// replica's offset is compared with primaryAddress's, which is then
+// required. A lagging replica is read from primaryAddress instead when it is
+// set, otherwise the poll fails so the ScaledObject's fallback applies.
+type replicaGate struct {
+	primaryAddress string
+	maxLagSeconds  float64
+	maxLagBytes    float64
+}
+
+func parseReplicaGate(metadata map[string]string) (*replicaGate, error) {
+	gate := replicaGate{primaryAddress: metadata["primaryAddress"]}
+	enabled := false
+
+	if val, ok := metadata["maxReplicaLagSeconds"]; ok && val != "" {
+		seconds, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Max replica lag parsing error %s", err.Error())
+		}
+
+		if seconds <= 0 {
+			return nil, fmt.Errorf("max replica lag must be positive")
+		}
+
+		gate.maxLagSeconds = seconds
+		enabled = true
+	}
+
+	if val, ok := metadata["maxReplicaLagBytes"]; ok && val != "" {
+		bytes, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Max replica lag bytes parsing error %s", err.Error())
+		}
+
+		if bytes <= 0 {
+			return nil, fmt.Errorf("max replica lag bytes must be positive")
+		}
+
+		if gate.primaryAddress == "" {
+			return nil, fmt.Errorf("maxReplicaLagBytes requires primaryAddress")
+		}
+
+		gate.maxLagBytes = bytes
+		enabled = true
+	}
+
+	if !enabled {
+		if gate.primaryAddress != "" {
+			return nil, fmt.Errorf("primaryAddress requires maxReplicaLagSeconds or maxReplicaLagBytes")
+		}
+		return nil, nil
+	}
+
+	return &gate, nil
+}
+
+// lagging returns why the replica behind client is too stale to read, or an
+// empty string when it is fresh enough. A server which is not a replica is
+// never lagging.
+func (g *replicaGate) lagging(client *goredis.Client, primary *goredis.Client) (string, error) {
+	raw, err := client.Info("replication").Result()
+	if err != nil {
+		return "", err
+	}
+
+	fields := parseInfo(raw)
+	if fields["role"] != "slave" {
+		return "", nil
+	}
+
+	if fields["master_link_status"] != "up" {
+		return "its link to the primary is down", nil
+	}
+
+	if g.maxLagSeconds > 0 {
+		seconds, err := strconv.ParseFloat(fields["master_last_io_seconds_ago"], 64)
+		if err == nil && seconds > g.maxLagSeconds {
+			return fmt.Sprintf("it last heard from the primary %gs ago", seconds), nil
+		}
+	}
+
+	if g.maxLagBytes > 0 {
+		replicaOffset, err := strconv.ParseFloat(fields["slave_repl_offset"], 64)
+		if err != nil {
+			return "", fmt.Errorf("replica reports no replication offset")
+		}
+
+		primaryRaw, err := primary.Info("replication").Result()
+		if err != nil {
+			return "", fmt.Errorf("primary %s %s", g.primaryAddress, err.Error())
+		}
+
+		primaryOffset, err := strconv.ParseFloat(parseInfo(primaryRaw)["master_repl_offset"], 64)
+		if err != nil {
+			return "", fmt.Errorf("primary %s reports no replication offset", g.primaryAddress)
+		}
+
+		if lag := primaryOffset - replicaOffset; lag > g.maxLagBytes {
+			return fmt.Sprintf("it is %g bytes behind the primary", lag), nil
+		}
+	}
+
+	return "", nil
+}
+
+// replicaClient returns the client to read the metric with, which is the
+// replica's unless it lags and a primary is configured
+func (r *redisBackend) replicaClient(client *goredis.Client) (*goredis.Client, error) {
+	reason, err := r.replica.lagging(client, r.primaryClient)
+	if err != nil {
+		return nil, err
+	}
+
+	if reason == "" {
+		return client, nil
+	}
+
+	if r.primaryClient == nil {
+		return nil, fmt.Errorf("replica %s is stale, %s", r.address, reason)
+	}
+
+	log.Printf("Replica %s is stale, %s, reading from primary %s", r.address, reason, r.replica.primaryAddress)
+
+	return r.primaryClient, nil
+}
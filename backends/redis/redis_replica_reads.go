@@ -0,0 +1,155 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	goredis "github.com/go-redis/redis"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const (
+	replicaRefreshInterval = 30 * time.Second
+
+	// Replicas acknowledge the replication stream every second, a replica
+	// silent for longer than this is not read from
+	maxReplicaReadLagSeconds = 5
+)
+
+// replicaReads sends the reads of a backend whose address is a primary to
+// the primary's replicas, to keep polling load off busy primaries. Replicas
+// are discovered from the primary's INFO replication, refreshed every
+// replicaRefreshInterval, and used in turn. The primary is read when no
+// replica is online or a replica read fails.
+type replicaReads struct {
+	allowEgress func(address string) error
+
+	mu        sync.Mutex
+	refreshed time.Time
+	addresses []string
+	clients   map[string]*goredis.Client
+	next      int
+}
+
+func parseReplicaReads(cfg *config.Config, metadata map[string]string) (*replicaReads, error) {
+	val, ok := metadata["readFromReplicas"]
+	if !ok || val == "" {
+		return nil, nil
+	}
+
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		return nil, fmt.Errorf("Read from replicas parsing error %s", err.Error())
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+
+	return &replicaReads{
+		allowEgress: cfg.AllowEgress,
+		clients:     make(map[string]*goredis.Client),
+	}, nil
+}
+
+// readFromReplica runs read against the next replica, or the primary when
+// there is none or the replica fails before ctx is done
+func (r *redisBackend) readFromReplica(ctx context.Context, read func(client *goredis.Client) (int64, error)) (int64, error) {
+	client, address := r.replicaReads.pick(r)
+	if client != nil {
+		value, err := read(client)
+		if err == nil || ctx.Err() != nil {
+			return value, err
+		}
+
+		log.Printf("Read from replica %s of %s failed, reading from the primary %s", address, r.address, err.Error())
+	}
+
+	return read(r.getClient())
+}
+
+// pick returns the client of the next replica, or nil when there is none
+func (p *replicaReads) pick(r *redisBackend) (*goredis.Client, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if time.Since(p.refreshed) > replicaRefreshInterval {
+		p.refresh(r)
+	}
+
+	if len(p.addresses) == 0 {
+		return nil, ""
+	}
+
+	address := p.addresses[p.next%len(p.addresses)]
+	p.next++
+
+	client, ok := p.clients[address]
+	if !ok {
+		client = r.newClient(address)
+		p.clients[address] = client
+	}
+
+	return client, address
+}
+
+// refresh lists the online replicas of the primary. The previous list is
+// kept when the primary cannot be asked.
+func (p *replicaReads) refresh(r *redisBackend) {
+	p.refreshed = time.Now()
+
+	raw, err := r.getClient().Info("replication").Result()
+	if err != nil {
+		log.Printf("Replicas of %s not refreshed %s", r.address, err.Error())
+		return
+	}
+
+	online := make(map[string]bool)
+	var addresses []string
+	for _, replica := range replicaFields(parseInfo(raw)) {
+		if replica["state"] != "online" {
+			continue
+		}
+
+		lag, err := strconv.ParseFloat(replica["lag"], 64)
+		if err != nil || lag > maxReplicaReadLagSeconds {
+			continue
+		}
+
+		address := net.JoinHostPort(replica["ip"], replica["port"])
+		if err := p.allowEgress(address); err != nil {
+			log.Printf("Replica %s of %s not used %s", address, r.address, err.Error())
+			continue
+		}
+
+		online[address] = true
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	for address, client := range p.clients {
+		if !online[address] {
+			client.Close()
+			delete(p.clients, address)
+		}
+	}
+
+	p.addresses = addresses
+}
+
+// close closes the clients of the replicas
+func (p *replicaReads) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for address, client := range p.clients {
+		client.Close()
+		delete(p.clients, address)
+	}
+}
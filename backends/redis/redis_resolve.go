@@ -0,0 +1,136 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const resolveTimeout = 5 * time.Second
+
+// addressResolver looks up the host of a redis backend's address every
+// interval, and right after a poll failed with a connection error. Pooled
+// connections outlive the address they were dialed to, so when a Service
+// such as redis-master points at a new IP they keep talking to the dead pod.
+// When the set of IPs changes the client is rebuilt, which drops the old
+// connections and dials the new address.
+type addressResolver struct {
+	host     string
+	interval time.Duration
+
+	trigger   chan struct{}
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// parseAddressResolver reads resolveInterval as a duration like "30s". It
+// returns nil when it is not set or the address is an IP.
+func parseAddressResolver(metadata map[string]string, address string) (*addressResolver, error) {
+	val, ok := metadata["resolveInterval"]
+	if !ok || val == "" {
+		return nil, nil
+	}
+
+	interval, err := time.ParseDuration(val)
+	if err != nil {
+		return nil, fmt.Errorf("Resolve interval parsing error %s", err.Error())
+	}
+
+	if interval <= 0 {
+		return nil, fmt.Errorf("resolve interval must be positive")
+	}
+
+	// Through a proxy or a tunnel the address is resolved on the other end
+	if metadata["proxyURL"] != "" || metadata["sshTunnelHost"] != "" {
+		return nil, fmt.Errorf("resolveInterval is not supported with proxyURL or sshTunnelHost")
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("Address parsing error %s", err.Error())
+	}
+
+	if net.ParseIP(host) != nil {
+		return nil, nil
+	}
+
+	return &addressResolver{
+		host:     host,
+		interval: interval,
+		trigger:  make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+// run resolves the host until the backend is closed. A failed lookup keeps
+// the client, the next one is compared against the last successful one.
+func (a *addressResolver) run(r *redisBackend) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	known, err := a.lookup()
+	if err != nil {
+		log.Printf("Redis host %s not resolved %s", a.host, err.Error())
+	}
+
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		case <-a.trigger:
+		}
+
+		addrs, err := a.lookup()
+		if err != nil {
+			log.Printf("Redis host %s not resolved %s", a.host, err.Error())
+			continue
+		}
+
+		if known != "" && addrs != known {
+			log.Printf("Redis host %s moved from %s to %s, reconnecting", a.host, known, addrs)
+			r.resetClient(r.getClient())
+		}
+		known = addrs
+	}
+}
+
+// lookup returns the sorted IPs of the host
+func (a *addressResolver) lookup() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, a.host)
+	if err != nil {
+		return "", err
+	}
+
+	sort.Strings(addrs)
+	return strings.Join(addrs, ","), nil
+}
+
+// connectionFailed asks for a lookup when err is a connection error, a
+// pending request is not repeated
+func (a *addressResolver) connectionFailed(err error) {
+	if !isConnectionError(err) {
+		return
+	}
+
+	select {
+	case a.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// close stops the lookups
+func (a *addressResolver) close() {
+	a.closeOnce.Do(func() {
+		close(a.stop)
+	})
+}
@@ -0,0 +1,51 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const srvLookupTimeout = 5 * time.Second
+
+// expandRedisSRV resolves addressSRV, the name of a DNS SRV record such as
+// _redis._tcp.cache.internal, into the address metadata key, for service
+// discovery through DNS like Consul's. The record is resolved when the scaler
+// is registered. Among several targets the one with the lowest priority is
+// used, targets of the same priority are picked by weight.
+func expandRedisSRV(metadata map[string]string) (map[string]string, error) {
+	name, ok := metadata["addressSRV"]
+	if !ok || name == "" {
+		return metadata, nil
+	}
+
+	if val, ok := metadata["address"]; ok && val != "" {
+		return nil, fmt.Errorf("address and addressSRV are mutually exclusive")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), srvLookupTimeout)
+	defer cancel()
+
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, fmt.Errorf("SRV lookup of %s failed %s", name, err.Error())
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("SRV record %s has no targets", name)
+	}
+
+	// Records are sorted by priority and randomized by weight
+	target := strings.TrimSuffix(records[0].Target, ".")
+
+	expanded := make(map[string]string, len(metadata)+1)
+	for key, value := range metadata {
+		expanded[key] = value
+	}
+	expanded["address"] = net.JoinHostPort(target, strconv.Itoa(int(records[0].Port)))
+
+	return expanded, nil
+}
@@ -0,0 +1,238 @@
+package redis
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const defaultSSHPort = "22"
+
+// sshTunnel dials redis through an SSH jump host with the OpenSSH client,
+// which forwards every connection with ssh -W, for redis in a private
+// network only reachable through a bastion. sshTunnelHost is the jump host,
+// sshTunnelUser the user logging in with the private key in
+// sshTunnelKeyFile. The host key is verified against
+// sshTunnelKnownHostsFile. Both files are paths inside the secret directory,
+// the key must not be readable by others, as ssh refuses it otherwise.
+type sshTunnel struct {
+	host           string
+	port           string
+	user           string
+	keyFile        string
+	knownHostsFile string
+}
+
+// parseSSHTunnel reads the sshTunnel keys. It returns nil when no
+// sshTunnelHost is set.
+func parseSSHTunnel(cfg *config.Config, metadata map[string]string) (*sshTunnel, error) {
+	val, ok := metadata["sshTunnelHost"]
+	if !ok || val == "" {
+		return nil, nil
+	}
+
+	if proxyURL, ok := metadata["proxyURL"]; ok && proxyURL != "" {
+		return nil, fmt.Errorf("sshTunnelHost and proxyURL are mutually exclusive")
+	}
+
+	tunnel := &sshTunnel{host: val, port: defaultSSHPort, user: metadata["sshTunnelUser"]}
+	if host, port, err := net.SplitHostPort(val); err == nil {
+		tunnel.host, tunnel.port = host, port
+	}
+
+	if tunnel.user == "" {
+		return nil, fmt.Errorf("sshTunnelHost requires sshTunnelUser")
+	}
+
+	// Values starting with a dash would be taken as options by ssh
+	if strings.HasPrefix(tunnel.host, "-") || strings.HasPrefix(tunnel.user, "-") {
+		return nil, fmt.Errorf("invalid sshTunnelHost or sshTunnelUser")
+	}
+
+	if _, err := strconv.ParseUint(tunnel.port, 10, 16); err != nil {
+		return nil, fmt.Errorf("SSH tunnel port parsing error %s", err.Error())
+	}
+
+	if err := cfg.AllowEgress(net.JoinHostPort(tunnel.host, tunnel.port)); err != nil {
+		return nil, err
+	}
+
+	var err error
+	tunnel.keyFile, err = secretDirPath(cfg, "sshTunnelKeyFile", metadata["sshTunnelKeyFile"])
+	if err != nil {
+		return nil, err
+	}
+
+	tunnel.knownHostsFile, err = secretDirPath(cfg, "sshTunnelKnownHostsFile", metadata["sshTunnelKnownHostsFile"])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := exec.LookPath("ssh"); err != nil {
+		return nil, fmt.Errorf("sshTunnelHost requires the ssh client %s", err.Error())
+	}
+
+	return tunnel, nil
+}
+
+// secretDirPath resolves a path of the metadata key below the secret
+// directory, rejecting paths which leave it
+func secretDirPath(cfg *config.Config, key string, val string) (string, error) {
+	if val == "" {
+		return "", fmt.Errorf("no %s given", key)
+	}
+
+	if cfg.SecretDir == "" {
+		return "", fmt.Errorf("%s is disabled, no secret directory configured", key)
+	}
+
+	if filepath.IsAbs(val) || strings.HasPrefix(filepath.Clean(val), "..") {
+		return "", fmt.Errorf("%s %s must be a path inside the secret directory", key, val)
+	}
+
+	return filepath.Join(cfg.SecretDir, filepath.Clean(val)), nil
+}
+
+// dial starts ssh forwarding its standard input and output to address
+func (s *sshTunnel) dial(address string, timeout time.Duration) (net.Conn, error) {
+	connectTimeout := int(timeout / time.Second)
+	if connectTimeout < 1 {
+		connectTimeout = 1
+	}
+
+	cmd := exec.Command("ssh",
+		"-i", s.keyFile,
+		"-o", "IdentitiesOnly=yes",
+		"-o", "BatchMode=yes",
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", "UserKnownHostsFile="+s.knownHostsFile,
+		"-o", "ConnectTimeout="+strconv.Itoa(connectTimeout),
+		"-o", "ExitOnForwardFailure=yes",
+		"-p", s.port,
+		"-l", s.user,
+		"-W", address,
+		"--", s.host,
+	)
+
+	stdinReader, stdinWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		stdinReader.Close()
+		stdinWriter.Close()
+		return nil, err
+	}
+
+	conn := &sshConn{
+		cmd:    cmd,
+		reader: stdoutReader,
+		writer: stdinWriter,
+		done:   make(chan struct{}),
+		remote: sshAddr(address),
+	}
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdinReader, stdoutWriter, &conn.stderr
+	err = cmd.Start()
+	stdinReader.Close()
+	stdoutWriter.Close()
+	if err != nil {
+		stdinWriter.Close()
+		stdoutReader.Close()
+		return nil, err
+	}
+
+	go func() {
+		cmd.Wait()
+		close(conn.done)
+	}()
+
+	return conn, nil
+}
+
+// sshConn is a connection forwarded by an ssh process
+type sshConn struct {
+	cmd    *exec.Cmd
+	reader *os.File
+	writer *os.File
+	stderr bytes.Buffer
+	done   chan struct{}
+	remote sshAddr
+
+	closeOnce sync.Once
+}
+
+func (c *sshConn) Read(b []byte) (int, error) {
+	n, err := c.reader.Read(b)
+	if err != nil && n == 0 {
+		select {
+		case <-c.done:
+			if message := strings.TrimSpace(c.stderr.String()); message != "" {
+				return 0, fmt.Errorf("ssh tunnel closed %s", message)
+			}
+		default:
+		}
+	}
+	return n, err
+}
+
+func (c *sshConn) Write(b []byte) (int, error) {
+	return c.writer.Write(b)
+}
+
+// Close ends the ssh process
+func (c *sshConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.writer.Close()
+		c.reader.Close()
+		if c.cmd.Process != nil {
+			c.cmd.Process.Kill()
+		}
+	})
+	return nil
+}
+
+func (c *sshConn) LocalAddr() net.Addr {
+	return sshAddr("ssh")
+}
+
+func (c *sshConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *sshConn) SetDeadline(t time.Time) error {
+	if err := c.reader.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.writer.SetWriteDeadline(t)
+}
+
+func (c *sshConn) SetReadDeadline(t time.Time) error {
+	return c.reader.SetReadDeadline(t)
+}
+
+func (c *sshConn) SetWriteDeadline(t time.Time) error {
+	return c.writer.SetWriteDeadline(t)
+}
+
+// sshAddr is the address of a connection forwarded by ssh
+type sshAddr string
+
+func (a sshAddr) Network() string {
+	return "ssh"
+}
+
+func (a sshAddr) String() string {
+	return string(a)
+}
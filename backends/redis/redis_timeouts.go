@@ -0,0 +1,51 @@
+package redis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+// redisTimeouts bound connecting to redis and every command sent, so a slow
+// or unreachable server fails the poll instead of holding it until the
+// deadline of the KEDA call. Zero values keep the go-redis defaults.
+type redisTimeouts struct {
+	dial  time.Duration
+	read  time.Duration
+	write time.Duration
+}
+
+// parseRedisTimeouts reads dialTimeout, readTimeout and writeTimeout as
+// durations like "500ms", defaulting to the server wide redisTimeouts
+func parseRedisTimeouts(cfg *config.Config, metadata map[string]string) (redisTimeouts, error) {
+	timeouts := redisTimeouts{
+		dial:  cfg.RedisTimeouts.DialTimeout.Duration,
+		read:  cfg.RedisTimeouts.ReadTimeout.Duration,
+		write: cfg.RedisTimeouts.WriteTimeout.Duration,
+	}
+
+	for key, timeout := range map[string]*time.Duration{
+		"dialTimeout":  &timeouts.dial,
+		"readTimeout":  &timeouts.read,
+		"writeTimeout": &timeouts.write,
+	} {
+		val, ok := metadata[key]
+		if !ok || val == "" {
+			continue
+		}
+
+		parsed, err := time.ParseDuration(val)
+		if err != nil {
+			return timeouts, fmt.Errorf("%s parsing error %s", key, err.Error())
+		}
+
+		if parsed <= 0 {
+			return timeouts, fmt.Errorf("%s must be positive", key)
+		}
+
+		*timeout = parsed
+	}
+
+	return timeouts, nil
+}
@@ -0,0 +1,172 @@
+package redis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// parseRedisTLS reads the TLS settings of a redis backend. enableTLS turns
+// TLS on, tlsServerName overrides the name the certificate is verified
+// against and sent with SNI, for endpoints behind a TCP load balancer whose
+// hostname the certificate does not carry. The server certificate is
+// verified against the CAs in tlsCAFile, for private CAs of managed clouds,
+// or the system pool, and not at all with insecureSkipVerify.
+//
+// A client certificate for mutual TLS is read from PEM in tlsCert and tlsKey,
+// as resolved by a TriggerAuthentication, or from the files tlsCertFile and
+// tlsKeyFile, such as a mounted secret, which are read again when they
+// change. It returns nil for plaintext.
+func parseRedisTLS(metadata map[string]string) (*tls.Config, error) {
+	enabled := false
+	if val, ok := metadata["enableTLS"]; ok && val != "" {
+		var err error
+		enabled, err = strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("Enable TLS parsing error %s", err.Error())
+		}
+	}
+
+	serverName := metadata["tlsServerName"]
+	caFile := metadata["tlsCAFile"]
+
+	insecureSkipVerify := false
+	if val, ok := metadata["insecureSkipVerify"]; ok && val != "" {
+		var err error
+		insecureSkipVerify, err = strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("Insecure skip verify parsing error %s", err.Error())
+		}
+	}
+
+	inline := metadata["tlsCert"] != "" || metadata["tlsKey"] != ""
+	files := metadata["tlsCertFile"] != "" || metadata["tlsKeyFile"] != ""
+
+	if !enabled {
+		if serverName != "" || caFile != "" || insecureSkipVerify || inline || files {
+			return nil, fmt.Errorf("tlsServerName, tlsCAFile, insecureSkipVerify and client certificates require enableTLS")
+		}
+		return nil, nil
+	}
+
+	if caFile != "" && insecureSkipVerify {
+		return nil, fmt.Errorf("tlsCAFile and insecureSkipVerify are mutually exclusive")
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if inline && files {
+		return nil, fmt.Errorf("tlsCert and tlsCertFile are mutually exclusive")
+	}
+
+	if inline {
+		cert, err := tls.X509KeyPair([]byte(metadata["tlsCert"]), []byte(metadata["tlsKey"]))
+		if err != nil {
+			return nil, fmt.Errorf("Client certificate parsing error %s", err.Error())
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if files {
+		certFiles := &certificateFiles{
+			certFile: metadata["tlsCertFile"],
+			keyFile:  metadata["tlsKeyFile"],
+		}
+		if err := certFiles.load(); err != nil {
+			return nil, fmt.Errorf("Client certificate parsing error %s", err.Error())
+		}
+
+		tlsConfig.GetClientCertificate = certFiles.getClientCertificate
+	}
+
+	return tlsConfig, nil
+}
+
+// certificateFiles is a client certificate read from files, which is read
+// again when the files changed, so certificates rotated on disk, for example
+// by cert-manager, are used by new connections without registering the
+// scaler again
+type certificateFiles struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+// load reads the certificate pair
+func (c *certificateFiles) load() error {
+	modified, err := c.lastModified()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	c.cert = &cert
+	c.modified = modified
+	return nil
+}
+
+// lastModified returns the later modification time of the two files
+func (c *certificateFiles) lastModified() (time.Time, error) {
+	var modified time.Time
+	for _, file := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+	return modified, nil
+}
+
+// getClientCertificate returns the certificate, reloading it when the files
+// changed. The previous certificate is kept while the new pair does not load,
+// as the files are not replaced at the same time.
+func (c *certificateFiles) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if modified, err := c.lastModified(); err == nil && !modified.Equal(c.modified) {
+		if err := c.load(); err != nil {
+			log.Printf("Could not reload client certificate %s, keeping the previous one %s", c.certFile, err.Error())
+		} else {
+			log.Printf("Reloaded client certificate %s", c.certFile)
+		}
+	}
+
+	return c.cert, nil
+}
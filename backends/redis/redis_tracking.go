@@ -0,0 +1,311 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	goredis "github.com/go-redis/redis"
+)
+
+const (
+	invalidateChannel = "__redis__:invalidate"
+
+	// A cached length is read again after this long even without an
+	// invalidation, in case one got lost
+	trackingMaxAge = time.Minute
+
+	subscribeTimeout = 5 * time.Second
+	maxRESPBulkBytes = 1024 * 1024
+
+	// Bounds on the arrays of a reply, so a broken or hostile server cannot
+	// force huge allocations or unbounded recursion
+	maxRESPArrayItems = 64 * 1024
+	maxRESPDepth      = 8
+)
+
+// lengthCache caches the length of listName with the client side caching of
+// Redis 6. Reads go through a client whose connections enable CLIENT
+// TRACKING with invalidations redirected to a subscriber connection, so
+// polls answer from the cache until redis reports the list changed.
+//
+// The vendored client cannot parse invalidation messages, which carry an
+// array of keys, so the subscriber speaks RESP on its own connection. When
+// it fails, the cache is dropped and set up again on the next read.
+type lengthCache struct {
+	mu         sync.Mutex
+	client     *goredis.Client
+	subscriber net.Conn
+	valid      bool
+	length     int64
+	readAt     time.Time
+	generation uint64
+	retryAt    time.Time
+	closed     bool
+}
+
+// parseClientCache reads clientCache, which is off unless set to true. It
+// needs a listName and rules out proxies and replica reads, whose
+// connections cannot be tracked.
+func parseClientCache(backend *redisBackend, metadata map[string]string) (*lengthCache, error) {
+	val, ok := metadata["clientCache"]
+	if !ok || val == "" {
+		return nil, nil
+	}
+
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		return nil, fmt.Errorf("Client cache parsing error %s", err.Error())
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+
+	if backend.listName == "" {
+		return nil, fmt.Errorf("clientCache needs a listName")
+	}
+
+	if backend.proxyMode || backend.replica != nil || backend.replicaReads != nil {
+		return nil, fmt.Errorf("clientCache is not supported with proxyMode, replica lag checks or replica reads")
+	}
+
+	if backend.flavor != "" && !redisFlavors[backend.flavor].tracking {
+		return nil, fmt.Errorf("clientCache is not supported by %s", backend.flavor)
+	}
+
+	return &lengthCache{}, nil
+}
+
+// listLength returns the cached length of the list, reading it through a
+// tracked connection when it was invalidated. Without a working subscriber
+// the length is read uncached through client, such as on servers before
+// Redis 6, and the cache is set up again after trackingMaxAge.
+func (c *lengthCache) listLength(r *redisBackend, client *goredis.Client) (int64, error) {
+	c.mu.Lock()
+	if c.valid && time.Since(c.readAt) < trackingMaxAge {
+		length := c.length
+		c.mu.Unlock()
+		return length, nil
+	}
+
+	if c.client == nil && !c.closed && time.Now().After(c.retryAt) {
+		if err := c.connect(r); err != nil {
+			log.Printf("Client cache of %s not available, reading uncached %s", r.address, err.Error())
+			c.retryAt = time.Now().Add(trackingMaxAge)
+		}
+	}
+
+	tracked, generation := c.client, c.generation
+	c.mu.Unlock()
+
+	if tracked == nil {
+		return client.LLen(r.listName).Result()
+	}
+
+	length, err := tracked.LLen(r.listName).Result()
+	if err != nil {
+		return -1, err
+	}
+
+	// An invalidation received while reading makes the length stale
+	c.mu.Lock()
+	if c.generation == generation && c.client == tracked {
+		c.valid, c.length, c.readAt = true, length, time.Now()
+	}
+	c.mu.Unlock()
+
+	return length, nil
+}
+
+// connect opens the subscriber and the tracked client. It is called with
+// mu held.
+func (c *lengthCache) connect(r *redisBackend) error {
+	conn, err := r.dialRedis(r.address)
+	if err != nil {
+		return err
+	}
+
+	id, err := subscribeInvalidations(conn, r.authArgs())
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	client := r.newClient(r.address)
+	options := client.Options()
+	onConnect := options.OnConnect
+	options.OnConnect = func(conn *goredis.Conn) error {
+		if onConnect != nil {
+			if err := onConnect(conn); err != nil {
+				return err
+			}
+		}
+		return conn.Do("client", "tracking", "on", "redirect", id).Err()
+	}
+
+	// Servers without tracking fail the first connection
+	if err := client.Ping().Err(); err != nil {
+		client.Close()
+		conn.Close()
+		return err
+	}
+
+	c.client, c.subscriber = client, conn
+	c.valid = false
+	go c.receive(r.address, conn, client)
+
+	return nil
+}
+
+// receive invalidates the cache on every message of the subscriber until
+// the connection fails or is closed
+func (c *lengthCache) receive(address string, conn net.Conn, client *goredis.Client) {
+	reader := bufio.NewReader(conn)
+	for {
+		if _, err := readRESP(reader); err != nil {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			if c.subscriber == conn {
+				if !c.closed {
+					log.Printf("Client cache invalidations of %s stopped %s", address, err.Error())
+				}
+				c.subscriber, c.client, c.valid = nil, nil, false
+				conn.Close()
+				client.Close()
+			}
+			return
+		}
+
+		c.mu.Lock()
+		c.valid = false
+		c.generation++
+		c.mu.Unlock()
+	}
+}
+
+func (c *lengthCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.subscriber != nil {
+		c.subscriber.Close()
+		c.client.Close()
+		c.subscriber, c.client = nil, nil
+	}
+}
+
+// subscribeInvalidations authenticates conn, subscribes it to the
+// invalidation channel and returns its client id
+func subscribeInvalidations(conn net.Conn, auth []interface{}) (int64, error) {
+	conn.SetDeadline(time.Now().Add(subscribeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	reader := bufio.NewReader(conn)
+
+	if auth != nil {
+		if _, err := doRESP(conn, reader, auth...); err != nil {
+			return -1, err
+		}
+	}
+
+	reply, err := doRESP(conn, reader, "client", "id")
+	if err != nil {
+		return -1, err
+	}
+
+	id, ok := reply.(int64)
+	if !ok {
+		return -1, fmt.Errorf("unexpected reply to CLIENT ID")
+	}
+
+	if _, err := doRESP(conn, reader, "subscribe", invalidateChannel); err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// doRESP sends a command and reads its reply
+func doRESP(w io.Writer, reader *bufio.Reader, args ...interface{}) (interface{}, error) {
+	command := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		value := fmt.Sprint(arg)
+		command = append(command, "$"+strconv.Itoa(len(value))+"\r\n"+value+"\r\n"...)
+	}
+
+	if _, err := w.Write(command); err != nil {
+		return nil, err
+	}
+
+	return readRESP(reader)
+}
+
+// readRESP reads a RESP2 reply, error replies are returned as errors
+func readRESP(reader *bufio.Reader) (interface{}, error) {
+	return readRESPValue(reader, 0)
+}
+
+func readRESPValue(reader *bufio.Reader, depth int) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed reply")
+	}
+	kind, line := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, fmt.Errorf("%s", line)
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line)
+		if err != nil || size < -1 || size > maxRESPBulkBytes {
+			return nil, fmt.Errorf("malformed bulk reply")
+		}
+
+		if size == -1 {
+			return nil, nil
+		}
+
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line)
+		if err != nil || count < -1 || count > maxRESPArrayItems || depth >= maxRESPDepth {
+			return nil, fmt.Errorf("malformed array reply")
+		}
+
+		if count == -1 {
+			return nil, nil
+		}
+
+		items := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			item, err := readRESPValue(reader, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unknown reply type %q", kind)
+	}
+}
@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRESP(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame string
+		want  interface{}
+	}{
+		{"simple string", "+OK\r\n", "OK"},
+		{"integer", ":42\r\n", int64(42)},
+		{"bulk string", "$5\r\nqueue\r\n", "queue"},
+		{"null bulk string", "$-1\r\n", nil},
+		{"null array", "*-1\r\n", nil},
+		{"empty array", "*0\r\n", []interface{}{}},
+		{
+			"subscribe confirmation",
+			"*3\r\n$9\r\nsubscribe\r\n$20\r\n__redis__:invalidate\r\n:1\r\n",
+			[]interface{}{"subscribe", invalidateChannel, int64(1)},
+		},
+		{
+			"invalidation",
+			"*3\r\n$7\r\nmessage\r\n$20\r\n__redis__:invalidate\r\n*1\r\n$5\r\nqueue\r\n",
+			[]interface{}{"message", invalidateChannel, []interface{}{"queue"}},
+		},
+		{
+			"flush invalidation",
+			"*3\r\n$7\r\nmessage\r\n$20\r\n__redis__:invalidate\r\n*-1\r\n",
+			[]interface{}{"message", invalidateChannel, nil},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := readRESP(bufio.NewReader(strings.NewReader(test.frame)))
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestReadRESPSequence(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader(
+		"*3\r\n$7\r\nmessage\r\n$20\r\n__redis__:invalidate\r\n*1\r\n$1\r\na\r\n" +
+			"*3\r\n$7\r\nmessage\r\n$20\r\n__redis__:invalidate\r\n*1\r\n$1\r\nb\r\n"))
+
+	for _, key := range []string{"a", "b"} {
+		got, err := readRESP(reader)
+		if err != nil {
+			t.Fatalf("unexpected error %s", err.Error())
+		}
+		want := []interface{}{"message", invalidateChannel, []interface{}{key}}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %#v, want %#v", got, want)
+		}
+	}
+}
+
+func TestReadRESPErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame string
+	}{
+		{"error reply", "-ERR unknown command\r\n"},
+		{"missing carriage return", "+OK\n"},
+		{"empty line", "\r\n"},
+		{"unknown type", "!3\r\nfoo\r\n"},
+		{"bad integer", ":forty\r\n"},
+		{"bad bulk length", "$abc\r\n"},
+		{"negative bulk length", "$-2\r\n"},
+		{"oversized bulk length", "$99999999\r\n"},
+		{"truncated bulk string", "$10\r\nqueue\r\n"},
+		{"bad array count", "*abc\r\n"},
+		{"negative array count", "*-2\r\n"},
+		{"oversized array count", "*99999999\r\n"},
+		{"truncated array", "*2\r\n$5\r\nqueue\r\n"},
+		{"too deeply nested", strings.Repeat("*1\r\n", maxRESPDepth+1) + ":1\r\n"},
+		{"end of input", ""},
+	}
+
+	for _, test := range tests {
+		if got, err := readRESP(bufio.NewReader(strings.NewReader(test.frame))); err == nil {
+			t.Errorf("%s: expected an error, got %#v", test.name, got)
+		}
+	}
+}
+
+func TestDoRESP(t *testing.T) {
+	var written bytes.Buffer
+	reader := bufio.NewReader(strings.NewReader(":7\r\n"))
+
+	reply, err := doRESP(&written, reader, "client", "tracking", "on", "redirect", int64(7))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err.Error())
+	}
+	if reply != int64(7) {
+		t.Errorf("got reply %#v, want 7", reply)
+	}
+
+	want := "*5\r\n$6\r\nclient\r\n$8\r\ntracking\r\n$2\r\non\r\n$8\r\nredirect\r\n$1\r\n7\r\n"
+	if written.String() != want {
+		t.Errorf("got command %q, want %q", written.String(), want)
+	}
+}
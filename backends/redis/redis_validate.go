@@ -0,0 +1,51 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+
+	goredis "github.com/go-redis/redis"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// parseValidateConnection reads validateConnection, which is on unless set
+// to false for scalers registered before their redis is up
+func parseValidateConnection(metadata map[string]string) (bool, error) {
+	val, ok := metadata["validateConnection"]
+	if !ok || val == "" {
+		return true, nil
+	}
+
+	validate, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("Validate connection parsing error %s", err.Error())
+	}
+
+	return validate, nil
+}
+
+// validateConnection pings redis and checks that an existing listName key is
+// a list, so a misconfigured scaler is rejected by New() with an error KEDA
+// shows in the ScaledObject's events instead of failing every poll
+func (r *redisBackend) validateConnection(client *goredis.Client) error {
+	if err := client.Ping().Err(); err != nil {
+		return status.Errorf(codes.FailedPrecondition, "redis at %s is not reachable, check address, password and TLS settings: %s", r.address, err.Error())
+	}
+
+	if r.listName == "" {
+		return nil
+	}
+
+	// Redis deletes empty lists, a missing key is an empty queue
+	keyType, err := client.Type(r.listName).Result()
+	if err != nil {
+		return status.Errorf(codes.FailedPrecondition, "type of %s on %s not readable: %s", r.listName, r.address, err.Error())
+	}
+
+	if keyType != "list" && keyType != "none" {
+		return status.Errorf(codes.InvalidArgument, "listName %s on %s holds a %s, not a list", r.listName, r.address, keyType)
+	}
+
+	return nil
+}
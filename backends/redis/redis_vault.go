@@ -0,0 +1,357 @@
+package redis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const (
+	vaultAuthKubernetes = "kubernetes"
+	vaultAuthToken      = "token"
+
+	vaultTimeout           = 10 * time.Second
+	vaultTokenHeader       = "X-Vault-Token"
+	maxVaultBodyBytes      = 64 * 1024
+	vaultServiceAccountJWT = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	// Secrets without a lease, such as KV entries, are read again this often
+	vaultStaticRefreshInterval = 5 * time.Minute
+	minVaultRenewInterval      = 10 * time.Second
+)
+
+// vaultCredentials are redis credentials read from vaultPath of the Vault
+// server of the server config, for example short-lived credentials of a database
+// secrets engine or a KV entry. Both data.username and data.password and the
+// KV version 2 layout data.data are understood.
+//
+// The scaler logs in with its service account through the kubernetes auth
+// method mounted where the server config says and vaultRole, or uses vaultToken with vaultAuthMethod token, which
+// is best given as vaultTokenSecretRef. Leases are renewed in the background
+// at half their duration, credentials are read again when a lease cannot be
+// renewed any more and revoked when the backend is closed.
+type vaultCredentials struct {
+	address    string
+	path       string
+	authMethod string
+	authMount  string
+	role       string
+	client     *http.Client
+
+	// fetchMu serializes logins, reads and renewals
+	fetchMu sync.Mutex
+	token   string
+
+	mu            sync.Mutex
+	username      string
+	password      string
+	leaseID       string
+	leaseDuration time.Duration
+	renewable     bool
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+type vaultResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int64                  `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+}
+
+// parseVaultCredentials reads the credentials at registration. It returns nil
+// when vaultPath is not set.
+//
+// The address and the auth mount only come from the server config, as the
+// login sends the scaler's own service account token to them.
+func parseVaultCredentials(cfg *config.Config, metadata map[string]string) (*vaultCredentials, error) {
+	for _, key := range []string{"vaultAddress", "vaultAuthMount"} {
+		if val, ok := metadata[key]; ok && val != "" {
+			return nil, fmt.Errorf("%s is not accepted in metadata, the vault server is set in the server config", key)
+		}
+	}
+
+	path := strings.Trim(metadata["vaultPath"], "/")
+	if path == "" {
+		return nil, nil
+	}
+
+	if cfg.Vault.Address == "" {
+		return nil, fmt.Errorf("vaultPath requires the vault address to be configured")
+	}
+
+	if password, ok := metadata["password"]; ok && password != "" {
+		return nil, fmt.Errorf("password and vaultPath are mutually exclusive")
+	}
+
+	vault := &vaultCredentials{
+		address:    strings.TrimSuffix(cfg.Vault.Address, "/"),
+		path:       path,
+		authMethod: vaultAuthKubernetes,
+		authMount:  vaultAuthKubernetes,
+		role:       metadata["vaultRole"],
+		client: &http.Client{
+			Timeout: vaultTimeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return fmt.Errorf("vault redirects are not followed")
+			},
+		},
+		stop: make(chan struct{}),
+	}
+
+	if val, ok := metadata["vaultAuthMethod"]; ok && val != "" {
+		vault.authMethod = val
+	}
+
+	if cfg.Vault.AuthMount != "" {
+		vault.authMount = strings.Trim(cfg.Vault.AuthMount, "/")
+	}
+
+	switch vault.authMethod {
+	case vaultAuthKubernetes:
+		if vault.role == "" {
+			return nil, fmt.Errorf("vault kubernetes auth requires a vaultRole")
+		}
+	case vaultAuthToken:
+		vault.token = metadata["vaultToken"]
+		if vault.token == "" {
+			return nil, fmt.Errorf("vault token auth requires a vaultToken")
+		}
+	default:
+		return nil, fmt.Errorf("vaultAuthMethod must be %s or %s", vaultAuthKubernetes, vaultAuthToken)
+	}
+
+	if err := vault.refresh(); err != nil {
+		return nil, err
+	}
+
+	go vault.run()
+
+	return vault, nil
+}
+
+// credentials returns the current username and password
+func (v *vaultCredentials) credentials() (string, string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.username, v.password
+}
+
+// refresh reads the credentials from Vault, logging in first when needed
+func (v *vaultCredentials) refresh() error {
+	v.fetchMu.Lock()
+	defer v.fetchMu.Unlock()
+
+	return v.read()
+}
+
+func (v *vaultCredentials) read() error {
+	resp, err := v.request(http.MethodGet, "/v1/"+v.path, nil)
+	if err != nil {
+		return err
+	}
+
+	data := resp.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+
+	password, _ := data["password"].(string)
+	if password == "" {
+		return fmt.Errorf("vault secret %s has no password", v.path)
+	}
+	username, _ := data["username"].(string)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.username = username
+	v.password = password
+	v.leaseID = resp.LeaseID
+	v.leaseDuration = time.Duration(resp.LeaseDuration) * time.Second
+	v.renewable = resp.Renewable
+
+	return nil
+}
+
+// run renews the lease, or reads the credentials again, until the backend is
+// closed
+func (v *vaultCredentials) run() {
+	for {
+		v.mu.Lock()
+		wait := v.leaseDuration / 2
+		if v.leaseID == "" {
+			wait = vaultStaticRefreshInterval
+		}
+		v.mu.Unlock()
+
+		if wait < minVaultRenewInterval {
+			wait = minVaultRenewInterval
+		}
+
+		select {
+		case <-v.stop:
+			return
+		case <-time.After(wait):
+		}
+
+		if err := v.renew(); err != nil {
+			log.Printf("Vault credentials %s not renewed %s", v.path, err.Error())
+		}
+	}
+}
+
+// renew extends the lease. A lease which is not renewable, failed to renew or
+// reached its maximum duration is replaced by reading new credentials.
+func (v *vaultCredentials) renew() error {
+	v.fetchMu.Lock()
+	defer v.fetchMu.Unlock()
+
+	v.mu.Lock()
+	leaseID, renewable := v.leaseID, v.renewable
+	v.mu.Unlock()
+
+	if leaseID != "" && renewable {
+		resp, err := v.request(http.MethodPut, "/v1/sys/leases/renew", map[string]string{"lease_id": leaseID})
+		if err == nil && time.Duration(resp.LeaseDuration)*time.Second >= 2*minVaultRenewInterval {
+			v.mu.Lock()
+			v.leaseDuration = time.Duration(resp.LeaseDuration) * time.Second
+			v.mu.Unlock()
+			return nil
+		}
+
+		if err != nil {
+			log.Printf("Vault lease of %s not renewed, reading new credentials %s", v.path, err.Error())
+		}
+	}
+
+	return v.read()
+}
+
+// request calls the Vault API, logging in again once when the token was
+// rejected
+func (v *vaultCredentials) request(method string, path string, in interface{}) (*vaultResponse, error) {
+	if v.token == "" {
+		if err := v.login(); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, status, err := v.call(method, path, in, v.token)
+	if status == http.StatusForbidden && v.authMethod == vaultAuthKubernetes {
+		if err := v.login(); err != nil {
+			return nil, err
+		}
+		resp, _, err = v.call(method, path, in, v.token)
+	}
+
+	return resp, err
+}
+
+// login exchanges the service account token for a Vault token
+func (v *vaultCredentials) login() error {
+	if v.authMethod != vaultAuthKubernetes {
+		return fmt.Errorf("vault token rejected")
+	}
+
+	jwt, err := ioutil.ReadFile(vaultServiceAccountJWT)
+	if err != nil {
+		return err
+	}
+
+	resp, _, err := v.call(http.MethodPost, "/v1/auth/"+v.authMount+"/login", map[string]string{
+		"role": v.role,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	}, "")
+	if err != nil {
+		return fmt.Errorf("vault login failed %s", err.Error())
+	}
+
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return fmt.Errorf("vault login returned no token")
+	}
+
+	v.token = resp.Auth.ClientToken
+	return nil
+}
+
+func (v *vaultCredentials) call(method string, path string, in interface{}, token string) (*vaultResponse, int, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, 0, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, v.address+path, body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if token != "" {
+		req.Header.Set(vaultTokenHeader, token)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxVaultBodyBytes))
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, resp.StatusCode, fmt.Errorf("vault %s %s returned %s", method, path, resp.Status)
+	}
+
+	var result vaultResponse
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, resp.StatusCode, fmt.Errorf("Vault response parsing error %s", err.Error())
+		}
+	}
+
+	return &result, resp.StatusCode, nil
+}
+
+// close stops the renewals and revokes the lease, the credentials are not
+// needed any more
+func (v *vaultCredentials) close() {
+	v.closeOnce.Do(func() {
+		close(v.stop)
+
+		v.fetchMu.Lock()
+		defer v.fetchMu.Unlock()
+
+		v.mu.Lock()
+		leaseID := v.leaseID
+		v.mu.Unlock()
+
+		if leaseID == "" {
+			return
+		}
+
+		if _, err := v.request(http.MethodPut, "/v1/sys/leases/revoke", map[string]string{"lease_id": leaseID}); err != nil {
+			log.Printf("Vault lease of %s not revoked %s", v.path, err.Error())
+		}
+	})
+}
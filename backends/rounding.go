@@ -0,0 +1,47 @@
+package backends
+
+import (
+	"fmt"
+	"math"
+)
+
+// RoundingMode decides how fractional values, such as aggregated time series
+// samples, become the integer metric reported to KEDA. Near a threshold the
+// mode decides whether a replica is added, so it is configurable per scaler
+// with roundingMode.
+type RoundingMode string
+
+const (
+	roundingCeil    RoundingMode = "ceil"
+	roundingFloor   RoundingMode = "floor"
+	roundingNearest RoundingMode = "nearest"
+
+	// A fractional backlog still counts by default
+	defaultRoundingMode = roundingCeil
+)
+
+// ParseRoundingMode reads roundingMode, ceil by default
+func ParseRoundingMode(metadata map[string]string) (RoundingMode, error) {
+	val, ok := metadata["roundingMode"]
+	if !ok || val == "" {
+		return defaultRoundingMode, nil
+	}
+
+	switch mode := RoundingMode(val); mode {
+	case roundingCeil, roundingFloor, roundingNearest:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("RoundingMode must be %s, %s or %s", roundingCeil, roundingFloor, roundingNearest)
+}
+
+// Round converts value to an integer. Nearest rounds halves away from zero.
+func (m RoundingMode) Round(value float64) int64 {
+	switch m {
+	case roundingFloor:
+		return int64(math.Floor(value))
+	case roundingNearest:
+		return int64(math.Round(value))
+	}
+	return int64(math.Ceil(value))
+}
@@ -0,0 +1,165 @@
+package snmp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BER tags of the types used by SNMP
+const (
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagNull        = 0x05
+	tagOID         = 0x06
+	tagSequence    = 0x30
+
+	tagCounter32 = 0x41
+	tagGauge32   = 0x42
+	tagTimeTicks = 0x43
+	tagCounter64 = 0x46
+
+	tagNoSuchObject   = 0x80
+	tagNoSuchInstance = 0x81
+	tagEndOfMibView   = 0x82
+
+	tagGetRequest  = 0xa0
+	tagGetResponse = 0xa2
+	tagReport      = 0xa8
+)
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+
+	var digits []byte
+	for ; n > 0; n >>= 8 {
+		digits = append([]byte{byte(n)}, digits...)
+	}
+	return append([]byte{0x80 | byte(len(digits))}, digits...)
+}
+
+func encodeTLV(tag byte, content ...[]byte) []byte {
+	size := 0
+	for _, part := range content {
+		size += len(part)
+	}
+
+	out := append([]byte{tag}, encodeLength(size)...)
+	for _, part := range content {
+		out = append(out, part...)
+	}
+	return out
+}
+
+func encodeInteger(v int64) []byte {
+	content := []byte{byte(v)}
+	for v > 127 || v < -128 {
+		v >>= 8
+		content = append([]byte{byte(v)}, content...)
+	}
+	return encodeTLV(tagInteger, content)
+}
+
+func encodeOctetString(s []byte) []byte {
+	return encodeTLV(tagOctetString, s)
+}
+
+func encodeOID(oid []uint32) []byte {
+	content := []byte{byte(oid[0]*40 + oid[1])}
+	for _, arc := range oid[2:] {
+		var digits []byte
+		digits = append(digits, byte(arc&0x7f))
+		for arc >>= 7; arc > 0; arc >>= 7 {
+			digits = append([]byte{byte(arc&0x7f) | 0x80}, digits...)
+		}
+		content = append(content, digits...)
+	}
+	return encodeTLV(tagOID, content)
+}
+
+// parseOID reads a dotted OID such as 1.3.6.1.2.1.1.3.0
+func parseOID(val string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimPrefix(val, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("oid %s is too short", val)
+	}
+
+	oid := make([]uint32, len(parts))
+	for i, part := range parts {
+		arc, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("OID parsing error %s", err.Error())
+		}
+		oid[i] = uint32(arc)
+	}
+
+	if oid[0] > 2 || oid[1] > 39 {
+		return nil, fmt.Errorf("oid %s is invalid", val)
+	}
+
+	return oid, nil
+}
+
+// decodeTLV splits the first element off b
+func decodeTLV(b []byte) (byte, []byte, []byte, error) {
+	if len(b) < 2 {
+		return 0, nil, nil, fmt.Errorf("truncated element")
+	}
+
+	tag := b[0]
+	size := int(b[1])
+	offset := 2
+	if size&0x80 != 0 {
+		digits := size & 0x7f
+		if digits == 0 || digits > 4 || len(b) < 2+digits {
+			return 0, nil, nil, fmt.Errorf("invalid length")
+		}
+
+		size = 0
+		for _, digit := range b[2 : 2+digits] {
+			size = size<<8 | int(digit)
+		}
+		offset += digits
+	}
+
+	if size < 0 || len(b) < offset+size {
+		return 0, nil, nil, fmt.Errorf("truncated element")
+	}
+
+	return tag, b[offset : offset+size], b[offset+size:], nil
+}
+
+// decodeExpected splits the first element off b and checks its tag
+func decodeExpected(b []byte, expected byte) ([]byte, []byte, error) {
+	tag, content, rest, err := decodeTLV(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if tag != expected {
+		return nil, nil, fmt.Errorf("expected tag 0x%x, got 0x%x", expected, tag)
+	}
+
+	return content, rest, nil
+}
+
+func decodeInteger(content []byte) int64 {
+	var v int64
+	for i, digit := range content {
+		if i == 0 && digit&0x80 != 0 {
+			v = -1
+		}
+		v = v<<8 | int64(digit)
+	}
+	return v
+}
+
+func decodeUnsigned(content []byte) uint64 {
+	var v uint64
+	for _, digit := range content {
+		v = v<<8 | uint64(digit)
+	}
+	return v
+}
@@ -0,0 +1,616 @@
+// Package snmp is the metric backend reading an integer OID of an SNMP
+// agent, for appliances which only expose their queue depth over SNMP
+package snmp
+
+import (
+	"context"
+	"crypto/hmac"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	// ScalerType is the scalerType metadata value of the snmp backend
+	ScalerType = "snmp"
+)
+
+const (
+	valueMetricName = "SNMPValue"
+
+	version2c = "2c"
+	version3  = "3"
+
+	defaultPort      = "161"
+	defaultCommunity = "public"
+	defaultTimeout   = 5 * time.Second
+
+	maxMessageSize = 65507
+
+	// RFC 3414 requires passwords of at least 8 characters
+	minPasswordLength = 8
+)
+
+// Report OIDs of the user based security model, a report about an engine
+// time outside the window is answered by retrying with the reported time
+var usmReports = map[string]string{
+	"1.3.6.1.6.3.15.1.1.1.0": "unsupported security level",
+	"1.3.6.1.6.3.15.1.1.2.0": "not in time window",
+	"1.3.6.1.6.3.15.1.1.3.0": "unknown user name",
+	"1.3.6.1.6.3.15.1.1.4.0": "unknown engine id",
+	"1.3.6.1.6.3.15.1.1.5.0": "wrong digest, check the authentication password",
+	"1.3.6.1.6.3.15.1.1.6.0": "decryption error, check the privacy password",
+}
+
+const usmNotInTimeWindow = "1.3.6.1.6.3.15.1.1.2.0"
+
+// snmpBackend reports the value of oid, which must be an integer, counter,
+// gauge, time ticks or a string holding a number, read with an SNMP GET.
+// Version 2c authenticates with community. Version 3 uses username with
+// optional authPassword (authProtocol MD5 or SHA) and privPassword (AES),
+// the engine of the agent is discovered on the first poll.
+type snmpBackend struct {
+	address     string
+	oid         []uint32
+	oidString   string
+	version     string
+	community   string
+	contextName string
+	timeout     time.Duration
+
+	// mu serializes polls, the engine state of user changes with them
+	mu   sync.Mutex
+	user *usmUser
+}
+
+// NewBackend creates an snmp backend from the trigger metadata
+func NewBackend(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	backend := snmpBackend{
+		version:     version2c,
+		community:   defaultCommunity,
+		contextName: metadata["contextName"],
+		timeout:     defaultTimeout,
+	}
+
+	address, ok := metadata["address"]
+	if !ok || address == "" {
+		return nil, fmt.Errorf("no address given")
+	}
+
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultPort)
+	}
+
+	if err := cfg.AllowEgress(address); err != nil {
+		return nil, err
+	}
+	backend.address = address
+
+	oid, err := parseOID(metadata["oid"])
+	if err != nil {
+		return nil, err
+	}
+	backend.oid = oid
+	backend.oidString = formatOID(oid)
+
+	if val, ok := metadata["version"]; ok && val != "" {
+		backend.version = val
+	}
+
+	switch backend.version {
+	case version2c:
+		if val, ok := metadata["community"]; ok && val != "" {
+			backend.community = val
+		}
+	case version3:
+		backend.user, err = parseUSMUser(metadata)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("version must be %s or %s", version2c, version3)
+	}
+
+	if val, ok := metadata["timeout"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Timeout parsing error %s", err.Error())
+		}
+
+		if seconds <= 0 {
+			return nil, fmt.Errorf("timeout must be positive")
+		}
+
+		backend.timeout = time.Duration(seconds) * time.Second
+	}
+
+	return &backend, nil
+}
+
+func parseUSMUser(metadata map[string]string) (*usmUser, error) {
+	user := &usmUser{
+		name:         metadata["username"],
+		authProtocol: authProtocolSHA,
+		authPassword: metadata["authPassword"],
+		privProtocol: privProtocolAES,
+		privPassword: metadata["privPassword"],
+	}
+
+	if user.name == "" {
+		return nil, fmt.Errorf("snmp version 3 requires a username")
+	}
+
+	if val, ok := metadata["authProtocol"]; ok && val != "" {
+		user.authProtocol = strings.ToUpper(val)
+	}
+
+	if user.authProtocol != authProtocolMD5 && user.authProtocol != authProtocolSHA {
+		return nil, fmt.Errorf("authProtocol must be %s or %s", authProtocolMD5, authProtocolSHA)
+	}
+
+	if val, ok := metadata["privProtocol"]; ok && val != "" {
+		user.privProtocol = strings.ToUpper(val)
+	}
+
+	if user.privProtocol != privProtocolAES {
+		return nil, fmt.Errorf("privProtocol must be %s", privProtocolAES)
+	}
+
+	if user.privPassword != "" && user.authPassword == "" {
+		return nil, fmt.Errorf("privPassword requires an authPassword")
+	}
+
+	for _, password := range []string{user.authPassword, user.privPassword} {
+		if password != "" && len(password) < minPasswordLength {
+			return nil, fmt.Errorf("snmp passwords must have at least %d characters", minPasswordLength)
+		}
+	}
+
+	return user, nil
+}
+
+// Endpoint returns the agent address
+func (s *snmpBackend) Endpoint() string {
+	return s.address
+}
+
+// MetricName returns the name of the value metric
+func (s *snmpBackend) MetricName() string {
+	return valueMetricName
+}
+
+// GetMetricValue reads the OID from the agent
+func (s *snmpBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deadline := time.Now().Add(s.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "udp", s.address)
+	if err != nil {
+		return -1, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(deadline); err != nil {
+		return -1, err
+	}
+
+	var varbind []byte
+	var tag byte
+	if s.version == version3 {
+		tag, varbind, err = s.getV3(conn)
+	} else {
+		tag, varbind, err = s.getV2c(conn)
+	}
+	if err != nil {
+		return -1, fmt.Errorf("snmp %s %s", s.address, err.Error())
+	}
+
+	return s.decodeValue(tag, varbind)
+}
+
+func (s *snmpBackend) getRequest(requestID int32) []byte {
+	varbind := encodeTLV(tagSequence, encodeOID(s.oid), encodeTLV(tagNull))
+	return encodeTLV(tagGetRequest,
+		encodeInteger(int64(requestID)),
+		encodeInteger(0),
+		encodeInteger(0),
+		encodeTLV(tagSequence, varbind))
+}
+
+func (s *snmpBackend) getV2c(conn net.Conn) (byte, []byte, error) {
+	requestID := rand.Int31()
+	message := encodeTLV(tagSequence,
+		encodeInteger(1),
+		encodeOctetString([]byte(s.community)),
+		s.getRequest(requestID))
+
+	if _, err := conn.Write(message); err != nil {
+		return 0, nil, err
+	}
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		content, _, err := decodeExpected(buf[:n], tagSequence)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		// Skip the version and community
+		_, content, err = decodeExpected(content, tagInteger)
+		if err != nil {
+			return 0, nil, err
+		}
+		_, content, err = decodeExpected(content, tagOctetString)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		pduTag, id, oid, tag, value, err := decodePDU(content)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		// Late answers to earlier polls are skipped
+		if id != requestID {
+			continue
+		}
+
+		if pduTag != tagGetResponse {
+			return 0, nil, fmt.Errorf("unexpected pdu 0x%x for %s", pduTag, oid)
+		}
+
+		return tag, value, nil
+	}
+}
+
+func (s *snmpBackend) getV3(conn net.Conn) (byte, []byte, error) {
+	if s.user.engineID == nil {
+		if err := s.discover(conn); err != nil {
+			return 0, nil, fmt.Errorf("engine discovery failed %s", err.Error())
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		pduTag, oid, tag, value, err := s.exchangeV3(conn, false)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if pduTag == tagGetResponse {
+			return tag, value, nil
+		}
+
+		if pduTag == tagReport && oid == usmNotInTimeWindow && attempt == 0 {
+			continue
+		}
+
+		if reason, ok := usmReports[oid]; ok {
+			return 0, nil, fmt.Errorf("agent reported %s", reason)
+		}
+		return 0, nil, fmt.Errorf("unexpected pdu 0x%x for %s", pduTag, oid)
+	}
+}
+
+// discover learns the engine id, boots and time of the agent from the report
+// answering an unauthenticated request, and localizes the user's keys
+func (s *snmpBackend) discover(conn net.Conn) error {
+	pduTag, oid, _, _, err := s.exchangeV3(conn, true)
+	if err != nil {
+		return err
+	}
+
+	if pduTag != tagReport {
+		return fmt.Errorf("unexpected pdu 0x%x for %s", pduTag, oid)
+	}
+
+	if s.user.engineID == nil {
+		return fmt.Errorf("agent reported no engine id")
+	}
+
+	s.user.localize()
+	return nil
+}
+
+// exchangeV3 sends a GET and returns the pdu type, the first variable binding
+// and its value of the answer. The engine boots and time of the answer are
+// kept for the following requests.
+func (s *snmpBackend) exchangeV3(conn net.Conn, discovery bool) (byte, string, byte, []byte, error) {
+	messageID := rand.Int31()
+	requestID := rand.Int31()
+
+	message, err := s.encodeV3(messageID, s.getRequest(requestID), discovery)
+	if err != nil {
+		return 0, "", 0, nil, err
+	}
+
+	if _, err := conn.Write(message); err != nil {
+		return 0, "", 0, nil, err
+	}
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return 0, "", 0, nil, err
+		}
+
+		id, params, authenticated, scopedPDU, err := s.decodeV3(buf[:n], discovery)
+		if err != nil {
+			return 0, "", 0, nil, err
+		}
+
+		if id != messageID {
+			continue
+		}
+
+		if discovery && len(params.engineID) > 0 {
+			s.user.engineID = append([]byte{}, params.engineID...)
+		}
+		if len(params.engineID) > 0 {
+			s.user.setEngineTime(params.engineBoots, params.engineTime)
+		}
+
+		// Skip the context engine id and context name
+		_, scopedPDU, err = decodeExpected(scopedPDU, tagOctetString)
+		if err != nil {
+			return 0, "", 0, nil, err
+		}
+		_, scopedPDU, err = decodeExpected(scopedPDU, tagOctetString)
+		if err != nil {
+			return 0, "", 0, nil, err
+		}
+
+		pduTag, _, oid, tag, value, err := decodePDU(scopedPDU)
+		if err == nil && pduTag == tagGetResponse && s.user.flags()&flagAuth != 0 && !authenticated {
+			return 0, "", 0, nil, fmt.Errorf("unauthenticated response")
+		}
+		return pduTag, oid, tag, value, err
+	}
+}
+
+func (s *snmpBackend) encodeV3(messageID int32, pdu []byte, discovery bool) ([]byte, error) {
+	flags := byte(flagReportable)
+	params := securityParameters{}
+	if !discovery {
+		flags |= s.user.flags()
+		params.engineID = s.user.engineID
+		params.engineBoots, params.engineTime = s.user.currentEngineTime()
+		params.userName = []byte(s.user.name)
+	}
+
+	global := encodeTLV(tagSequence,
+		encodeInteger(int64(messageID)),
+		encodeInteger(maxMessageSize),
+		encodeOctetString([]byte{flags}),
+		encodeInteger(securityModelUSM))
+
+	msgData := encodeTLV(tagSequence,
+		encodeOctetString(params.engineID),
+		encodeOctetString([]byte(s.contextName)),
+		pdu)
+
+	if flags&flagPriv != 0 {
+		encrypted, salt, err := s.user.encrypt(msgData)
+		if err != nil {
+			return nil, err
+		}
+		msgData = encodeOctetString(encrypted)
+		params.privParams = salt
+	}
+
+	if flags&flagAuth != 0 {
+		params.authParams = make([]byte, authParamsSize)
+	}
+
+	encodedParams, authOffset := params.encode()
+	version := encodeInteger(3)
+	paramsString := encodeOctetString(encodedParams)
+
+	content := append(append(append(append([]byte{}, version...), global...), paramsString...), msgData...)
+	message := encodeTLV(tagSequence, content)
+
+	if flags&flagAuth != 0 {
+		offset := len(message) - len(content) + len(version) + len(global) + len(paramsString) - len(encodedParams) + authOffset
+		copy(message[offset:], s.user.sign(message))
+	}
+
+	return message, nil
+}
+
+// decodeV3 returns the message id, the security parameters, whether the
+// message is authenticated and the scoped pdu of a message, checking its
+// authentication and decrypting it
+func (s *snmpBackend) decodeV3(message []byte, discovery bool) (int32, *securityParameters, bool, []byte, error) {
+	content, _, err := decodeExpected(message, tagSequence)
+	if err != nil {
+		return 0, nil, false, nil, err
+	}
+
+	_, content, err = decodeExpected(content, tagInteger)
+	if err != nil {
+		return 0, nil, false, nil, err
+	}
+
+	global, content, err := decodeExpected(content, tagSequence)
+	if err != nil {
+		return 0, nil, false, nil, err
+	}
+
+	idContent, global, err := decodeExpected(global, tagInteger)
+	if err != nil {
+		return 0, nil, false, nil, err
+	}
+	_, global, err = decodeExpected(global, tagInteger)
+	if err != nil {
+		return 0, nil, false, nil, err
+	}
+	flagsContent, _, err := decodeExpected(global, tagOctetString)
+	if err != nil {
+		return 0, nil, false, nil, err
+	}
+
+	flags := byte(0)
+	if len(flagsContent) == 1 {
+		flags = flagsContent[0]
+	}
+
+	encodedParams, content, err := decodeExpected(content, tagOctetString)
+	if err != nil {
+		return 0, nil, false, nil, err
+	}
+
+	params, err := decodeSecurityParameters(encodedParams)
+	if err != nil {
+		return 0, nil, false, nil, err
+	}
+
+	if flags&flagAuth != 0 && !discovery {
+		if len(params.authParams) != authParamsSize {
+			return 0, nil, false, nil, fmt.Errorf("invalid authentication parameters")
+		}
+
+		// The parameters are a slice of message, their offset is the
+		// difference of the capacities
+		offset := cap(message) - cap(params.authParams)
+		received := append([]byte{}, params.authParams...)
+
+		zeroed := append([]byte{}, message...)
+		copy(zeroed[offset:offset+authParamsSize], make([]byte, authParamsSize))
+		if !hmac.Equal(s.user.sign(zeroed), received) {
+			return 0, nil, false, nil, fmt.Errorf("response authentication failed")
+		}
+	}
+
+	scopedPDU := content
+	if flags&flagPriv != 0 && !discovery {
+		encrypted, _, err := decodeExpected(content, tagOctetString)
+		if err != nil {
+			return 0, nil, false, nil, err
+		}
+
+		scopedPDU, err = s.user.decrypt(encrypted, params.engineBoots, params.engineTime, params.privParams)
+		if err != nil {
+			return 0, nil, false, nil, err
+		}
+	}
+
+	scopedPDU, _, err = decodeExpected(scopedPDU, tagSequence)
+	if err != nil {
+		return 0, nil, false, nil, err
+	}
+
+	return int32(decodeInteger(idContent)), params, flags&flagAuth != 0, scopedPDU, nil
+}
+
+// decodePDU returns the pdu type, request id, and the OID, value type and
+// value of the first variable binding
+func decodePDU(b []byte) (byte, int32, string, byte, []byte, error) {
+	pduTag, content, _, err := decodeTLV(b)
+	if err != nil {
+		return 0, 0, "", 0, nil, err
+	}
+
+	fields := make([]int64, 3)
+	for i := range fields {
+		var value []byte
+		value, content, err = decodeExpected(content, tagInteger)
+		if err != nil {
+			return 0, 0, "", 0, nil, err
+		}
+		fields[i] = decodeInteger(value)
+	}
+
+	if pduTag == tagGetResponse && fields[1] != 0 {
+		return 0, 0, "", 0, nil, fmt.Errorf("agent returned error status %d", fields[1])
+	}
+
+	varbinds, _, err := decodeExpected(content, tagSequence)
+	if err != nil {
+		return 0, 0, "", 0, nil, err
+	}
+
+	varbind, _, err := decodeExpected(varbinds, tagSequence)
+	if err != nil {
+		return 0, 0, "", 0, nil, err
+	}
+
+	oid, varbind, err := decodeExpected(varbind, tagOID)
+	if err != nil {
+		return 0, 0, "", 0, nil, err
+	}
+
+	tag, value, _, err := decodeTLV(varbind)
+	if err != nil {
+		return 0, 0, "", 0, nil, err
+	}
+
+	return pduTag, int32(fields[0]), decodeOIDString(oid), tag, value, nil
+}
+
+func (s *snmpBackend) decodeValue(tag byte, value []byte) (int64, error) {
+	switch tag {
+	case tagInteger:
+		return decodeInteger(value), nil
+	case tagCounter32, tagGauge32, tagTimeTicks, tagCounter64:
+		return int64(decodeUnsigned(value)), nil
+	case tagOctetString:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(string(value)), 10, 64)
+		if err != nil {
+			return -1, fmt.Errorf("SNMP value parsing error %s", err.Error())
+		}
+		return parsed, nil
+	case tagNoSuchObject, tagNoSuchInstance, tagEndOfMibView:
+		return -1, fmt.Errorf("oid %s does not exist on %s", s.oidString, s.address)
+	}
+
+	return -1, fmt.Errorf("oid %s on %s has the non numeric type 0x%x", s.oidString, s.address, tag)
+}
+
+// Close is a no-op as every poll uses its own socket
+func (s *snmpBackend) Close() error {
+	return nil
+}
+
+func formatOID(oid []uint32) string {
+	parts := make([]string, len(oid))
+	for i, arc := range oid {
+		parts[i] = strconv.FormatUint(uint64(arc), 10)
+	}
+	return strings.Join(parts, ".")
+}
+
+func decodeOIDString(content []byte) string {
+	if len(content) == 0 {
+		return ""
+	}
+
+	oid := []uint32{uint32(content[0]) / 40, uint32(content[0]) % 40}
+	arc := uint32(0)
+	for _, digit := range content[1:] {
+		arc = arc<<7 | uint32(digit&0x7f)
+		if digit&0x80 == 0 {
+			oid = append(oid, arc)
+			arc = 0
+		}
+	}
+	return formatOID(oid)
+}
@@ -0,0 +1,226 @@
+package snmp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"time"
+)
+
+// User based security model (RFC 3414) of SNMPv3 with HMAC-MD5-96 or
+// HMAC-SHA-96 authentication and AES-128 privacy (RFC 3826)
+
+const (
+	authProtocolMD5 = "MD5"
+	authProtocolSHA = "SHA"
+	privProtocolAES = "AES"
+
+	authParamsSize = 12
+
+	flagAuth       = 0x01
+	flagPriv       = 0x02
+	flagReportable = 0x04
+
+	securityModelUSM = 3
+)
+
+// usmUser holds the credentials of a user and the keys localized to the
+// engine of the agent
+type usmUser struct {
+	name         string
+	authProtocol string
+	authPassword string
+	privProtocol string
+	privPassword string
+
+	engineID    []byte
+	engineBoots int64
+	engineTime  int64
+	timeUpdated time.Time
+	authKey     []byte
+	privKey     []byte
+}
+
+// setEngineTime records the boots and time the agent reported
+func (u *usmUser) setEngineTime(boots int64, engineTime int64) {
+	u.engineBoots = boots
+	u.engineTime = engineTime
+	u.timeUpdated = time.Now()
+}
+
+// currentEngineTime returns the boots and the time of the agent, advanced
+// by the time since it was reported so requests stay in the time window
+func (u *usmUser) currentEngineTime() (int64, int64) {
+	if u.timeUpdated.IsZero() {
+		return u.engineBoots, u.engineTime
+	}
+	return u.engineBoots, u.engineTime + int64(time.Since(u.timeUpdated)/time.Second)
+}
+
+func (u *usmUser) flags() byte {
+	flags := byte(0)
+	if u.authPassword != "" {
+		flags |= flagAuth
+	}
+	if u.privPassword != "" {
+		flags |= flagPriv
+	}
+	return flags
+}
+
+func (u *usmUser) newHash() func() hash.Hash {
+	if u.authProtocol == authProtocolMD5 {
+		return md5.New
+	}
+	return sha1.New
+}
+
+// localize derives the keys of the user for the discovered engine
+func (u *usmUser) localize() {
+	if u.authPassword != "" {
+		u.authKey = localizeKey(u.newHash(), u.authPassword, u.engineID)
+	}
+
+	// AES-128 uses the first 16 bytes of the key localized with the
+	// authentication hash
+	if u.privPassword != "" {
+		u.privKey = localizeKey(u.newHash(), u.privPassword, u.engineID)[:16]
+	}
+}
+
+// localizeKey turns a password into a key by hashing a megabyte of the
+// repeated password and binding it to the engine id
+func localizeKey(newHash func() hash.Hash, password string, engineID []byte) []byte {
+	h := newHash()
+	buf := make([]byte, 64)
+	for written := 0; written < 1048576; written += len(buf) {
+		for i := range buf {
+			buf[i] = password[(written+i)%len(password)]
+		}
+		h.Write(buf)
+	}
+	key := h.Sum(nil)
+
+	h = newHash()
+	h.Write(key)
+	h.Write(engineID)
+	h.Write(key)
+	return h.Sum(nil)
+}
+
+// sign returns the authentication parameters of a whole message encoded
+// with zeroed authentication parameters
+func (u *usmUser) sign(message []byte) []byte {
+	mac := hmac.New(u.newHash(), u.authKey)
+	mac.Write(message)
+	return mac.Sum(nil)[:authParamsSize]
+}
+
+func (u *usmUser) aesIV(boots int64, engineTime int64, salt []byte) []byte {
+	iv := make([]byte, 16)
+	binary.BigEndian.PutUint32(iv[0:4], uint32(boots))
+	binary.BigEndian.PutUint32(iv[4:8], uint32(engineTime))
+	copy(iv[8:], salt)
+	return iv
+}
+
+// encrypt encrypts the scoped PDU and returns it with its salt
+func (u *usmUser) encrypt(scopedPDU []byte) ([]byte, []byte, error) {
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+
+	block, err := aes.NewCipher(u.privKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	boots, engineTime := u.currentEngineTime()
+	encrypted := make([]byte, len(scopedPDU))
+	cipher.NewCFBEncrypter(block, u.aesIV(boots, engineTime, salt)).XORKeyStream(encrypted, scopedPDU)
+	return encrypted, salt, nil
+}
+
+func (u *usmUser) decrypt(encrypted []byte, boots int64, engineTime int64, salt []byte) ([]byte, error) {
+	if len(salt) != 8 {
+		return nil, fmt.Errorf("invalid privacy parameters")
+	}
+
+	block, err := aes.NewCipher(u.privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	scopedPDU := make([]byte, len(encrypted))
+	cipher.NewCFBDecrypter(block, u.aesIV(boots, engineTime, salt)).XORKeyStream(scopedPDU, encrypted)
+	return scopedPDU, nil
+}
+
+// securityParameters are the USM fields of a message
+type securityParameters struct {
+	engineID    []byte
+	engineBoots int64
+	engineTime  int64
+	userName    []byte
+	authParams  []byte
+	privParams  []byte
+}
+
+// encode returns the encoded parameters and the offset of the
+// authentication parameters' content in them
+func (p *securityParameters) encode() ([]byte, int) {
+	head := append(encodeOctetString(p.engineID), encodeInteger(p.engineBoots)...)
+	head = append(head, encodeInteger(p.engineTime)...)
+	head = append(head, encodeOctetString(p.userName)...)
+
+	auth := encodeOctetString(p.authParams)
+	content := append(append(append([]byte{}, head...), auth...), encodeOctetString(p.privParams)...)
+
+	encoded := encodeTLV(tagSequence, content)
+	headerSize := len(encoded) - len(content)
+	return encoded, headerSize + len(head) + (len(auth) - len(p.authParams))
+}
+
+func decodeSecurityParameters(b []byte) (*securityParameters, error) {
+	content, _, err := decodeExpected(b, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &securityParameters{}
+	fields := []struct {
+		tag   byte
+		bytes *[]byte
+		int   *int64
+	}{
+		{tagOctetString, &p.engineID, nil},
+		{tagInteger, nil, &p.engineBoots},
+		{tagInteger, nil, &p.engineTime},
+		{tagOctetString, &p.userName, nil},
+		{tagOctetString, &p.authParams, nil},
+		{tagOctetString, &p.privParams, nil},
+	}
+
+	for _, field := range fields {
+		var value []byte
+		value, content, err = decodeExpected(content, field.tag)
+		if err != nil {
+			return nil, err
+		}
+
+		if field.bytes != nil {
+			*field.bytes = value
+		} else {
+			*field.int = decodeInteger(value)
+		}
+	}
+
+	return p, nil
+}
@@ -0,0 +1,105 @@
+package snmp
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"hash"
+	"testing"
+)
+
+// Password to key and key localization samples of RFC 3414 A.3
+var rfc3414EngineID = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2}
+
+func TestLocalizeKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		newHash func() hash.Hash
+		want    string
+	}{
+		{"MD5", md5.New, "526f5eed9fcce26f8964c2930787d82b"},
+		{"SHA", sha1.New, "6695febc9288e36282235fc7151f128497b38f3f"},
+	}
+
+	for _, test := range tests {
+		got := hex.EncodeToString(localizeKey(test.newHash, "maplesyrup", rfc3414EngineID))
+		if got != test.want {
+			t.Errorf("%s: got localized key %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	u := &usmUser{
+		authProtocol: authProtocolSHA,
+		authPassword: "maplesyrup",
+		privProtocol: privProtocolAES,
+		privPassword: "maplesyrup",
+		engineID:     rfc3414EngineID,
+	}
+	u.localize()
+
+	if got := hex.EncodeToString(u.authKey); got != "6695febc9288e36282235fc7151f128497b38f3f" {
+		t.Errorf("got authentication key %s", got)
+	}
+
+	// AES-128 takes the first 16 bytes of the localized key
+	if got := hex.EncodeToString(u.privKey); got != "6695febc9288e36282235fc7151f1284" {
+		t.Errorf("got privacy key %s", got)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Test case 1 of RFC 2202, truncated to HMAC-MD5-96 and HMAC-SHA-96
+	tests := []struct {
+		authProtocol string
+		keySize      int
+		want         string
+	}{
+		{authProtocolMD5, 16, "9294727a3638bb1c13f48ef8"},
+		{authProtocolSHA, 20, "b617318655057264e28bc0b6"},
+	}
+
+	for _, test := range tests {
+		u := &usmUser{
+			authProtocol: test.authProtocol,
+			authKey:      bytes.Repeat([]byte{0x0b}, test.keySize),
+		}
+		if got := hex.EncodeToString(u.sign([]byte("Hi There"))); got != test.want {
+			t.Errorf("%s: got authentication parameters %s, want %s", test.authProtocol, got, test.want)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	u := &usmUser{
+		authProtocol: authProtocolSHA,
+		privProtocol: privProtocolAES,
+		privPassword: "maplesyrup",
+		engineID:     rfc3414EngineID,
+	}
+	u.localize()
+	u.engineBoots, u.engineTime = 3, 1200
+
+	scopedPDU := []byte("scoped pdu of an arbitrary length")
+	encrypted, salt, err := u.encrypt(scopedPDU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(encrypted, scopedPDU) {
+		t.Fatalf("scoped PDU was not encrypted")
+	}
+
+	decrypted, err := u.decrypt(encrypted, 3, 1200, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, scopedPDU) {
+		t.Errorf("got %q, want %q", decrypted, scopedPDU)
+	}
+
+	if _, err := u.decrypt(encrypted, 3, 1200, salt[:4]); err == nil {
+		t.Errorf("expected an error for a short salt")
+	}
+}
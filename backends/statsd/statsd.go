@@ -0,0 +1,81 @@
+// Package statsd is the metric backend serving the last value of a gauge
+// received by the StatsD listener of the scaler
+package statsd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+// ScalerType is the scalerType metadata value of the statsd backend
+const ScalerType = "statsd"
+
+const metricName = "StatsDGauge"
+
+// Gauges holds the gauges received by the StatsD listener
+type Gauges interface {
+	// Gauge returns the last value of the gauge named name with the
+	// DogStatsD tags, which are matched regardless of their order
+	Gauge(name string, tags []string) (float64, bool)
+}
+
+// statsdBackend reports the last value pushed for the gauge named metric,
+// with tags selecting one of several DogStatsD series of the same name.
+// Fractional values are rounded with roundingMode. It fails until the gauge
+// was received once.
+type statsdBackend struct {
+	metric   string
+	tags     []string
+	rounding backends.RoundingMode
+	gauges   Gauges
+}
+
+// NewBackendFactory returns the factory of statsd backends reading gauges
+func NewBackendFactory(gauges Gauges) backends.BackendFactory {
+	return func(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+		if cfg.StatsDPort == 0 {
+			return nil, fmt.Errorf("statsd backend requires the statsdPort to be configured")
+		}
+
+		backend := statsdBackend{
+			metric: metadata["metric"],
+			tags:   backends.SplitItems(metadata["tags"]),
+			gauges: gauges,
+		}
+		if backend.metric == "" {
+			return nil, fmt.Errorf("no metric given")
+		}
+
+		var err error
+		backend.rounding, err = backends.ParseRoundingMode(metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		return &backend, nil
+	}
+}
+
+// MetricName returns the name of the gauge metric
+func (s *statsdBackend) MetricName() string {
+	return metricName
+}
+
+// GetMetricValue returns the last value pushed for the gauge
+func (s *statsdBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	value, ok := s.gauges.Gauge(s.metric, s.tags)
+	if !ok {
+		return -1, fmt.Errorf("no value received for statsd gauge %s", s.metric)
+	}
+
+	return s.rounding.Round(value), nil
+}
+
+// Close is a no-op, the gauge is kept for other scalers reading it
+func (s *statsdBackend) Close() error {
+	return nil
+}
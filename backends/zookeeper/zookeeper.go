@@ -0,0 +1,245 @@
+// Package zookeeper is the metric backend counting the children of a znode,
+// for work queues kept as ephemeral or sequential znodes
+package zookeeper
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	// ScalerType is the scalerType metadata value of the zookeeper backend
+	ScalerType = "zookeeper"
+	// DefaultServers is used by triggers which do not set servers
+	DefaultServers = "zookeeper.default.svc.cluster.local:2181"
+)
+
+const (
+	childCountMetricName = "ZookeeperChildCount"
+
+	defaultTimeout = 5 * time.Second
+	maxFrameBytes  = 1024 * 1024
+
+	// Operation codes and errors of the zookeeper protocol
+	opExists       = 3
+	opCloseSession = -11
+	errNoNode      = -101
+
+	existsXid = 1
+	closeXid  = 2
+)
+
+// zookeeperBackend reports the number of children of a znode. Every poll
+// opens a session with the first reachable server, reads the child count
+// from the znode's stat and closes the session again, so no session is held
+// between polls. A missing znode has no children.
+type zookeeperBackend struct {
+	servers []string
+	path    string
+	timeout time.Duration
+}
+
+// NewBackend creates a zookeeper backend from the trigger metadata
+func NewBackend(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	backend := zookeeperBackend{timeout: defaultTimeout}
+
+	backend.path = metadata["path"]
+	if backend.path == "" {
+		return nil, fmt.Errorf("no path given")
+	}
+
+	if !strings.HasPrefix(backend.path, "/") {
+		return nil, fmt.Errorf("path must be absolute")
+	}
+
+	if len(backend.path) > 1 {
+		backend.path = strings.TrimSuffix(backend.path, "/")
+	}
+
+	servers := DefaultServers
+	if val, ok := metadata["servers"]; ok && val != "" {
+		servers = val
+	}
+
+	backend.servers = backends.SplitItems(servers)
+	if len(backend.servers) == 0 {
+		return nil, fmt.Errorf("no servers given")
+	}
+
+	for _, server := range backend.servers {
+		if err := cfg.AllowEgress(server); err != nil {
+			return nil, err
+		}
+	}
+
+	if val, ok := metadata["timeout"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Timeout parsing error %s", err.Error())
+		}
+
+		if seconds <= 0 {
+			return nil, fmt.Errorf("timeout must be positive")
+		}
+
+		backend.timeout = time.Duration(seconds) * time.Second
+	}
+
+	return &backend, nil
+}
+
+// Endpoint returns the zookeeper servers
+func (z *zookeeperBackend) Endpoint() string {
+	return strings.Join(z.servers, ",")
+}
+
+// MetricName returns the name of the child count metric
+func (z *zookeeperBackend) MetricName() string {
+	return childCountMetricName
+}
+
+// GetMetricValue returns the number of children of the znode, trying the
+// servers in order
+func (z *zookeeperBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	var err error
+	for _, server := range z.servers {
+		var count int64
+		count, err = z.countChildren(ctx, server)
+		if err == nil {
+			return count, nil
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return -1, fmt.Errorf("zookeeper %s", err.Error())
+}
+
+func (z *zookeeperBackend) countChildren(ctx context.Context, server string) (int64, error) {
+	deadline := time.Now().Add(z.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return -1, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(deadline); err != nil {
+		return -1, err
+	}
+
+	r := bufio.NewReader(conn)
+
+	// Connect request: protocol version, last zxid seen, session timeout,
+	// session id and password, all zero for a new session
+	connect := make([]byte, 0, 44)
+	connect = appendInt32(connect, 0)
+	connect = appendInt64(connect, 0)
+	connect = appendInt32(connect, int32(z.timeout/time.Millisecond))
+	connect = appendInt64(connect, 0)
+	connect = appendBuffer(connect, make([]byte, 16))
+	if err := writeFrame(conn, connect); err != nil {
+		return -1, err
+	}
+
+	if _, err := readFrame(r); err != nil {
+		return -1, fmt.Errorf("session not established %s", err.Error())
+	}
+
+	// Closing the session right away removes its ephemeral state on the
+	// server instead of waiting for the session timeout
+	defer writeFrame(conn, appendInt32(appendInt32(nil, closeXid), opCloseSession))
+
+	exists := appendInt32(nil, existsXid)
+	exists = appendInt32(exists, opExists)
+	exists = appendBuffer(exists, []byte(z.path))
+	exists = append(exists, 0)
+	if err := writeFrame(conn, exists); err != nil {
+		return -1, err
+	}
+
+	reply, err := readFrame(r)
+	if err != nil {
+		return -1, err
+	}
+
+	// Reply header: xid, zxid and error code, followed by the stat whose
+	// last fields are the data length, child count and pzxid
+	if len(reply) < 16 {
+		return -1, fmt.Errorf("short reply from %s", server)
+	}
+
+	switch code := int32(binary.BigEndian.Uint32(reply[12:16])); code {
+	case 0:
+	case errNoNode:
+		return 0, nil
+	default:
+		return -1, fmt.Errorf("%s returned error %d for %s", server, code, z.path)
+	}
+
+	stat := reply[16:]
+	if len(stat) < 68 {
+		return -1, fmt.Errorf("short stat from %s", server)
+	}
+
+	return int64(int32(binary.BigEndian.Uint32(stat[56:60]))), nil
+}
+
+// Close is a no-op as no connection is held between polls
+func (z *zookeeperBackend) Close() error {
+	return nil
+}
+
+func appendInt32(b []byte, v int32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendInt64(b []byte, v int64) []byte {
+	return appendInt32(appendInt32(b, int32(v>>32)), int32(v))
+}
+
+func appendBuffer(b []byte, data []byte) []byte {
+	return append(appendInt32(b, int32(len(data))), data...)
+}
+
+// writeFrame writes a length prefixed packet
+func writeFrame(w io.Writer, payload []byte) error {
+	_, err := w.Write(append(appendInt32(nil, int32(len(payload))), payload...))
+	return err
+}
+
+// readFrame reads a length prefixed packet
+func readFrame(r io.Reader) ([]byte, error) {
+	var size int32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+
+	if size < 0 || size > maxFrameBytes {
+		return nil, fmt.Errorf("frame of %d bytes", size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
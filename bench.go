@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/client"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+	"github.com/patnaikshekhar/keda_external_scaler/server"
+)
+
+const (
+	benchNamespace = "bench"
+	// maxBenchRate keeps the tick interval of the rate above zero
+	maxBenchRate = 1000000
+)
+
+// runBench registers synthetic static scalers on a target server and drives
+// IsActive and GetMetrics calls against them at a fixed rate, then prints the
+// latency percentiles. The target needs staticBackend in its config.
+func runBench(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	address := flags.String("address", fmt.Sprintf("localhost:%d", config.DefaultPort), "address of the scaler server")
+	useTLS := flags.Bool("tls", true, "connect using TLS")
+	caFile := flags.String("ca-file", "", "CA certificate used to verify the server")
+	insecureSkipVerify := flags.Bool("insecure-skip-verify", false, "skip verification of the server certificate")
+	scalers := flags.Int("scalers", 10, "number of synthetic scalers to register")
+	rate := flags.Int("rps", 100, "requests per second")
+	duration := flags.Duration("duration", 30*time.Second, "duration of the benchmark")
+	concurrency := flags.Int("concurrency", 64, "maximum number of requests in flight")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *scalers <= 0 || *rate <= 0 || *concurrency <= 0 {
+		return fmt.Errorf("scalers, rps and concurrency must be positive")
+	}
+
+	if *rate > maxBenchRate {
+		return fmt.Errorf("rps must not exceed %d", maxBenchRate)
+	}
+
+	// Retries would hide the latency of shed calls, they count as failures
+	scalerClient, err := client.Dial(*address, client.Options{
+		Insecure:           !*useTLS,
+		CAFile:             *caFile,
+		InsecureSkipVerify: *insecureSkipVerify,
+		Retries:            -1,
+	})
+	if err != nil {
+		return err
+	}
+	defer scalerClient.Close()
+
+	ctx := context.Background()
+
+	refs := make([]*pb.ScaledObjectRef, *scalers)
+	for i := range refs {
+		refs[i] = client.Ref(benchNamespace, fmt.Sprintf("bench-%d", i))
+
+		err := scalerClient.Register(ctx, refs[i], map[string]string{
+			"scalerType": server.StaticScalerType,
+			"value":      strconv.Itoa(i),
+		})
+		if err != nil {
+			return fmt.Errorf("registering %s failed %s", refs[i].Name, err.Error())
+		}
+	}
+
+	defer func() {
+		for _, ref := range refs {
+			scalerClient.Unregister(ctx, ref)
+		}
+	}()
+
+	var mu sync.Mutex
+	var latencies []time.Duration
+	var failures int
+
+	var wg sync.WaitGroup
+	inFlight := make(chan struct{}, *concurrency)
+	ticker := time.NewTicker(time.Second / time.Duration(*rate))
+	defer ticker.Stop()
+
+	start := time.Now()
+	deadline := time.After(*duration)
+
+	for i := 0; ; i++ {
+		select {
+		case <-deadline:
+			wg.Wait()
+			elapsed := time.Since(start)
+			printBenchReport(latencies, failures, elapsed)
+			return nil
+		case <-ticker.C:
+		}
+
+		inFlight <- struct{}{}
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+
+			ref := refs[i%len(refs)]
+			callStart := time.Now()
+
+			var err error
+			if i%2 == 0 {
+				_, err = scalerClient.IsActive(ctx, ref)
+			} else {
+				_, err = scalerClient.MetricValue(ctx, ref, server.StaticMetricName)
+			}
+
+			latency := time.Since(callStart)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				failures++
+				return
+			}
+			latencies = append(latencies, latency)
+		}(i)
+	}
+}
+
+func printBenchReport(latencies []time.Duration, failures int, elapsed time.Duration) {
+	total := len(latencies) + failures
+	fmt.Printf("requests:   %d\n", total)
+	fmt.Printf("failures:   %d\n", failures)
+	fmt.Printf("throughput: %.1f req/s\n", float64(total)/elapsed.Seconds())
+
+	if len(latencies) == 0 {
+		return
+	}
+
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+
+	for _, p := range []float64{50, 90, 95, 99} {
+		fmt.Printf("p%-9.0f %s\n", p, percentile(latencies, p))
+	}
+	fmt.Printf("max        %s\n", latencies[len(latencies)-1])
+}
+
+// percentile returns the p-th percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	index := int(float64(len(sorted)-1) * p / 100)
+	return sorted[index]
+}
@@ -0,0 +1,217 @@
+// Package client is a Go client for the external scaler. It wraps the
+// generated gRPC stubs with TLS setup, retries of transient failures and
+// helpers taking plain values, for tools which need to talk to the scaler
+// the way KEDA does.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultRetries = 3
+	defaultBackoff = 200 * time.Millisecond
+)
+
+// Options configure the connection to the scaler
+type Options struct {
+	// Insecure connects in plaintext, for listeners with tls set to none
+	Insecure bool
+	// CAFile verifies the server certificate, the system pool is used when
+	// it is empty
+	CAFile string
+	// ServerName overrides the name the server certificate is verified
+	// against
+	ServerName string
+	// InsecureSkipVerify skips verification of the server certificate
+	InsecureSkipVerify bool
+	// CertFile and KeyFile are the client certificate for mtls listeners
+	CertFile string
+	KeyFile  string
+
+	// Retries is how often a call failing with Unavailable, which the
+	// scaler returns while it sheds load, is retried. Zero uses the default,
+	// a negative value disables retries.
+	Retries int
+	// Backoff is the wait before the first retry, doubled for every further
+	// retry. Zero uses the default.
+	Backoff time.Duration
+}
+
+// Client is a connection to the scaler
+type Client struct {
+	conn   *grpc.ClientConn
+	scaler pb.ExternalScalerClient
+}
+
+// Dial connects to the scaler at address
+func Dial(address string, opts Options) (*Client, error) {
+	dialOption := grpc.WithInsecure()
+	if !opts.Insecure {
+		tlsConfig, err := opts.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		dialOption = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+	}
+
+	conn, err := grpc.Dial(address, dialOption, grpc.WithUnaryInterceptor(opts.retryInterceptor()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		conn:   conn,
+		scaler: pb.NewExternalScalerClient(conn),
+	}, nil
+}
+
+func (o *Options) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if o.CAFile != "" {
+		ca, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", o.CAFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// retryInterceptor retries calls failing with Unavailable with an
+// exponential backoff, as long as the context allows
+func (o *Options) retryInterceptor() grpc.UnaryClientInterceptor {
+	retries := o.Retries
+	if retries == 0 {
+		retries = defaultRetries
+	}
+
+	backoff := o.Backoff
+	if backoff == 0 {
+		backoff = defaultBackoff
+	}
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		wait := backoff
+		for attempt := 0; ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil || attempt >= retries || status.Code(err) != codes.Unavailable {
+				return err
+			}
+
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(wait):
+			}
+			wait *= 2
+		}
+	}
+}
+
+// Scaler returns the generated client for calls not covered by the helpers
+func (c *Client) Scaler() pb.ExternalScalerClient {
+	return c.scaler
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Ref returns the reference to a ScaledObject
+func Ref(namespace string, name string) *pb.ScaledObjectRef {
+	return &pb.ScaledObjectRef{
+		Namespace: namespace,
+		Name:      name,
+	}
+}
+
+// Register registers a scaler with the trigger metadata, replacing the
+// scaler registered for the same ScaledObject
+func (c *Client) Register(ctx context.Context, ref *pb.ScaledObjectRef, metadata map[string]string) error {
+	_, err := c.scaler.New(ctx, &pb.NewRequest{
+		ScaledObjectRef: ref,
+		Metadata:        metadata,
+	})
+	return err
+}
+
+// Unregister removes a scaler
+func (c *Client) Unregister(ctx context.Context, ref *pb.ScaledObjectRef) error {
+	_, err := c.scaler.Close(ctx, ref)
+	return err
+}
+
+// IsActive reports whether the scaler is active
+func (c *Client) IsActive(ctx context.Context, ref *pb.ScaledObjectRef) (bool, error) {
+	resp, err := c.scaler.IsActive(ctx, ref)
+	if err != nil {
+		return false, err
+	}
+	return resp.Result, nil
+}
+
+// MetricSpec returns the metric name and target size of the scaler
+func (c *Client) MetricSpec(ctx context.Context, ref *pb.ScaledObjectRef) (string, int64, error) {
+	resp, err := c.scaler.GetMetricSpec(ctx, ref)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if len(resp.MetricSpecs) == 0 {
+		return "", 0, fmt.Errorf("no metric spec returned for %s/%s", ref.Namespace, ref.Name)
+	}
+
+	return resp.MetricSpecs[0].MetricName, resp.MetricSpecs[0].TargetSize, nil
+}
+
+// MetricValue returns the value of the named metric of the scaler
+func (c *Client) MetricValue(ctx context.Context, ref *pb.ScaledObjectRef, metricName string) (int64, error) {
+	resp, err := c.scaler.GetMetrics(ctx, &pb.GetMetricsRequest{
+		ScaledObjectRef: ref,
+		MetricName:      metricName,
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	for _, value := range resp.MetricValues {
+		if value.MetricName == metricName {
+			return value.MetricValue, nil
+		}
+	}
+
+	return -1, fmt.Errorf("no value returned for %s", metricName)
+}
@@ -0,0 +1,459 @@
+// Package config loads the server wide settings of the scaler
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"google.golang.org/grpc/credentials"
+)
+
+// Environment variables naming directories, which startup checks refer to
+const (
+	CertPathEnv  = "CERT_PATH"
+	PluginDirEnv = "PLUGIN_DIR"
+	ExecDirEnv   = "EXEC_DIR"
+	SecretDirEnv = "SECRET_DIR"
+)
+
+const (
+	configFileEnv = "CONFIG_FILE"
+	logLevelEnv   = "LOG_LEVEL"
+
+	// minPollIntervalEnv names the environment variable holding the minimum
+	// duration between two backend queries of the same client for the same
+	// scaler. Polls arriving faster than this are answered from the last
+	// observed value.
+	minPollIntervalEnv = "MIN_POLL_INTERVAL"
+
+	// DefaultPort is the port of the default listener
+	DefaultPort = 8080
+
+	defaultLogLevel        = "info"
+	defaultAdminPort       = 9090
+	defaultShutdownTimeout = 30 * time.Second
+
+	// TLS modes of a listener
+	TLSModeNone   = "none"
+	TLSModeServer = "tls"
+	TLSModeMutual = "mtls"
+)
+
+// Config holds the server wide settings. It is built from environment
+// variables and optionally overridden by the JSON file named in CONFIG_FILE.
+type Config struct {
+	MinPollInterval Duration         `json:"minPollInterval"`
+	Listeners       []ListenerConfig `json:"listeners"`
+	PluginDir       string           `json:"pluginDir"`
+	ExecDir         string           `json:"execDir"`
+	LogLevel        string           `json:"logLevel"`
+
+	// SecretDir holds the files trigger metadata may read credentials from,
+	// such as a mounted Secret, see passwordFile of the redis backend
+	SecretDir string `json:"secretDir"`
+
+	// AdminPort serves the HTTP admin API and self-metrics, zero disables it
+	AdminPort int `json:"adminPort"`
+	// AdminFallbackPort is used when AdminPort is already taken
+	AdminFallbackPort int `json:"adminFallbackPort"`
+	// AdminTokenFile holds the bearer token required by the admin API
+	// requests which change the server, such as PUT /loglevel. They are
+	// rejected while it is not set.
+	AdminTokenFile string `json:"adminTokenFile"`
+
+	// StatsDPort receives StatsD and DogStatsD gauges over UDP for the statsd
+	// backend, zero disables it. It is read at startup only.
+	StatsDPort int `json:"statsdPort"`
+
+	// MemorySoftLimitMB and MemoryHardLimitMB enable load shedding, zero
+	// disables the limit
+	MemorySoftLimitMB int `json:"memorySoftLimitMB"`
+	MemoryHardLimitMB int `json:"memoryHardLimitMB"`
+
+	Cost CostConfig `json:"cost"`
+
+	// EgressAllowlist restricts the hosts backends may connect to, see
+	// allowEgress
+	EgressAllowlist []string `json:"egressAllowlist"`
+
+	// MetadataEnv lists the environment variables trigger metadata may
+	// reference, see LookupMetadataEnv
+	MetadataEnv []string `json:"metadataEnv"`
+
+	// MaxEndpointsPerNamespace limits the distinct backend endpoints the
+	// scalers of a namespace may use, zero disables the limit
+	MaxEndpointsPerNamespace int `json:"maxEndpointsPerNamespace"`
+
+	// OTLP pushes the self-metrics to an OpenTelemetry collector
+	OTLP OTLPConfig `json:"otlp"`
+
+	// ObservationStream records every metric observation in a redis stream
+	ObservationStream ObservationStreamConfig `json:"observationStream"`
+
+	// ScaledObjectAnnotations enables overrides read from the annotations of
+	// the ScaledObjects, see annotationOverrides
+	ScaledObjectAnnotations bool `json:"scaledObjectAnnotations"`
+
+	// StaticBackend enables the static scalerType the bench command
+	// registers. It reports any value the trigger metadata asks for, so it
+	// is disabled unless the server is load tested.
+	StaticBackend bool `json:"staticBackend"`
+
+	// MaxProcs sets GOMAXPROCS, zero sizes it to the cgroup CPU quota
+	MaxProcs int `json:"maxProcs"`
+
+	// BackendTimeouts bounds the backend calls of a poll per scalerType, such
+	// as {"redis": "500ms", "webhook": "5s"}, as backends have very
+	// different latency profiles. Types without an entry are not bounded
+	// beyond their own timeouts.
+	BackendTimeouts map[string]Duration `json:"backendTimeouts"`
+
+	// RedisTimeouts are the default connection timeouts of the redis
+	// backends, the dialTimeout, readTimeout and writeTimeout metadata keys
+	// override them per scaler
+	RedisTimeouts RedisTimeoutsConfig `json:"redisTimeouts"`
+
+	// RedisPool is the default connection pool of the redis backends, the
+	// poolSize, minIdleConns, idleTimeout and keepaliveInterval metadata keys
+	// override it per scaler
+	RedisPool RedisPoolConfig `json:"redisPool"`
+
+	// Vault is the Vault server the redis backends may read their
+	// credentials from
+	Vault VaultConfig `json:"vault"`
+
+	// AzureAD enables the azureAuth aad metadata key of the redis backends
+	AzureAD AzureADConfig `json:"azureAD"`
+
+	// ElastiCacheIAM enables the iamAuth metadata key of the redis backends
+	ElastiCacheIAM ElastiCacheIAMConfig `json:"elastiCacheIAM"`
+
+	// ShutdownTimeout bounds how long in-flight RPCs are drained before the
+	// servers are stopped forcefully
+	ShutdownTimeout Duration `json:"shutdownTimeout"`
+}
+
+// CostConfig holds the server wide replica pricing. ReplicaCostPerHour is a
+// static price, PricingURL an endpoint answering GET with JSON of the form
+// {"replicaCostPerHour": 0.12} which takes precedence when reachable.
+type CostConfig struct {
+	ReplicaCostPerHour float64  `json:"replicaCostPerHour"`
+	PricingURL         string   `json:"pricingURL"`
+	RefreshInterval    Duration `json:"refreshInterval"`
+}
+
+// OTLPConfig configures pushing the self-metrics to an OpenTelemetry
+// collector with OTLP/HTTP using the JSON encoding. Endpoint is the base URL
+// of the collector, e.g. http://otel-collector:4318, an empty endpoint
+// disables the export.
+type OTLPConfig struct {
+	Endpoint string            `json:"endpoint"`
+	Interval Duration          `json:"interval"`
+	Headers  map[string]string `json:"headers" secret:"true"`
+}
+
+// ObservationStreamConfig configures appending every metric observation to
+// a redis stream capped at about MaxLen entries, for offline analysis of the
+// scaling behavior. An empty address disables it. It is read at startup only.
+type ObservationStreamConfig struct {
+	Address  string `json:"address"`
+	Password string `json:"password" secret:"true"`
+	Stream   string `json:"stream"`
+	MaxLen   int64  `json:"maxLen"`
+}
+
+// RedisTimeoutsConfig holds the connection timeouts of the redis backends.
+// Zero values keep the go-redis defaults.
+type RedisTimeoutsConfig struct {
+	DialTimeout  Duration `json:"dialTimeout"`
+	ReadTimeout  Duration `json:"readTimeout"`
+	WriteTimeout Duration `json:"writeTimeout"`
+}
+
+// RedisPoolConfig sizes the connection pools of the redis backends. Zero
+// values keep the go-redis defaults. KeepaliveInterval PINGs the pooled
+// connections in the background, zero disables it.
+type RedisPoolConfig struct {
+	PoolSize          int      `json:"poolSize"`
+	MinIdleConns      int      `json:"minIdleConns"`
+	IdleTimeout       Duration `json:"idleTimeout"`
+	KeepaliveInterval Duration `json:"keepaliveInterval"`
+}
+
+// VaultConfig names the Vault server of the vaultPath metadata key of the
+// redis backends, an empty address disables it. The scaler logs in with its
+// own service account token through the kubernetes auth method mounted at
+// AuthMount, so the server is chosen by the operator and trigger metadata
+// only picks the role and the path.
+type VaultConfig struct {
+	Address   string `json:"address"`
+	AuthMount string `json:"authMount"`
+}
+
+// AzureADConfig allows the redis backends to authenticate to Azure Cache for
+// Redis with an Entra ID (AAD) token of the scaler's own identity. As the
+// token is sent to the address of the trigger, it is disabled by default and
+// only sent to hosts of *.redis.cache.windows.net,
+// *.redisenterprise.cache.azure.net or the hostname patterns of
+// AllowedHosts.
+type AzureADConfig struct {
+	Enabled      bool     `json:"enabled"`
+	AllowedHosts []string `json:"allowedHosts"`
+}
+
+// ElastiCacheIAMConfig lists the ElastiCache replication groups the redis
+// backends may authenticate to with IAM. The connect tokens are signed with
+// the scaler's own AWS role, so IAM authentication is disabled until the
+// operator lists the groups tenants may use.
+type ElastiCacheIAMConfig struct {
+	ReplicationGroups []string `json:"replicationGroups"`
+}
+
+// ListenerConfig is a listener profile. Every profile gets its own gRPC
+// server but all of them share the same scaler registry. FallbackPort is used
+// when Port is already taken.
+type ListenerConfig struct {
+	Name         string `json:"name"`
+	Port         int    `json:"port"`
+	FallbackPort int    `json:"fallbackPort"`
+	TLS          string `json:"tls"`
+	CertPath     string `json:"certPath"`
+	ClientCAFile string `json:"clientCAFile"`
+}
+
+// Duration is a time.Duration which is read from JSON as a string like "5s"
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var val string
+	if err := json.Unmarshal(data, &val); err != nil {
+		return fmt.Errorf("duration must be a string %s", err.Error())
+	}
+
+	parsed, err := time.ParseDuration(val)
+	if err != nil {
+		return err
+	}
+
+	d.Duration = parsed
+	return nil
+}
+
+// Load builds the config from the environment and the config file
+func Load() (*Config, error) {
+	cfg := Config{
+		Listeners: []ListenerConfig{
+			{
+				Name:     "default",
+				Port:     DefaultPort,
+				TLS:      TLSModeServer,
+				CertPath: os.Getenv(CertPathEnv),
+			},
+		},
+		PluginDir:       os.Getenv(PluginDirEnv),
+		ExecDir:         os.Getenv(ExecDirEnv),
+		SecretDir:       os.Getenv(SecretDirEnv),
+		LogLevel:        defaultLogLevel,
+		AdminPort:       defaultAdminPort,
+		ShutdownTimeout: Duration{defaultShutdownTimeout},
+	}
+
+	if val := os.Getenv(logLevelEnv); val != "" {
+		cfg.LogLevel = val
+	}
+
+	if val := os.Getenv(minPollIntervalEnv); val != "" {
+		minPollInterval, err := time.ParseDuration(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s parsing error %s", minPollIntervalEnv, err.Error())
+		}
+
+		cfg.MinPollInterval.Duration = minPollInterval
+	}
+
+	if path := os.Getenv(configFileEnv); path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("Config file parsing error %s", err.Error())
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	if len(c.Listeners) == 0 {
+		return fmt.Errorf("no listeners configured")
+	}
+
+	if _, err := log.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("unknown log level %s", c.LogLevel)
+	}
+
+	if err := validateEgressAllowlist(c.EgressAllowlist); err != nil {
+		return err
+	}
+
+	if c.MaxEndpointsPerNamespace < 0 {
+		return fmt.Errorf("maxEndpointsPerNamespace must not be negative")
+	}
+
+	if c.StatsDPort < 0 || c.StatsDPort > 65535 {
+		return fmt.Errorf("statsdPort must be between 0 and 65535")
+	}
+
+	if c.MaxProcs < 0 {
+		return fmt.Errorf("maxProcs must not be negative")
+	}
+
+	for scalerType, timeout := range c.BackendTimeouts {
+		if timeout.Duration <= 0 {
+			return fmt.Errorf("backend timeout of %s must be positive", scalerType)
+		}
+	}
+
+	if c.RedisTimeouts.DialTimeout.Duration < 0 || c.RedisTimeouts.ReadTimeout.Duration < 0 || c.RedisTimeouts.WriteTimeout.Duration < 0 {
+		return fmt.Errorf("redis timeouts must not be negative")
+	}
+
+	if c.RedisPool.PoolSize < 0 || c.RedisPool.MinIdleConns < 0 || c.RedisPool.IdleTimeout.Duration < 0 || c.RedisPool.KeepaliveInterval.Duration < 0 {
+		return fmt.Errorf("redis pool settings must not be negative")
+	}
+
+	if c.RedisPool.PoolSize > 0 && c.RedisPool.MinIdleConns > c.RedisPool.PoolSize {
+		return fmt.Errorf("redis pool minIdleConns must not exceed poolSize")
+	}
+
+	if c.PluginDir != "" && !pluginsSupported {
+		return fmt.Errorf("pluginDir is set but this server was built without cgo and cannot load plugins, build it with CGO=1 to use them")
+	}
+
+	if c.Vault.Address != "" {
+		parsed, err := url.Parse(c.Vault.Address)
+		if err != nil {
+			return fmt.Errorf("Vault address parsing error %s", err.Error())
+		}
+
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			return fmt.Errorf("vault address must use http or https")
+		}
+	}
+
+	if err := validateHostPatterns("azureAD allowedHosts", c.AzureAD.AllowedHosts); err != nil {
+		return err
+	}
+
+	if c.ObservationStream.MaxLen < 0 {
+		return fmt.Errorf("observation stream maxLen must not be negative")
+	}
+
+	if c.MemorySoftLimitMB < 0 || c.MemoryHardLimitMB < 0 {
+		return fmt.Errorf("memory limits must not be negative")
+	}
+
+	if c.MemorySoftLimitMB > 0 && c.MemoryHardLimitMB > 0 && c.MemorySoftLimitMB > c.MemoryHardLimitMB {
+		return fmt.Errorf("memory soft limit must not exceed the hard limit")
+	}
+
+	ports := make(map[int]string)
+	if c.AdminPort != 0 {
+		ports[c.AdminPort] = "admin"
+		if c.AdminFallbackPort != 0 {
+			if c.AdminFallbackPort == c.AdminPort {
+				return fmt.Errorf("admin fallback port must differ from the admin port")
+			}
+			ports[c.AdminFallbackPort] = "admin"
+		}
+	}
+
+	for i := range c.Listeners {
+		listener := &c.Listeners[i]
+
+		if listener.Name == "" {
+			listener.Name = fmt.Sprintf("listener-%d", i)
+		}
+
+		if listener.TLS == "" {
+			listener.TLS = TLSModeServer
+		}
+
+		switch listener.TLS {
+		case TLSModeNone, TLSModeServer:
+		case TLSModeMutual:
+			if listener.ClientCAFile == "" {
+				return fmt.Errorf("listener %s uses mtls but has no clientCAFile", listener.Name)
+			}
+		default:
+			return fmt.Errorf("listener %s has unknown tls mode %s", listener.Name, listener.TLS)
+		}
+
+		listenerPorts := []int{listener.Port}
+		if listener.FallbackPort != 0 {
+			listenerPorts = append(listenerPorts, listener.FallbackPort)
+		}
+
+		for _, port := range listenerPorts {
+			if other, ok := ports[port]; ok {
+				return fmt.Errorf("listeners %s and %s both use port %d", other, listener.Name, port)
+			}
+			ports[port] = listener.Name
+		}
+	}
+
+	return nil
+}
+
+// Credentials returns the transport credentials for the listener or nil when
+// the listener serves plaintext
+func (l *ListenerConfig) Credentials() (credentials.TransportCredentials, error) {
+	if l.TLS == TLSModeNone {
+		return nil, nil
+	}
+
+	certFile := fmt.Sprintf("%s/server.crt", l.CertPath)
+	keyFile := fmt.Sprintf("%s/server.key", l.CertPath)
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if l.TLS == TLSModeMutual {
+		ca, err := ioutil.ReadFile(l.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", l.ClientCAFile)
+		}
+
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
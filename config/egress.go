@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// AllowEgress checks that a backend may connect to address, a host with an
+// optional port, against the egressAllowlist of the config. Entries are
+// CIDRs, matched against the literal or resolved IP addresses of the host,
+// or hostname patterns such as *.cache.windows.net, matched against the host
+// name. An empty allowlist allows every host.
+//
+// The check runs at registration. It keeps tenants from pointing a shared
+// scaler at internal services through trigger metadata, but a host name
+// resolving to other addresses later is not caught.
+func (c *Config) AllowEgress(address string) error {
+	if len(c.EgressAllowlist) == 0 {
+		return nil
+	}
+
+	host := hostOf(address)
+
+	var networks []*net.IPNet
+	for _, entry := range c.EgressAllowlist {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			networks = append(networks, network)
+			continue
+		}
+
+		if matched, _ := path.Match(strings.ToLower(entry), host); matched {
+			return nil
+		}
+	}
+
+	if len(networks) > 0 {
+		ips := []net.IP{net.ParseIP(host)}
+		if ips[0] == nil {
+			addrs, err := net.LookupIP(host)
+			if err != nil {
+				return fmt.Errorf("host %s is not in the egress allowlist and could not be resolved %s", host, err.Error())
+			}
+			ips = addrs
+		}
+
+		if len(ips) > 0 && allInNetworks(ips, networks) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("host %s is not in the egress allowlist", host)
+}
+
+// AllowEgressURL checks the host of a URL with AllowEgress
+func (c *Config) AllowEgressURL(u *url.URL) error {
+	return c.AllowEgress(u.Host)
+}
+
+// MatchHost reports whether the host of address, a host with an optional
+// port, matches one of the hostname patterns, such as
+// *.redis.cache.windows.net
+func MatchHost(patterns []string, address string) bool {
+	host := hostOf(address)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), host); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func hostOf(address string) string {
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+func allInNetworks(ips []net.IP, networks []*net.IPNet) bool {
+	for _, ip := range ips {
+		inside := false
+		for _, network := range networks {
+			if network.Contains(ip) {
+				inside = true
+				break
+			}
+		}
+
+		if !inside {
+			return false
+		}
+	}
+	return true
+}
+
+// validateHostPatterns rejects entries which are not valid hostname patterns
+func validateHostPatterns(name string, entries []string) error {
+	for _, entry := range entries {
+		if _, err := path.Match(entry, ""); err != nil || entry == "" || strings.ContainsAny(entry, "/:") {
+			return fmt.Errorf("%s entry %s is not a hostname pattern", name, entry)
+		}
+	}
+	return nil
+}
+
+// validateEgressAllowlist rejects entries which are neither a CIDR nor a
+// valid hostname pattern
+func validateEgressAllowlist(entries []string) error {
+	for _, entry := range entries {
+		if _, _, err := net.ParseCIDR(entry); err == nil {
+			continue
+		}
+
+		if _, err := path.Match(entry, ""); err != nil || entry == "" || strings.ContainsAny(entry, "/:") {
+			return fmt.Errorf("egress allowlist entry %s is neither a CIDR nor a hostname pattern", entry)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import "testing"
+
+func TestAllowEgress(t *testing.T) {
+	cfg := &Config{EgressAllowlist: []string{"10.0.0.0/8", "*.cache.windows.net", "redis.example.com"}}
+
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"10.1.2.3:6379", true},
+		{"10.1.2.3", true},
+		{"[::ffff:10.1.2.3]:6379", true},
+		{"192.168.1.1:6379", false},
+		{"tenant.cache.windows.net:6380", true},
+		{"TENANT.Cache.Windows.Net.:6380", true},
+		{"cache.windows.net:6380", false},
+		{"redis.example.com", true},
+		{"redis.example.com.evil.io:6379", false},
+	}
+
+	for _, test := range tests {
+		err := cfg.AllowEgress(test.address)
+		if allowed := err == nil; allowed != test.allowed {
+			t.Errorf("%s: got allowed %t, want %t (%v)", test.address, allowed, test.allowed, err)
+		}
+	}
+
+	if err := (&Config{}).AllowEgress("192.168.1.1:6379"); err != nil {
+		t.Errorf("expected an empty allowlist to allow every host, got %s", err.Error())
+	}
+}
+
+func TestValidateEgressAllowlist(t *testing.T) {
+	tests := []struct {
+		entry string
+		valid bool
+	}{
+		{"10.0.0.0/8", true},
+		{"fd00::/8", true},
+		{"*.cache.windows.net", true},
+		{"redis.example.com", true},
+		{"", false},
+		{"10.0.0.0/33", false},
+		{"redis.example.com:6379", false},
+		{"https://redis.example.com", false},
+		{"[redis", false},
+	}
+
+	for _, test := range tests {
+		err := validateEgressAllowlist([]string{test.entry})
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("%q: got valid %t, want %t", test.entry, valid, test.valid)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// LookupMetadataEnv returns the value of the environment variable name,
+// which trigger metadata references with keys like passwordFromEnv so
+// secrets stay out of ScaledObjects. Only variables listed in MetadataEnv
+// can be read, anything else in the scaler's environment, such as its own
+// credentials, is off limits to ScaledObject authors.
+func (c *Config) LookupMetadataEnv(name string) (string, error) {
+	allowed := false
+	for _, entry := range c.MetadataEnv {
+		if entry == name {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed {
+		return "", fmt.Errorf("environment variable %s is not listed in metadataEnv", name)
+	}
+
+	val, ok := os.LookupEnv(name)
+	if !ok || val == "" {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	return val, nil
+}
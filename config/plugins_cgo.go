@@ -0,0 +1,8 @@
+//go:build cgo
+// +build cgo
+
+package config
+
+// pluginsSupported reports whether the server can load Go plugins, which
+// needs cgo
+const pluginsSupported = true
@@ -0,0 +1,8 @@
+//go:build !cgo
+// +build !cgo
+
+package config
+
+// pluginsSupported reports whether the server can load Go plugins. Without
+// cgo plugin.Open always fails, so a pluginDir is rejected at load time.
+const pluginsSupported = false
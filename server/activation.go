@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+	"google.golang.org/grpc/status"
+)
+
+const activationMetadataPrefix = "activation"
+
+// activationInheritedKeys are copied from the scaler's metadata when a
+// prefixed backend does not set them, so a cheap check against the same
+// redis needs only its own mode key
+var activationInheritedKeys = []string{
+	"address", "addressFailover", "username", "password", "databaseIndex", "pauseKey",
+	"addressFromEnv", "passwordFromEnv", "passwordFile",
+	"enableTLS", "tlsServerName", "tlsCAFile", "insecureSkipVerify",
+	"tlsCert", "tlsKey", "tlsCertFile", "tlsKeyFile",
+	"dialTimeout", "readTimeout", "writeTimeout", "proxyURL",
+	"sshTunnelHost", "sshTunnelUser", "sshTunnelKeyFile", "sshTunnelKnownHostsFile",
+	"vaultPath", "vaultRole", "vaultAuthMethod", "vaultToken",
+	"iamAuth", "iamUserId", "iamReplicationGroupId", "awsRegion",
+	"azureCache", "azureAuth", "azureObjectId", "azureClientId",
+}
+
+// parseActivationBackend creates the backend deciding whether the scaler is
+// active, configured by the metadata keys starting with "activation" with the
+// prefix removed, e.g. activationScalerType and activationExistsKey. This
+// keeps IsActive, which KEDA calls to scale from zero, cheap when the metric
+// itself is expensive to compute. It returns nil when no such key is set and
+// the metric backend decides activity as well.
+func parseActivationBackend(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	return parsePrefixedBackend(cfg, ref, metadata, activationMetadataPrefix)
+}
+
+// parsePrefixedBackend creates a secondary backend from the metadata keys
+// starting with prefix followed by an upper case letter, with the prefix
+// removed and the letter lower cased. It returns nil when no such key is set.
+func parsePrefixedBackend(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string, prefix string) (backends.Backend, error) {
+	prefixed := make(map[string]string)
+	for key, value := range metadata {
+		if !strings.HasPrefix(key, prefix) || len(key) == len(prefix) {
+			continue
+		}
+
+		first, size := utf8.DecodeRuneInString(key[len(prefix):])
+		if !unicode.IsUpper(first) {
+			continue
+		}
+
+		prefixed[string(unicode.ToLower(first))+key[len(prefix)+size:]] = value
+	}
+
+	if len(prefixed) == 0 {
+		return nil, nil
+	}
+
+	for _, key := range activationInheritedKeys {
+		if _, ok := prefixed[key]; !ok {
+			if val, ok := metadata[key]; ok {
+				prefixed[key] = val
+			}
+		}
+	}
+
+	scalerType := defaultScalerType
+	if val, ok := prefixed["scalerType"]; ok && val != "" {
+		scalerType = val
+	}
+
+	factory, ok := backendFactories[scalerType]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s scaler type %s", prefix, scalerType)
+	}
+
+	backend, err := factory(cfg, ref, prefixed)
+	if err != nil {
+		if s, ok := status.FromError(err); ok {
+			return nil, status.Errorf(s.Code(), "%s backend %s", prefix, s.Message())
+		}
+		return nil, fmt.Errorf("%s backend %s", prefix, err.Error())
+	}
+
+	return backend, nil
+}
+
+// checkActivity asks the activation backend whether the scaler is active.
+// Like the metric backend it is active when its value is above zero unless
+// it decides activity itself.
+func checkActivity(ctx context.Context, backend backends.Backend) (bool, error) {
+	if activityBackend, ok := backend.(backends.ActivityBackend); ok {
+		_, active, err := activityBackend.GetMetricAndActivity(ctx)
+		return active, err
+	}
+
+	value, err := backend.GetMetricValue(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return value > 0, nil
+}
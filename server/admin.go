@@ -0,0 +1,136 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+)
+
+// scalerStatus is the admin API view of a registered scaler
+type scalerStatus struct {
+	Name         string                 `json:"name"`
+	Type         string                 `json:"type"`
+	Endpoint     string                 `json:"endpoint,omitempty"`
+	Labels       map[string]string      `json:"labels,omitempty"`
+	TargetSize   int64                  `json:"targetSize"`
+	LastValue    int64                  `json:"lastValue"`
+	LastActive   bool                   `json:"lastActive"`
+	LastPoll     *time.Time             `json:"lastPoll,omitempty"`
+	LastQuery    *time.Time             `json:"lastQuery,omitempty"`
+	Capabilities map[string]bool        `json:"capabilities,omitempty"`
+	Readiness    string                 `json:"readiness"`
+	WarmUpError  string                 `json:"warmUpError,omitempty"`
+	Degraded     bool                   `json:"degraded"`
+	ErrorRate    *float64               `json:"errorRate,omitempty"`
+	Buffers      map[string]bufferUsage `json:"buffers"`
+	Shadow       *shadowStatus          `json:"shadow,omitempty"`
+}
+
+func (r *Scaler) status() scalerStatus {
+	status := scalerStatus{
+		Name:       r.name,
+		Type:       r.scalerType,
+		TargetSize: r.effectiveTargetSize(),
+		Labels:     r.labels,
+	}
+
+	if backend, ok := r.backend.(backends.EndpointBackend); ok {
+		status.Endpoint = backend.Endpoint()
+	}
+
+	if backend, ok := r.backend.(backends.CapabilitiesBackend); ok {
+		status.Capabilities = backend.Capabilities()
+	}
+
+	status.Readiness, status.WarmUpError = r.getReadiness()
+
+	status.Buffers = r.bufferUsage()
+
+	if r.shadow != nil {
+		shadow := r.shadow.status()
+		status.Shadow = &shadow
+	}
+
+	if r.errorBudget != nil {
+		degraded, rate := r.getDegraded()
+		status.Degraded = degraded
+		status.ErrorRate = &rate
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status.LastValue = r.lastValue
+	status.LastActive = r.lastActive
+	if !r.lastPoll.IsZero() {
+		lastPoll := r.lastPoll
+		status.LastPoll = &lastPoll
+	}
+	if !r.lastQuery.IsZero() {
+		lastQuery := r.lastQuery
+		status.LastQuery = &lastQuery
+	}
+
+	return status
+}
+
+// newAdminServer creates the HTTP admin API server. Admin requests are
+// background work and are rejected first when the server is under memory
+// pressure.
+func newAdminServer(scalerServer *RedisExternalScalerServer, shedder *loadShedder, bound *boundAddresses, report *startupReport) *http.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"status":    "ok",
+			"listeners": bound.list(),
+		})
+	})
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w, scalerServer.listScalers())
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		dependencies, ready := report.list()
+		if !ready {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		writeJSON(w, map[string]interface{}{
+			"ready":        ready,
+			"dependencies": dependencies,
+		})
+	})
+
+	mux.HandleFunc("/loglevel", handleLogLevel(scalerServer))
+
+	mux.HandleFunc("/debug/runtime", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, currentRuntimeSettings())
+	})
+
+	mux.HandleFunc("/scalers", handleScalerListing(scalerServer))
+
+	mux.HandleFunc("/push", handlePush(scalerServer))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !shedder.allow(priorityBackground) {
+			http.Error(w, "server is under memory pressure", http.StatusServiceUnavailable)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+
+	return &http.Server{Handler: handler}
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+)
+
+const (
+	aggregationLast = "last"
+	aggregationAvg  = "avg"
+	aggregationMax  = "max"
+	aggregationP95  = "p95"
+
+	maxAggregationWindow  = time.Hour
+	maxAggregationSamples = 1024
+)
+
+// valueAggregation smooths noisy metrics by reporting an aggregate of the
+// values polled within the last window instead of the latest value
+type valueAggregation struct {
+	function string
+	window   time.Duration
+	rounding backends.RoundingMode
+
+	samples *timedRing
+}
+
+// parseValueAggregation reads aggregation and windowSeconds. It returns nil
+// when the latest value is reported, which is the default.
+func parseValueAggregation(metadata map[string]string) (*valueAggregation, error) {
+	function := aggregationLast
+	if val, ok := metadata["aggregation"]; ok && val != "" {
+		function = val
+	}
+
+	switch function {
+	case aggregationLast:
+		return nil, nil
+	case aggregationAvg, aggregationMax, aggregationP95:
+	default:
+		return nil, fmt.Errorf("aggregation must be %s, %s, %s or %s", aggregationLast, aggregationAvg, aggregationMax, aggregationP95)
+	}
+
+	val, ok := metadata["windowSeconds"]
+	if !ok || val == "" {
+		return nil, fmt.Errorf("aggregation %s requires windowSeconds", function)
+	}
+
+	seconds, err := strconv.Atoi(val)
+	if err != nil {
+		return nil, fmt.Errorf("Window seconds parsing error %s", err.Error())
+	}
+
+	window := time.Duration(seconds) * time.Second
+	if window <= 0 || window > maxAggregationWindow {
+		return nil, fmt.Errorf("windowSeconds must be between 1 and %d", int(maxAggregationWindow.Seconds()))
+	}
+
+	rounding, err := backends.ParseRoundingMode(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return &valueAggregation{
+		function: function,
+		window:   window,
+		rounding: rounding,
+		samples:  newTimedRing(maxAggregationSamples),
+	}, nil
+}
+
+// add records a polled value and returns the aggregate of the window. It must
+// be called with the scaler's lock held.
+func (a *valueAggregation) add(now time.Time, value int64) int64 {
+	a.samples.trimBefore(now.Add(-a.window))
+	a.samples.add(now, value)
+
+	values := a.samples.values()
+
+	switch a.function {
+	case aggregationAvg:
+		total := 0.0
+		for _, value := range values {
+			total += float64(value)
+		}
+		return a.rounding.Round(total / float64(len(values)))
+
+	case aggregationMax:
+		max := values[0]
+		for _, value := range values[1:] {
+			if value > max {
+				max = value
+			}
+		}
+		return max
+
+	case aggregationP95:
+		sorted := values
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i] < sorted[j]
+		})
+		return sorted[int(float64(len(sorted)-1)*0.95)]
+	}
+
+	return value
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	annotationPrefix           = "scaler.example.com/"
+	targetOverrideAnnotation   = annotationPrefix + "target-override"
+	maxValueOverrideAnnotation = annotationPrefix + "max-value-override"
+
+	annotationRefreshInterval = 30 * time.Second
+)
+
+type kubeScaledObject struct {
+	Metadata struct {
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+}
+
+// annotationOverrides holds the overrides read from the annotations of the
+// ScaledObject, which let operators change a scaler in an emergency without
+// editing its trigger metadata. They are refreshed in the background and a
+// failed refresh keeps the last known overrides.
+type annotationOverrides struct {
+	ref *pb.ScaledObjectRef
+
+	mu         sync.Mutex
+	fetched    time.Time
+	refreshing bool
+	targetSize int64
+	maxValue   int64
+}
+
+func newAnnotationOverrides(ref *pb.ScaledObjectRef) *annotationOverrides {
+	return &annotationOverrides{ref: ref}
+}
+
+// get returns the target and maximum value overrides, zero when not set, and
+// starts a refresh when they are older than annotationRefreshInterval
+func (a *annotationOverrides) get() (int64, int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.refreshing && time.Since(a.fetched) > annotationRefreshInterval {
+		a.refreshing = true
+		go a.refresh()
+	}
+
+	return a.targetSize, a.maxValue
+}
+
+func (a *annotationOverrides) refresh() {
+	targetSize, maxValue, err := a.fetch()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.refreshing = false
+	a.fetched = time.Now()
+
+	name := getScalerUniqueName(a.ref)
+	if err != nil {
+		log.Printf("Annotations of %s not refreshed %s", name, err.Error())
+		return
+	}
+
+	if targetSize != a.targetSize || maxValue != a.maxValue {
+		log.Printf("Annotation overrides of %s changed, target %d, max value %d", name, targetSize, maxValue)
+	}
+
+	a.targetSize = targetSize
+	a.maxValue = maxValue
+}
+
+func (a *annotationOverrides) fetch() (int64, int64, error) {
+	client, err := getKubeClient()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kubeTimeout)
+	defer cancel()
+
+	var scaledObject kubeScaledObject
+	path := fmt.Sprintf("/apis/%s/namespaces/%s/scaledobjects/%s", scaledObjectAPIVersion, a.ref.Namespace, a.ref.Name)
+	if err := client.Do(ctx, http.MethodGet, path, nil, &scaledObject); err != nil {
+		return 0, 0, err
+	}
+
+	annotations := scaledObject.Metadata.Annotations
+
+	targetSize, err := parseOverrideAnnotation(annotations, targetOverrideAnnotation)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	maxValue, err := parseOverrideAnnotation(annotations, maxValueOverrideAnnotation)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return targetSize, maxValue, nil
+}
+
+// parseOverrideAnnotation reads a positive integer annotation, zero when it
+// is not set
+func parseOverrideAnnotation(annotations map[string]string, name string) (int64, error) {
+	val, ok := annotations[name]
+	if !ok || val == "" {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Annotation %s parsing error %s", name, err.Error())
+	}
+
+	if value <= 0 {
+		return 0, fmt.Errorf("annotation %s must be positive", name)
+	}
+
+	return value, nil
+}
+
+// effectiveTargetSize returns the target size, taking a target override
+// annotation into account
+func (r *Scaler) effectiveTargetSize() int64 {
+	if r.overrides != nil {
+		if targetSize, _ := r.overrides.get(); targetSize > 0 {
+			return targetSize
+		}
+	}
+	return r.targetSize
+}
+
+// effectiveMaxValue returns the cap on the metric value, taking a max value
+// override annotation into account
+func (r *Scaler) effectiveMaxValue() int64 {
+	if r.overrides != nil {
+		if _, maxValue := r.overrides.get(); maxValue > 0 {
+			return maxValue
+		}
+	}
+	return r.maxValue
+}
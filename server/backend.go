@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	argobackend "github.com/patnaikshekhar/keda_external_scaler/backends/argo"
+	awslbbackend "github.com/patnaikshekhar/keda_external_scaler/backends/awslb"
+	consulbackend "github.com/patnaikshekhar/keda_external_scaler/backends/consul"
+	backlogbackend "github.com/patnaikshekhar/keda_external_scaler/backends/eventbacklog"
+	jolokiabackend "github.com/patnaikshekhar/keda_external_scaler/backends/jolokia"
+	kubejobsbackend "github.com/patnaikshekhar/keda_external_scaler/backends/kubejobs"
+	modbusbackend "github.com/patnaikshekhar/keda_external_scaler/backends/modbus"
+	openfaasbackend "github.com/patnaikshekhar/keda_external_scaler/backends/openfaas"
+	podsbackend "github.com/patnaikshekhar/keda_external_scaler/backends/podmetrics"
+	pushbackend "github.com/patnaikshekhar/keda_external_scaler/backends/push"
+	redisbackend "github.com/patnaikshekhar/keda_external_scaler/backends/redis"
+	snmpbackend "github.com/patnaikshekhar/keda_external_scaler/backends/snmp"
+	statsdbackend "github.com/patnaikshekhar/keda_external_scaler/backends/statsd"
+	zookeeperbackend "github.com/patnaikshekhar/keda_external_scaler/backends/zookeeper"
+)
+
+const defaultScalerType = redisbackend.ScalerType
+
+// activationGate can force a scaler to be inactive regardless of its metric.
+// The reason is logged when activity is vetoed.
+type activationGate interface {
+	allowActive(ctx context.Context) (bool, string)
+}
+
+// backendFactories maps the scalerType metadata value to its backend. It is
+// only modified during startup, before any server is serving requests.
+var backendFactories = map[string]backends.BackendFactory{
+	redisbackend.ScalerType:     redisbackend.NewBackend,
+	consulbackend.ScalerType:    consulbackend.NewBackend,
+	zookeeperbackend.ScalerType: zookeeperbackend.NewBackend,
+	snmpbackend.ScalerType:      snmpbackend.NewBackend,
+	modbusbackend.ScalerType:    modbusbackend.NewBackend,
+	jolokiabackend.ScalerType:   jolokiabackend.NewBackend,
+	openfaasbackend.ScalerType:  openfaasbackend.NewBackend,
+	awslbbackend.ScalerType:     awslbbackend.NewBackend,
+	backlogbackend.ScalerType:   backlogbackend.NewBackend,
+	execScalerType:              parseExecMetadata,
+	webhookScalerType:           parseWebhookMetadata,
+	delegateScalerType:          parseDelegateMetadata,
+	StaticScalerType:            parseStaticMetadata,
+	statsdbackend.ScalerType:    statsdbackend.NewBackendFactory(statsdGauges),
+	pushbackend.ScalerType:      pushbackend.NewBackendFactory(pushedValues),
+	argobackend.ScalerType:      argobackend.NewBackendFactory(sharedKubeClient),
+	kubejobsbackend.ScalerType:  kubejobsbackend.NewBackendFactory(sharedKubeClient),
+	podsbackend.ScalerType:      podsbackend.NewBackendFactory(sharedKubeClient),
+}
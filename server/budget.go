@@ -0,0 +1,69 @@
+package server
+
+import (
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+)
+
+// endpoints returns the distinct remote endpoints the scaler's backends
+// connect to
+func (r *Scaler) endpoints() []string {
+	var endpoints []string
+	scalerBackends := []backends.Backend{r.backend, r.activation}
+	if r.shadow != nil {
+		scalerBackends = append(scalerBackends, r.shadow.backend)
+	}
+
+	for _, backend := range scalerBackends {
+		if endpointBackend, ok := backend.(backends.EndpointBackend); ok {
+			for _, endpoint := range strings.Split(endpointBackend.Endpoint(), ",") {
+				if endpoint != "" {
+					endpoints = append(endpoints, endpoint)
+				}
+			}
+		}
+	}
+	return endpoints
+}
+
+// checkEndpointBudget rejects a scaler when registering it would make its
+// namespace use more distinct endpoints than maxEndpoints, zero meaning no
+// limit. The scaler it replaces, if any, does not count. It must be called
+// with the server's lock held.
+func (s *RedisExternalScalerServer) checkEndpointBudget(scaler *Scaler, maxEndpoints int) error {
+	if maxEndpoints <= 0 {
+		return nil
+	}
+
+	namespace := scaler.ref.GetNamespace()
+	used := make(map[string]bool)
+	for name, other := range s.scalers {
+		if name == scaler.name || other.ref.GetNamespace() != namespace {
+			continue
+		}
+
+		for _, endpoint := range other.endpoints() {
+			used[endpoint] = true
+		}
+	}
+
+	var added []string
+	for _, endpoint := range scaler.endpoints() {
+		if !used[endpoint] {
+			used[endpoint] = true
+			added = append(added, endpoint)
+		}
+	}
+
+	if len(added) > 0 && len(used) > maxEndpoints {
+		return status.Errorf(codes.ResourceExhausted,
+			"namespace %s would use %d distinct backend endpoints, more than the limit of %d, %s would be added",
+			namespace, len(used), maxEndpoints, strings.Join(added, ", "))
+	}
+
+	return nil
+}
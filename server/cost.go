@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const (
+	defaultPricingRefresh = 10 * time.Minute
+	pricingTimeout        = 5 * time.Second
+	maxPricingBodyBytes   = 64 * 1024
+)
+
+// costPolicy caps the metric so the replicas it asks for stay within a
+// budget of maxCostPerHour, and records the cost pressure, the cost of the
+// replicas the backlog would need relative to the budget.
+type costPolicy struct {
+	maxCostPerHour     float64
+	replicaCostPerHour float64
+	pricing            *pricingSource
+
+	mu       sync.Mutex
+	pressure float64
+}
+
+func parseCostPolicy(cfg *config.Config, metadata map[string]string) (*costPolicy, error) {
+	val, ok := metadata["maxCostPerHour"]
+	if !ok || val == "" {
+		return nil, nil
+	}
+
+	maxCost, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Max cost parsing error %s", err.Error())
+	}
+
+	if maxCost <= 0 {
+		return nil, fmt.Errorf("max cost per hour must be positive")
+	}
+
+	policy := costPolicy{maxCostPerHour: maxCost}
+	policy.replicaCostPerHour = cfg.Cost.ReplicaCostPerHour
+
+	if val, ok := metadata["replicaCostPerHour"]; ok && val != "" {
+		replicaCost, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Replica cost parsing error %s", err.Error())
+		}
+		policy.replicaCostPerHour = replicaCost
+	} else if cfg.Cost.PricingURL != "" {
+		policy.pricing = getPricingSource(cfg.Cost.PricingURL, cfg.Cost.RefreshInterval.Duration)
+	}
+
+	if policy.replicaCostPerHour <= 0 && policy.pricing == nil {
+		return nil, fmt.Errorf("maxCostPerHour needs a replica cost from metadata or server config")
+	}
+
+	return &policy, nil
+}
+
+// apply caps value at the replicas affordable within the budget
+func (c *costPolicy) apply(ctx context.Context, value int64, targetSize int64) int64 {
+	replicaCost := c.replicaCostPerHour
+	if c.pricing != nil {
+		if price, ok := c.pricing.price(ctx); ok {
+			replicaCost = price
+		}
+	}
+
+	if replicaCost <= 0 || targetSize <= 0 {
+		return value
+	}
+
+	replicas := float64(value) / float64(targetSize)
+
+	c.mu.Lock()
+	c.pressure = replicas * replicaCost / c.maxCostPerHour
+	c.mu.Unlock()
+
+	affordable := int64(c.maxCostPerHour / replicaCost)
+	if maxValue := affordable * targetSize; value > maxValue {
+		return maxValue
+	}
+
+	return value
+}
+
+func (c *costPolicy) getPressure() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.pressure
+}
+
+// pricingSource caches the replica price fetched from a pricing endpoint.
+// fetching is closed when the running fetch completes and nil while none
+// runs, so a slow endpoint holds up no more than one request.
+type pricingSource struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu        sync.Mutex
+	value     float64
+	fetchedAt time.Time
+	fetching  chan struct{}
+}
+
+var (
+	pricingSourcesMu sync.Mutex
+	pricingSources   = make(map[string]*pricingSource)
+)
+
+// getPricingSource returns the shared source for a pricing URL so every
+// scaler uses the same cached price
+func getPricingSource(url string, refresh time.Duration) *pricingSource {
+	pricingSourcesMu.Lock()
+	defer pricingSourcesMu.Unlock()
+
+	if refresh <= 0 {
+		refresh = defaultPricingRefresh
+	}
+
+	source, ok := pricingSources[url]
+	if !ok {
+		source = &pricingSource{
+			url:    url,
+			client: &http.Client{Timeout: pricingTimeout},
+		}
+		pricingSources[url] = source
+	}
+	source.refresh = refresh
+
+	return source
+}
+
+// price returns the cached price. A stale price is refreshed in the
+// background and returned meanwhile, only the first price is waited for
+// until ctx is done. A failed refresh keeps the last known price.
+func (p *pricingSource) price(ctx context.Context) (float64, bool) {
+	p.mu.Lock()
+	known := !p.fetchedAt.IsZero()
+	if known && time.Since(p.fetchedAt) < p.refresh {
+		defer p.mu.Unlock()
+		return p.value, true
+	}
+
+	done := p.fetching
+	if done == nil {
+		done = make(chan struct{})
+		p.fetching = done
+		go p.update(done)
+	}
+	value := p.value
+	p.mu.Unlock()
+
+	if known {
+		return value, true
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return 0, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.value, !p.fetchedAt.IsZero()
+}
+
+// update fetches the price, bounded by the client timeout
+func (p *pricingSource) update(done chan struct{}) {
+	value, err := p.fetch(context.Background())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err == nil {
+		p.value = value
+		p.fetchedAt = time.Now()
+	}
+	p.fetching = nil
+	close(done)
+}
+
+func (p *pricingSource) fetch(ctx context.Context) (float64, error) {
+	req, err := http.NewRequest(http.MethodGet, p.url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("pricing endpoint returned status %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxPricingBodyBytes))
+	if err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		ReplicaCostPerHour float64 `json:"replicaCostPerHour"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return 0, fmt.Errorf("Pricing response parsing error %s", err.Error())
+	}
+
+	return result.ReplicaCostPerHour, nil
+}
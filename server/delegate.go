@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	delegateScalerType     = "grpc-delegate"
+	delegateMetadataPrefix = "delegate"
+	defaultDelegateTimeout = 5 * time.Second
+)
+
+// delegateBackend forwards requests to another external scaler. Metadata keys
+// starting with "delegate" configure the connection and are not forwarded,
+// everything else is sent to the delegate's New().
+type delegateBackend struct {
+	conn       *grpc.ClientConn
+	client     pb.ExternalScalerClient
+	ref        *pb.ScaledObjectRef
+	timeout    time.Duration
+	metricName string
+	targetSize int64
+}
+
+func parseDelegateMetadata(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	backend := delegateBackend{}
+	backend.ref = ref
+
+	address, ok := metadata["delegateAddress"]
+	if !ok || address == "" {
+		return nil, fmt.Errorf("no delegate address given")
+	}
+
+	if err := cfg.AllowEgress(address); err != nil {
+		return nil, err
+	}
+
+	backend.timeout = defaultDelegateTimeout
+	if val, ok := metadata["delegateTimeout"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Delegate timeout parsing error %s", err.Error())
+		}
+
+		if seconds <= 0 {
+			return nil, fmt.Errorf("delegate timeout must be positive")
+		}
+
+		backend.timeout = time.Duration(seconds) * time.Second
+	}
+
+	dialOption := grpc.WithInsecure()
+	if val, ok := metadata["delegateTLS"]; ok && val != "" {
+		enabled, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("Delegate TLS parsing error %s", err.Error())
+		}
+
+		if enabled {
+			creds, err := credentials.NewClientTLSFromFile(metadata["delegateCAFile"], metadata["delegateServerName"])
+			if err != nil {
+				return nil, err
+			}
+			dialOption = grpc.WithTransportCredentials(creds)
+		}
+	}
+
+	forwarded := make(map[string]string)
+	for key, value := range metadata {
+		if key != "scalerType" && !strings.HasPrefix(key, delegateMetadataPrefix) {
+			forwarded[key] = value
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), backend.timeout)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, address, dialOption)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.conn = conn
+	backend.client = pb.NewExternalScalerClient(conn)
+
+	if _, err := backend.client.New(ctx, &pb.NewRequest{
+		ScaledObjectRef: ref,
+		Metadata:        forwarded,
+	}); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("delegate %s rejected the scaler %s", address, err.Error())
+	}
+
+	spec, err := backend.client.GetMetricSpec(ctx, ref)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+
+	if len(spec.MetricSpecs) == 0 {
+		backend.Close()
+		return nil, fmt.Errorf("delegate %s returned no metric spec", address)
+	}
+
+	backend.metricName = spec.MetricSpecs[0].MetricName
+	backend.targetSize = spec.MetricSpecs[0].TargetSize
+
+	return &backend, nil
+}
+
+// Endpoint returns the address of the delegate
+func (d *delegateBackend) Endpoint() string {
+	return d.conn.Target()
+}
+
+// MetricName returns the metric name reported by the delegate
+func (d *delegateBackend) MetricName() string {
+	return d.metricName
+}
+
+// TargetSize returns the target reported by the delegate
+func (d *delegateBackend) TargetSize() int64 {
+	return d.targetSize
+}
+
+// GetMetricValue returns the metric value of the delegate
+func (d *delegateBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(injectTraceGRPC(ctx), d.timeout)
+	defer cancel()
+
+	resp, err := d.client.GetMetrics(ctx, &pb.GetMetricsRequest{
+		ScaledObjectRef: d.ref,
+		MetricName:      d.metricName,
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	for _, value := range resp.MetricValues {
+		if value.MetricName == d.metricName {
+			return value.MetricValue, nil
+		}
+	}
+
+	return -1, fmt.Errorf("delegate returned no value for %s", d.metricName)
+}
+
+// GetMetricAndActivity asks the delegate for both its metric and its activity
+func (d *delegateBackend) GetMetricAndActivity(ctx context.Context) (int64, bool, error) {
+	value, err := d.GetMetricValue(ctx)
+	if err != nil {
+		return -1, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(injectTraceGRPC(ctx), d.timeout)
+	defer cancel()
+
+	resp, err := d.client.IsActive(ctx, d.ref)
+	if err != nil {
+		return -1, false, err
+	}
+
+	return value, resp.Result, nil
+}
+
+// Close unregisters the scaler from the delegate and closes the connection
+func (d *delegateBackend) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+
+	_, err := d.client.Close(ctx, d.ref)
+	if closeErr := d.conn.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const (
+	defaultDependencyTimeout = 2 * time.Second
+	maxDependencyRedirects   = 10
+)
+
+// dependencyCheck vetoes activity while a downstream dependency is unhealthy.
+// http and https URLs must answer a GET with a 2xx status, tcp://host:port
+// URLs must accept a connection.
+type dependencyCheck struct {
+	url     *url.URL
+	timeout time.Duration
+	client  *http.Client
+}
+
+func parseDependencyCheck(cfg *config.Config, metadata map[string]string) (*dependencyCheck, error) {
+	val, ok := metadata["dependencyURL"]
+	if !ok || val == "" {
+		return nil, nil
+	}
+
+	parsed, err := url.Parse(val)
+	if err != nil {
+		return nil, fmt.Errorf("Dependency url parsing error %s", err.Error())
+	}
+
+	switch parsed.Scheme {
+	case "http", "https":
+	case "tcp":
+		if parsed.Host == "" {
+			return nil, fmt.Errorf("dependency url %s has no host", val)
+		}
+	default:
+		return nil, fmt.Errorf("dependency url must use http, https or tcp")
+	}
+
+	if err := cfg.AllowEgressURL(parsed); err != nil {
+		return nil, err
+	}
+
+	check := dependencyCheck{url: parsed}
+
+	check.timeout = defaultDependencyTimeout
+	if val, ok := metadata["dependencyTimeout"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Dependency timeout parsing error %s", err.Error())
+		}
+
+		if seconds <= 0 {
+			return nil, fmt.Errorf("dependency timeout must be positive")
+		}
+
+		check.timeout = time.Duration(seconds) * time.Second
+	}
+	check.client = &http.Client{
+		Timeout: check.timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxDependencyRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxDependencyRedirects)
+			}
+			return cfg.AllowEgressURL(req.URL)
+		},
+	}
+
+	return &check, nil
+}
+
+// allowActive vetoes activity while the dependency is unhealthy
+func (d *dependencyCheck) allowActive(ctx context.Context) (bool, string) {
+	if err := d.check(ctx); err != nil {
+		return false, fmt.Sprintf("dependency %s is unhealthy %s", d.url.Host, err.Error())
+	}
+	return true, ""
+}
+
+func (d *dependencyCheck) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	if d.url.Scheme == "tcp" {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", d.url.Host)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, d.url.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+func TestParseDependencyCheckErrors(t *testing.T) {
+	cfg := &config.Config{EgressAllowlist: []string{"127.0.0.0/8"}}
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+	}{
+		{"bad url", map[string]string{"dependencyURL": "http://[::1"}},
+		{"unknown scheme", map[string]string{"dependencyURL": "ftp://127.0.0.1/"}},
+		{"tcp without host", map[string]string{"dependencyURL": "tcp:///"}},
+		{"host not allowed", map[string]string{"dependencyURL": "http://192.168.1.1/health"}},
+		{"bad timeout", map[string]string{"dependencyURL": "http://127.0.0.1/", "dependencyTimeout": "soon"}},
+		{"zero timeout", map[string]string{"dependencyURL": "http://127.0.0.1/", "dependencyTimeout": "0"}},
+	}
+
+	for _, test := range tests {
+		if _, err := parseDependencyCheck(cfg, test.metadata); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+
+	check, err := parseDependencyCheck(cfg, map[string]string{})
+	if err != nil || check != nil {
+		t.Errorf("got %v %v, want no check without dependencyURL", check, err)
+	}
+}
+
+func TestDependencyCheckRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://192.168.1.1/health", http.StatusFound)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{EgressAllowlist: []string{"127.0.0.0/8"}}
+	check, err := parseDependencyCheck(cfg, map[string]string{"dependencyURL": server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err.Error())
+	}
+
+	// The redirect leaves the allowlist and is not followed
+	if _, err := check.client.Get(server.URL); err == nil {
+		t.Errorf("expected the redirect to be rejected")
+	}
+}
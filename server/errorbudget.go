@@ -0,0 +1,120 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultErrorBudgetWindow = 5 * time.Minute
+	maxErrorBudgetWindow     = time.Hour
+	maxErrorBudgetSamples    = 1024
+
+	// A handful of polls is not enough to judge the error rate
+	minErrorBudgetSamples = 5
+)
+
+// errorBudget tracks the share of failed polls of a scaler over a window.
+// When it exceeds the budget the scaler is degraded until the rate drops
+// back within budget.
+type errorBudget struct {
+	budget float64
+	window time.Duration
+
+	outcomes *timedRing
+	degraded bool
+	rate     float64
+}
+
+// parseErrorBudget reads errorBudget, the tolerated share of failed polls
+// between 0 and 1, and errorBudgetWindowSeconds. It returns nil when no
+// budget is set.
+func parseErrorBudget(metadata map[string]string) (*errorBudget, error) {
+	val, ok := metadata["errorBudget"]
+	if !ok || val == "" {
+		return nil, nil
+	}
+
+	budget, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Error budget parsing error %s", err.Error())
+	}
+
+	if budget < 0 || budget >= 1 {
+		return nil, fmt.Errorf("error budget must be at least 0 and below 1")
+	}
+
+	window := defaultErrorBudgetWindow
+	if val, ok := metadata["errorBudgetWindowSeconds"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Error budget window parsing error %s", err.Error())
+		}
+
+		window = time.Duration(seconds) * time.Second
+		if window <= 0 || window > maxErrorBudgetWindow {
+			return nil, fmt.Errorf("errorBudgetWindowSeconds must be between 1 and %d", int(maxErrorBudgetWindow.Seconds()))
+		}
+	}
+
+	return &errorBudget{
+		budget:   budget,
+		window:   window,
+		outcomes: newTimedRing(maxErrorBudgetSamples),
+	}, nil
+}
+
+// record adds the outcome of a poll and reports whether the degraded state
+// changed. It must be called with the scaler's lock held.
+func (e *errorBudget) record(now time.Time, failed bool) bool {
+	outcome := int64(0)
+	if failed {
+		outcome = 1
+	}
+
+	e.outcomes.trimBefore(now.Add(-e.window))
+	e.outcomes.add(now, outcome)
+
+	failures := int64(0)
+	for _, value := range e.outcomes.values() {
+		failures += value
+	}
+	e.rate = float64(failures) / float64(e.outcomes.len())
+
+	degraded := e.degraded
+	if e.outcomes.len() >= minErrorBudgetSamples {
+		degraded = e.rate > e.budget
+	}
+
+	changed := degraded != e.degraded
+	e.degraded = degraded
+	return changed
+}
+
+// reportDegraded logs a change of the degraded state and records it as an
+// event on the ScaledObject
+func (r *Scaler) reportDegraded(degraded bool, rate float64) {
+	if degraded {
+		message := fmt.Sprintf("%.0f%% of polls failed in the last %s, above the error budget of %.0f%%",
+			rate*100, r.errorBudget.window, r.errorBudget.budget*100)
+		r.logger().Printf("Scaler %s degraded, %s", r.name, message)
+		recordScaledObjectEvent(r.ref, eventTypeWarning, "ScalerDegraded", message)
+		return
+	}
+
+	message := fmt.Sprintf("%.0f%% of polls failed in the last %s, within the error budget", rate*100, r.errorBudget.window)
+	r.logger().Printf("Scaler %s recovered, %s", r.name, message)
+	recordScaledObjectEvent(r.ref, eventTypeNormal, "ScalerRecovered", message)
+}
+
+// getDegraded returns whether the scaler is degraded and its error rate
+func (r *Scaler) getDegraded() (bool, float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.errorBudget == nil {
+		return false, 0
+	}
+	return r.errorBudget.degraded, r.errorBudget.rate
+}
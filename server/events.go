@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	scaledObjectAPIVersion = "keda.k8s.io/v1alpha1"
+	scaledObjectKind       = "ScaledObject"
+	eventSource            = "keda-external-scaler"
+
+	eventTypeNormal  = "Normal"
+	eventTypeWarning = "Warning"
+)
+
+type kubeObjectReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+}
+
+type kubeObjectMeta struct {
+	GenerateName string `json:"generateName,omitempty"`
+	Namespace    string `json:"namespace,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+type kubeEvent struct {
+	APIVersion     string              `json:"apiVersion"`
+	Kind           string              `json:"kind"`
+	Metadata       kubeObjectMeta      `json:"metadata"`
+	InvolvedObject kubeObjectReference `json:"involvedObject"`
+	Type           string              `json:"type"`
+	Reason         string              `json:"reason"`
+	Message        string              `json:"message"`
+	Source         map[string]string   `json:"source"`
+	FirstTimestamp time.Time           `json:"firstTimestamp"`
+	LastTimestamp  time.Time           `json:"lastTimestamp"`
+	Count          int                 `json:"count"`
+}
+
+// recordScaledObjectEvent creates a Kubernetes Event on the ScaledObject in
+// the background. Outside a cluster, or without permission to create
+// events, the event is only logged.
+func recordScaledObjectEvent(ref *pb.ScaledObjectRef, eventType string, reason string, message string) {
+	go func() {
+		client, err := getKubeClient()
+		if err != nil {
+			log.Printf("Event %s for %s/%s not recorded %s", reason, ref.Namespace, ref.Name, err.Error())
+			return
+		}
+
+		now := time.Now()
+		event := kubeEvent{
+			APIVersion: "v1",
+			Kind:       "Event",
+			Metadata: kubeObjectMeta{
+				GenerateName: ref.Name + ".",
+				Namespace:    ref.Namespace,
+			},
+			InvolvedObject: kubeObjectReference{
+				APIVersion: scaledObjectAPIVersion,
+				Kind:       scaledObjectKind,
+				Namespace:  ref.Namespace,
+				Name:       ref.Name,
+			},
+			Type:           eventType,
+			Reason:         reason,
+			Message:        message,
+			Source:         map[string]string{"component": eventSource},
+			FirstTimestamp: now,
+			LastTimestamp:  now,
+			Count:          1,
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), kubeTimeout)
+		defer cancel()
+
+		path := fmt.Sprintf("/api/v1/namespaces/%s/events", ref.Namespace)
+		if err := client.Do(ctx, http.MethodPost, path, event, nil); err != nil {
+			log.Printf("Event %s for %s/%s not recorded %s", reason, ref.Namespace, ref.Name, err.Error())
+		}
+	}()
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	execScalerType       = "exec"
+	execMetricName       = "ExecValue"
+	defaultExecTimeout   = 5 * time.Second
+	maxExecTimeout       = 30 * time.Second
+	maxExecOutputBytes   = 4096
+	maxConcurrentExecCmd = 4
+)
+
+// execSlots bounds the number of commands running at the same time across
+// all exec backends
+var execSlots = make(chan struct{}, maxConcurrentExecCmd)
+
+// execBackend runs a command from the configured exec directory and reads the
+// metric value it prints on stdout. Fractional values are rounded according to
+// roundingMode. Commands run with an empty environment, inside the exec
+// directory, with a timeout and a bounded output size.
+type execBackend struct {
+	command  string
+	args     []string
+	dir      string
+	timeout  time.Duration
+	rounding backends.RoundingMode
+}
+
+func parseExecMetadata(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	if cfg.ExecDir == "" {
+		return nil, fmt.Errorf("exec backend is disabled, no exec directory configured")
+	}
+
+	backend := execBackend{}
+	backend.dir = cfg.ExecDir
+
+	val, ok := metadata["command"]
+	if !ok || val == "" {
+		return nil, fmt.Errorf("no command given")
+	}
+
+	if val != filepath.Base(val) {
+		return nil, fmt.Errorf("command %s must be a file name inside the exec directory", val)
+	}
+	backend.command = filepath.Join(cfg.ExecDir, val)
+
+	if val, ok := metadata["args"]; ok {
+		backend.args = strings.Fields(val)
+	}
+
+	backend.timeout = defaultExecTimeout
+	if val, ok := metadata["timeout"]; ok && val != "" {
+		timeout, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Timeout parsing error %s", err.Error())
+		}
+
+		backend.timeout = time.Duration(timeout) * time.Second
+		if backend.timeout <= 0 || backend.timeout > maxExecTimeout {
+			return nil, fmt.Errorf("timeout must be between 1 and %d seconds", int(maxExecTimeout.Seconds()))
+		}
+	}
+
+	rounding, err := backends.ParseRoundingMode(metadata)
+	if err != nil {
+		return nil, err
+	}
+	backend.rounding = rounding
+
+	return &backend, nil
+}
+
+// MetricName returns the name of the exec metric
+func (e *execBackend) MetricName() string {
+	return execMetricName
+}
+
+// GetMetricValue runs the command and parses its output as an integer
+func (e *execBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	select {
+	case execSlots <- struct{}{}:
+		defer func() { <-execSlots }()
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	}
+
+	cmd := exec.CommandContext(ctx, e.command, e.args...)
+	cmd.Dir = e.dir
+	cmd.Env = []string{}
+
+	stdout := limitedBuffer{limit: maxExecOutputBytes}
+	cmd.Stdout = &stdout
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return -1, fmt.Errorf("command %s timed out after %s", e.command, e.timeout)
+		}
+		return -1, fmt.Errorf("command %s failed %s", e.command, err.Error())
+	}
+
+	if stdout.truncated {
+		return -1, fmt.Errorf("command %s printed more than %d bytes", e.command, maxExecOutputBytes)
+	}
+
+	output := strings.TrimSpace(stdout.String())
+	if value, err := strconv.ParseInt(output, 10, 64); err == nil {
+		return value, nil
+	}
+
+	value, err := strconv.ParseFloat(output, 64)
+	if err != nil {
+		return -1, fmt.Errorf("Command output parsing error %s", err.Error())
+	}
+
+	return e.rounding.Round(value), nil
+}
+
+// Close is a no-op as commands do not outlive a call
+func (e *execBackend) Close() error {
+	return nil
+}
+
+// limitedBuffer keeps at most limit bytes and records whether more were written
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.Len(); len(p) > remaining {
+		b.truncated = true
+		if remaining > 0 {
+			b.Buffer.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Exit codes of the server, distinct per cause so orchestration and alerts
+// can tell a bad deployment from a failure at runtime
+const (
+	exitCodeRuntime    = 1
+	exitCodeConfig     = 2
+	exitCodeDependency = 3
+	exitCodeListen     = 4
+)
+
+// Kinds of startup errors
+const (
+	errorKindConfig     = "config"
+	errorKindDependency = "dependency"
+	errorKindListen     = "listen"
+	errorKindRuntime    = "runtime"
+)
+
+// startupError is an error which stops the server, with the kind of failure
+// deciding the exit code
+type startupError struct {
+	kind string
+	code int
+	err  error
+}
+
+func (e *startupError) Error() string {
+	return fmt.Sprintf("%s error %s", e.kind, e.err.Error())
+}
+
+// ConfigError marks err as an invalid config
+func ConfigError(err error) error {
+	return &startupError{kind: errorKindConfig, code: exitCodeConfig, err: err}
+}
+
+func dependencyError(err error) error {
+	return &startupError{kind: errorKindDependency, code: exitCodeDependency, err: err}
+}
+
+func listenError(err error) error {
+	return &startupError{kind: errorKindListen, code: exitCodeListen, err: err}
+}
+
+// ExitWithError logs err with its kind and exits with the matching code.
+// Errors which are not startup errors are runtime errors.
+func ExitWithError(err error) {
+	kind, code := errorKindRuntime, exitCodeRuntime
+	if startupErr, ok := err.(*startupError); ok {
+		kind, code = startupErr.kind, startupErr.code
+	}
+
+	log.WithFields(log.Fields{
+		"kind":     kind,
+		"exitCode": code,
+	}).Errorf("Scaler stopped %s", err.Error())
+
+	os.Exit(code)
+}
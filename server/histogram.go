@@ -0,0 +1,105 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// deltaHistogramBuckets are the upper bounds of the buckets of the change of
+// a scaler's value between polls, doubling from 1 to 65536
+const deltaHistogramBuckets = 17
+
+// deltaHistogram is a cumulative histogram of the absolute change of the raw
+// backend value between consecutive polls. Many large changes mean the queue
+// moves faster than it is polled.
+type deltaHistogram struct {
+	counts  [deltaHistogramBuckets + 1]uint64
+	count   uint64
+	sum     float64
+	last    int64
+	hasLast bool
+}
+
+func deltaBucketBound(i int) float64 {
+	return math.Pow(2, float64(i))
+}
+
+// observe records a polled value. It must be called with the scaler's lock
+// held.
+func (d *deltaHistogram) observe(value int64) {
+	if !d.hasLast {
+		d.last, d.hasLast = value, true
+		return
+	}
+
+	delta := float64(value - d.last)
+	if delta < 0 {
+		delta = -delta
+	}
+	d.last = value
+
+	bucket := deltaHistogramBuckets
+	for i := 0; i < deltaHistogramBuckets; i++ {
+		if delta <= deltaBucketBound(i) {
+			bucket = i
+			break
+		}
+	}
+
+	d.counts[bucket]++
+	d.count++
+	d.sum += delta
+}
+
+// writeDeltaHistograms writes the histograms of all scalers in the
+// Prometheus text format
+func writeDeltaHistograms(w io.Writer, scalers []*Scaler) {
+	name := metricsNamespace + "_value_change"
+	fmt.Fprintf(w, "# HELP %s Absolute change of the backend value between consecutive polls.\n", name)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+
+	for _, scaler := range scalers {
+		scaler.mu.Lock()
+		histogram := scaler.deltas
+		scaler.mu.Unlock()
+
+		labels := scalerLabels(scaler)
+		var cumulative uint64
+		for i := 0; i < deltaHistogramBuckets; i++ {
+			cumulative += histogram.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%g\"} %d\n", name, labels, deltaBucketBound(i), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, histogram.count)
+		fmt.Fprintf(w, "%s_sum{%s} %g\n", name, labels, histogram.sum)
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, histogram.count)
+	}
+}
+
+// deltaHistogramDataPoint returns the histogram of a scaler as OTLP data
+// point. OTLP buckets are not cumulative, the last one counts the changes
+// above the largest bound.
+func deltaHistogramDataPoint(scaler *Scaler, attributes []otlpAttribute, timestamp string) otlpHistogramDataPoint {
+	scaler.mu.Lock()
+	histogram := scaler.deltas
+	scaler.mu.Unlock()
+
+	point := otlpHistogramDataPoint{
+		Attributes:     attributes,
+		TimeUnixNano:   timestamp,
+		Count:          strconv.FormatUint(histogram.count, 10),
+		Sum:            histogram.sum,
+		BucketCounts:   make([]string, deltaHistogramBuckets+1),
+		ExplicitBounds: make([]float64, deltaHistogramBuckets),
+	}
+
+	for i := range point.BucketCounts {
+		point.BucketCounts[i] = strconv.FormatUint(histogram.counts[i], 10)
+	}
+	for i := range point.ExplicitBounds {
+		point.ExplicitBounds[i] = deltaBucketBound(i)
+	}
+
+	return point
+}
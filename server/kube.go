@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+)
+
+const (
+	kubeServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	kubeTimeout           = 10 * time.Second
+	maxKubeBodyBytes      = 1024 * 1024
+)
+
+// kubeClient is a minimal client for the Kubernetes API using the pod's
+// service account
+type kubeClient struct {
+	host   string
+	client *http.Client
+}
+
+var (
+	kubeOnce   sync.Once
+	kubeShared *kubeClient
+	kubeErr    error
+)
+
+// getKubeClient returns the shared in-cluster client, or an error when the
+// scaler does not run inside a cluster
+func getKubeClient() (*kubeClient, error) {
+	kubeOnce.Do(func() {
+		kubeShared, kubeErr = newInClusterKubeClient()
+	})
+	return kubeShared, kubeErr
+}
+
+// sharedKubeClient hands the shared in-cluster client to the backends reading
+// Kubernetes objects
+func sharedKubeClient() (backends.KubeClient, error) {
+	client, err := getKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func newInClusterKubeClient() (*kubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running inside a kubernetes cluster")
+	}
+
+	ca, err := ioutil.ReadFile(kubeServiceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates found in the service account CA")
+	}
+
+	return &kubeClient{
+		host: "https://" + net.JoinHostPort(host, port),
+		client: &http.Client{
+			Timeout: kubeTimeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+	}, nil
+}
+
+// Do sends a JSON request to the API server and decodes the response into out
+// when it is not nil
+//
+// Every request needs a rule in manifests/rbac.yaml, rbac_test.go lists them
+// with their callers.
+func (k *kubeClient) Do(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, k.host+path, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	// The token is rotated by the kubelet, read it for every request
+	token, err := ioutil.ReadFile(kubeServiceAccountDir + "/token")
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxKubeBodyBytes))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("kubernetes API %s %s returned %s", method, path, resp.Status)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("Kubernetes response parsing error %s", err.Error())
+		}
+	}
+
+	return nil
+}
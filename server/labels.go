@@ -0,0 +1,105 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+)
+
+const (
+	maxScalerLabels        = 8
+	maxScalerLabelValueLen = 64
+)
+
+// reservedLabels are set on every self-metric and cannot be overridden
+var reservedLabels = map[string]bool{
+	"scaler":   true,
+	"type":     true,
+	"endpoint": true,
+	"metric":   true,
+	"quantile": true,
+	"le":       true,
+	"buffer":   true,
+}
+
+// parseScalerLabels reads the labels metadata, a comma separated list of
+// name=value pairs such as "team=payments,env=prod". Names are sanitized to
+// valid Prometheus label names and values are truncated, so teams can slice
+// self-metrics without risking the exposition format or its cardinality.
+func parseScalerLabels(metadata map[string]string) (map[string]string, error) {
+	val, ok := metadata["labels"]
+	if !ok || val == "" {
+		return nil, nil
+	}
+
+	labels := make(map[string]string)
+	for _, pair := range backends.SplitItems(val) {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("label %s is not a name=value pair", pair)
+		}
+
+		name := sanitizeLabelName(strings.TrimSpace(parts[0]))
+		if name == "" || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("invalid label name %s", parts[0])
+		}
+
+		if reservedLabels[name] {
+			return nil, fmt.Errorf("label name %s is reserved", name)
+		}
+
+		value := strings.TrimSpace(parts[1])
+		if len(value) > maxScalerLabelValueLen {
+			value = value[:maxScalerLabelValueLen]
+		}
+
+		labels[name] = value
+	}
+
+	if len(labels) > maxScalerLabels {
+		return nil, fmt.Errorf("at most %d labels are allowed", maxScalerLabels)
+	}
+
+	return labels, nil
+}
+
+// sanitizeLabelName replaces the characters not allowed in a Prometheus label
+// name with underscores
+func sanitizeLabelName(name string) string {
+	sanitized := []byte(name)
+	for i, c := range sanitized {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')
+		if !valid {
+			sanitized[i] = '_'
+		}
+	}
+	return string(sanitized)
+}
+
+// formatCustomLabels formats labels for the Prometheus text format, sorted by
+// name and with a leading comma
+func formatCustomLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, ",%s=\"%s\"", name, escapeLabelValue(labels[name]))
+	}
+	return b.String()
+}
+
+// logger returns a log entry carrying the scaler's name and custom labels
+func (r *Scaler) logger() *log.Entry {
+	fields := log.Fields{"scaler": r.name}
+	for name, value := range r.labels {
+		fields["label_"+name] = value
+	}
+	return log.WithFields(fields)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// listenWithFallback listens on port, or on fallbackPort when port is already
+// taken, for example by an injected sidecar. A zero fallbackPort disables the
+// fallback.
+func listenWithFallback(name string, port int, fallbackPort int) (net.Listener, error) {
+	lis, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", port))
+	if err == nil || fallbackPort == 0 || !isAddressInUse(err) {
+		return lis, err
+	}
+
+	log.Printf("Port %d of %s is in use, falling back to port %d", port, name, fallbackPort)
+	return net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", fallbackPort))
+}
+
+func isAddressInUse(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+
+	sysErr, ok := opErr.Err.(*os.SyscallError)
+	if !ok {
+		return false
+	}
+
+	return sysErr.Err == syscall.EADDRINUSE
+}
+
+// boundAddresses records the address every listener is actually bound to so
+// it can be advertised on the health endpoint
+type boundAddresses struct {
+	mu        sync.RWMutex
+	addresses map[string]string
+}
+
+func (b *boundAddresses) set(name string, addr net.Addr) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.addresses == nil {
+		b.addresses = make(map[string]string)
+	}
+	b.addresses[name] = addr.String()
+}
+
+// list returns the bound addresses sorted by listener name
+func (b *boundAddresses) list() []listenerAddress {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	list := make([]listenerAddress, 0, len(b.addresses))
+	for name, address := range b.addresses {
+		list = append(list, listenerAddress{Name: name, Address: address})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
+
+// listenerAddress is the health endpoint view of a bound listener
+type listenerAddress struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
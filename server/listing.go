@@ -0,0 +1,171 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxListingLimit = 500
+
+// scalerListing filters, sorts and paginates the scaler statuses of the
+// admin API. The query parameters are namespace, backend (the scalerType),
+// state (degraded, active, inactive or a readiness state), sort (name, type,
+// lastPoll or lastValue, prefixed with - for descending order), limit and
+// offset.
+type scalerListing struct {
+	namespace  string
+	backend    string
+	state      string
+	sortBy     string
+	descending bool
+	limit      int
+	offset     int
+}
+
+var scalerListingStates = map[string]bool{
+	"degraded":       true,
+	"active":         true,
+	"inactive":       true,
+	readinessReady:   true,
+	readinessWarming: true,
+	readinessFailed:  true,
+}
+
+var scalerListingSorts = map[string]func(a, b *scalerStatus) bool{
+	"name": func(a, b *scalerStatus) bool {
+		return a.Name < b.Name
+	},
+	"type": func(a, b *scalerStatus) bool {
+		return a.Type < b.Type
+	},
+	"lastPoll": func(a, b *scalerStatus) bool {
+		if a.LastPoll == nil || b.LastPoll == nil {
+			return a.LastPoll == nil && b.LastPoll != nil
+		}
+		return a.LastPoll.Before(*b.LastPoll)
+	},
+	"lastValue": func(a, b *scalerStatus) bool {
+		return a.LastValue < b.LastValue
+	},
+}
+
+func parseScalerListing(query url.Values) (*scalerListing, error) {
+	listing := &scalerListing{
+		namespace: query.Get("namespace"),
+		backend:   query.Get("backend"),
+		state:     query.Get("state"),
+		sortBy:    "name",
+		limit:     maxListingLimit,
+	}
+
+	if listing.state != "" && !scalerListingStates[listing.state] {
+		return nil, fmt.Errorf("unknown state %s", listing.state)
+	}
+
+	if val := query.Get("sort"); val != "" {
+		listing.descending = strings.HasPrefix(val, "-")
+		listing.sortBy = strings.TrimPrefix(val, "-")
+		if _, ok := scalerListingSorts[listing.sortBy]; !ok {
+			return nil, fmt.Errorf("unknown sort %s", listing.sortBy)
+		}
+	}
+
+	if val := query.Get("limit"); val != "" {
+		limit, err := strconv.Atoi(val)
+		if err != nil || limit <= 0 || limit > maxListingLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxListingLimit)
+		}
+		listing.limit = limit
+	}
+
+	if val := query.Get("offset"); val != "" {
+		offset, err := strconv.Atoi(val)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("offset must be a non negative number")
+		}
+		listing.offset = offset
+	}
+
+	return listing, nil
+}
+
+func (l *scalerListing) matches(scaler *Scaler, status *scalerStatus) bool {
+	if l.namespace != "" && scaler.ref.Namespace != l.namespace {
+		return false
+	}
+
+	if l.backend != "" && status.Type != l.backend {
+		return false
+	}
+
+	switch l.state {
+	case "":
+		return true
+	case "degraded":
+		return status.Degraded
+	case "active":
+		return status.LastActive
+	case "inactive":
+		return !status.LastActive
+	default:
+		return status.Readiness == l.state
+	}
+}
+
+// apply returns the requested page of the matching statuses and the number
+// of matching statuses. Ties are broken by name so pages are stable.
+func (l *scalerListing) apply(scalers []*Scaler) ([]scalerStatus, int) {
+	statuses := make([]scalerStatus, 0, len(scalers))
+	for _, scaler := range scalers {
+		status := scaler.status()
+		if l.matches(scaler, &status) {
+			statuses = append(statuses, status)
+		}
+	}
+
+	less := scalerListingSorts[l.sortBy]
+	sort.SliceStable(statuses, func(i, j int) bool {
+		a, b := &statuses[i], &statuses[j]
+		if l.descending {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	total := len(statuses)
+	if l.offset >= total {
+		return []scalerStatus{}, total
+	}
+
+	end := l.offset + l.limit
+	if end > total {
+		end = total
+	}
+
+	return statuses[l.offset:end], total
+}
+
+func handleScalerListing(scalerServer *RedisExternalScalerServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listing, err := parseScalerListing(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		statuses, total := listing.apply(scalerServer.listScalers())
+
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		writeJSON(w, statuses)
+	}
+}
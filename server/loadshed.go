@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const memoryCheckInterval = 5 * time.Second
+
+// Work priorities, lowest first. Background work is shed once the soft memory
+// limit is exceeded, registrations are shed once the hard limit is exceeded.
+// Core work, which KEDA needs to keep scaling, is never shed.
+const (
+	priorityBackground = iota
+	priorityRegistration
+	priorityCore
+)
+
+const (
+	pressureNone int32 = iota
+	pressureSoft
+	pressureHard
+)
+
+var methodPriorities = map[string]int{
+	"/externalscaler.ExternalScaler/New": priorityRegistration,
+}
+
+// loadShedder tracks memory usage of the process and rejects low priority
+// work while it is above the configured limits
+type loadShedder struct {
+	softLimit uint64
+	hardLimit uint64
+	pressure  int32
+}
+
+func newLoadShedder(softLimitMB int, hardLimitMB int) *loadShedder {
+	l := &loadShedder{}
+	l.setLimits(softLimitMB, hardLimitMB)
+	return l
+}
+
+// setLimits changes the memory limits, zero disables a limit
+func (l *loadShedder) setLimits(softLimitMB int, hardLimitMB int) {
+	atomic.StoreUint64(&l.softLimit, uint64(softLimitMB)*1024*1024)
+	atomic.StoreUint64(&l.hardLimit, uint64(hardLimitMB)*1024*1024)
+}
+
+// run periodically samples memory usage until the context is done
+func (l *loadShedder) run(ctx context.Context) {
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		l.update()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *loadShedder) update() {
+	if atomic.LoadUint64(&l.softLimit) == 0 && atomic.LoadUint64(&l.hardLimit) == 0 {
+		atomic.StoreInt32(&l.pressure, pressureNone)
+		return
+	}
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	// Memory obtained from the OS minus what was returned is the closest
+	// estimate of the resident size available from the runtime
+	used := stats.Sys - stats.HeapReleased
+
+	softLimit := atomic.LoadUint64(&l.softLimit)
+	hardLimit := atomic.LoadUint64(&l.hardLimit)
+
+	pressure := pressureNone
+	if hardLimit > 0 && used > hardLimit {
+		pressure = pressureHard
+	} else if softLimit > 0 && used > softLimit {
+		pressure = pressureSoft
+	}
+
+	if previous := atomic.SwapInt32(&l.pressure, pressure); previous != pressure {
+		log.Printf("Memory pressure changed from %d to %d, %d MB in use", previous, pressure, used/1024/1024)
+	}
+}
+
+// allow reports whether work of the given priority should be served
+func (l *loadShedder) allow(priority int) bool {
+	switch atomic.LoadInt32(&l.pressure) {
+	case pressureHard:
+		return priority > priorityRegistration
+	case pressureSoft:
+		return priority > priorityBackground
+	}
+	return true
+}
+
+// unaryInterceptor rejects calls with Unavailable while their method's
+// priority is being shed
+func (l *loadShedder) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	priority, ok := methodPriorities[info.FullMethod]
+	if !ok {
+		priority = priorityCore
+	}
+
+	if !l.allow(priority) {
+		return nil, status.Errorf(codes.Unavailable, "server is under memory pressure, retry %s later", info.FullMethod)
+	}
+
+	return handler(ctx, req)
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const (
+	maxLogLevelBodySize = 64
+)
+
+// applyLogLevel sets the log level of the config. The level was validated
+// when the config was loaded.
+func applyLogLevel(cfg *config.Config) {
+	level, err := log.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return
+	}
+
+	if previous := log.GetLevel(); previous != level {
+		log.SetLevel(level)
+		log.Printf("Log level changed from %s to %s", previous, level)
+	}
+}
+
+// handleLogLevel reports the log level on GET and changes it on PUT, with
+// the level name as the plain text body. A PUT needs the token of
+// adminTokenFile as bearer token, as debug logging may expose credentials.
+// The change lasts until the next config reload or restart.
+func handleLogLevel(scalerServer *RedisExternalScalerServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprintln(w, log.GetLevel())
+
+		case http.MethodPut:
+			if err := authorizeAdmin(scalerServer.getConfig(), r); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxLogLevelBodySize))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			level, err := log.ParseLevel(strings.TrimSpace(string(body)))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			previous := log.GetLevel()
+			log.SetLevel(level)
+			log.Printf("Log level changed from %s to %s through the admin API", previous, level)
+
+			fmt.Fprintln(w, level)
+
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// authorizeAdmin checks the bearer token of a request changing the server
+// against adminTokenFile
+func authorizeAdmin(cfg *config.Config, r *http.Request) error {
+	if cfg.AdminTokenFile == "" {
+		return fmt.Errorf("changes through the admin API need adminTokenFile")
+	}
+
+	token, err := ioutil.ReadFile(cfg.AdminTokenFile)
+	if err != nil {
+		log.Printf("Admin token not read %s", err.Error())
+		return fmt.Errorf("admin token not available")
+	}
+
+	expected := strings.TrimSpace(string(token))
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if expected == "" || subtle.ConstantTimeCompare([]byte(given), []byte(expected)) != 1 {
+		return fmt.Errorf("unauthorized")
+	}
+
+	return nil
+}
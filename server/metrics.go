@@ -0,0 +1,219 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+)
+
+const (
+	latencyWindowSize = 256
+	metricsNamespace  = "external_scaler"
+)
+
+// latencyWindow keeps the most recent backend call latencies of a scaler
+// along with running totals over its whole lifetime
+type latencyWindow struct {
+	samples [latencyWindowSize]time.Duration
+	next    int
+	full    bool
+	count   uint64
+	sum     time.Duration
+}
+
+func (l *latencyWindow) len() int {
+	if l.full {
+		return latencyWindowSize
+	}
+	return l.next
+}
+
+func (l *latencyWindow) add(latency time.Duration) {
+	l.samples[l.next] = latency
+	l.next = (l.next + 1) % latencyWindowSize
+	if l.next == 0 {
+		l.full = true
+	}
+
+	l.count++
+	l.sum += latency
+}
+
+// quantiles returns the requested quantiles of the recorded window
+func (l *latencyWindow) quantiles(qs ...float64) []time.Duration {
+	size := l.len()
+
+	result := make([]time.Duration, len(qs))
+	if size == 0 {
+		return result
+	}
+
+	sorted := make([]time.Duration, size)
+	copy(sorted, l.samples[:size])
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	for i, q := range qs {
+		result[i] = sorted[int(float64(size-1)*q)]
+	}
+
+	return result
+}
+
+// writeMetrics writes the self-metrics in the Prometheus text format
+func writeMetrics(w io.Writer, scalers []*Scaler) {
+	name := metricsNamespace + "_backend_latency_seconds"
+	fmt.Fprintf(w, "# HELP %s Latency of backend calls per scaler over the last %d calls.\n", name, latencyWindowSize)
+	fmt.Fprintf(w, "# TYPE %s summary\n", name)
+
+	quantiles := []float64{0.5, 0.95}
+	for _, scaler := range scalers {
+		labels := scalerLabels(scaler)
+
+		scaler.mu.Lock()
+		values := scaler.latencies.quantiles(quantiles...)
+		count := scaler.latencies.count
+		sum := scaler.latencies.sum
+		scaler.mu.Unlock()
+
+		for i, q := range quantiles {
+			fmt.Fprintf(w, "%s{%s,quantile=\"%g\"} %g\n", name, labels, q, values[i].Seconds())
+		}
+		fmt.Fprintf(w, "%s_sum{%s} %g\n", name, labels, sum.Seconds())
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, count)
+	}
+
+	writeDeltaHistograms(w, scalers)
+
+	name = metricsNamespace + "_cost_pressure"
+	fmt.Fprintf(w, "# HELP %s Cost of the replicas the metric asks for relative to the scaler's budget.\n", name)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+
+	for _, scaler := range scalers {
+		if scaler.cost != nil {
+			fmt.Fprintf(w, "%s{%s} %g\n", name, scalerLabels(scaler), scaler.cost.getPressure())
+		}
+	}
+
+	name = metricsNamespace + "_ready"
+	fmt.Fprintf(w, "# HELP %s Whether the scaler's backend is warmed up, 1 when ready.\n", name)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+
+	for _, scaler := range scalers {
+		ready := 0
+		if readiness, _ := scaler.getReadiness(); readiness == readinessReady {
+			ready = 1
+		}
+		fmt.Fprintf(w, "%s{%s} %d\n", name, scalerLabels(scaler), ready)
+	}
+
+	name = metricsNamespace + "_degraded"
+	fmt.Fprintf(w, "# HELP %s Whether the scaler's poll error rate exceeds its error budget, 1 when degraded.\n", name)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+
+	for _, scaler := range scalers {
+		if scaler.errorBudget == nil {
+			continue
+		}
+
+		degraded := 0
+		if isDegraded, _ := scaler.getDegraded(); isDegraded {
+			degraded = 1
+		}
+		fmt.Fprintf(w, "%s{%s} %d\n", name, scalerLabels(scaler), degraded)
+	}
+
+	name = metricsNamespace + "_seconds_since_last_query"
+	fmt.Fprintf(w, "# HELP %s Seconds since KEDA last queried the scaler successfully, or since its registration when it was never queried.\n", name)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+
+	for _, scaler := range scalers {
+		fmt.Fprintf(w, "%s{%s} %g\n", name, scalerLabels(scaler), scaler.sinceLastQuery().Seconds())
+	}
+
+	name = metricsNamespace + "_shadow_divergence"
+	fmt.Fprintf(w, "# HELP %s Relative difference between the shadow backend's value and the metric value.\n", name)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+
+	for _, scaler := range scalers {
+		if scaler.shadow != nil {
+			fmt.Fprintf(w, "%s{%s} %g\n", name, scalerLabels(scaler), scaler.shadow.status().Divergence)
+		}
+	}
+
+	usages := make([]map[string]bufferUsage, len(scalers))
+	for i, scaler := range scalers {
+		usages[i] = scaler.bufferUsage()
+	}
+
+	for _, family := range []struct {
+		name  string
+		help  string
+		value func(bufferUsage) int
+	}{
+		{"_buffer_entries", "Entries held in the scaler's history buffers.", func(u bufferUsage) int { return u.Entries }},
+		{"_buffer_capacity", "Capacity of the scaler's history buffers.", func(u bufferUsage) int { return u.Capacity }},
+	} {
+		name = metricsNamespace + family.name
+		fmt.Fprintf(w, "# HELP %s %s\n", name, family.help)
+		fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+
+		for i, scaler := range scalers {
+			buffers := make([]string, 0, len(usages[i]))
+			for buffer := range usages[i] {
+				buffers = append(buffers, buffer)
+			}
+			sort.Strings(buffers)
+
+			labels := scalerLabels(scaler)
+			for _, buffer := range buffers {
+				fmt.Fprintf(w, "%s{%s,buffer=\"%s\"} %d\n", name, labels, buffer, family.value(usages[i][buffer]))
+			}
+		}
+	}
+
+	name = metricsNamespace + "_auxiliary_value"
+	fmt.Fprintf(w, "# HELP %s Auxiliary values collected by backends alongside their metric.\n", name)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+
+	for _, scaler := range scalers {
+		backend, ok := scaler.backend.(backends.AuxiliaryMetricsBackend)
+		if !ok {
+			continue
+		}
+
+		values := backend.AuxiliaryMetrics()
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		labels := scalerLabels(scaler)
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s{%s,metric=\"%s\"} %g\n", name, labels, escapeLabelValue(key), values[key])
+		}
+	}
+}
+
+func scalerLabels(scaler *Scaler) string {
+	endpoint := ""
+	if backend, ok := scaler.backend.(backends.EndpointBackend); ok {
+		endpoint = backend.Endpoint()
+	}
+
+	return fmt.Sprintf("scaler=\"%s\",type=\"%s\",endpoint=\"%s\"%s",
+		escapeLabelValue(scaler.name), escapeLabelValue(scaler.scalerType), escapeLabelValue(endpoint),
+		formatCustomLabels(scaler.labels))
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-redis/redis"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const (
+	defaultObservationStream = "keda-external-scaler:observations"
+	defaultObservationMaxLen = 10000
+	observationQueueSize     = 1024
+	droppedLogInterval       = time.Minute
+)
+
+type observation struct {
+	scaler     string
+	scalerType string
+	value      int64
+	active     bool
+	at         time.Time
+}
+
+// observationStream appends metric observations to a capped redis stream in
+// the background. Polls never wait for it, observations are dropped while
+// the queue is full.
+type observationStream struct {
+	client  *redis.Client
+	stream  string
+	maxLen  int64
+	queue   chan observation
+	dropped uint64
+}
+
+// newObservationStream returns nil when no address is configured
+func newObservationStream(cfg config.ObservationStreamConfig) *observationStream {
+	if cfg.Address == "" {
+		return nil
+	}
+
+	stream := defaultObservationStream
+	if cfg.Stream != "" {
+		stream = cfg.Stream
+	}
+
+	maxLen := int64(defaultObservationMaxLen)
+	if cfg.MaxLen > 0 {
+		maxLen = cfg.MaxLen
+	}
+
+	return &observationStream{
+		client: redis.NewClient(&redis.Options{
+			Addr:     cfg.Address,
+			Password: cfg.Password,
+		}),
+		stream: stream,
+		maxLen: maxLen,
+		queue:  make(chan observation, observationQueueSize),
+	}
+}
+
+// record queues an observation, it is a no-op on a nil stream
+func (o *observationStream) record(scaler string, scalerType string, value int64, active bool) {
+	if o == nil {
+		return
+	}
+
+	select {
+	case o.queue <- observation{scaler, scalerType, value, active, time.Now()}:
+	default:
+		atomic.AddUint64(&o.dropped, 1)
+	}
+}
+
+// run appends the queued observations until ctx is done
+func (o *observationStream) run(ctx context.Context) {
+	defer o.client.Close()
+
+	ticker := time.NewTicker(droppedLogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if dropped := atomic.SwapUint64(&o.dropped, 0); dropped > 0 {
+				log.Printf("Dropped %d observations for stream %s, the queue was full", dropped, o.stream)
+			}
+		case obs := <-o.queue:
+			err := o.client.XAdd(&redis.XAddArgs{
+				Stream:       o.stream,
+				MaxLenApprox: o.maxLen,
+				Values: map[string]interface{}{
+					"scaler":    obs.scaler,
+					"type":      obs.scalerType,
+					"value":     obs.value,
+					"active":    strconv.FormatBool(obs.active),
+					"timestamp": obs.at.UnixNano() / int64(time.Millisecond),
+				},
+			}).Err()
+			if err != nil {
+				log.Printf("Observation for %s not appended to stream %s %s", obs.scaler, o.stream, err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const (
+	defaultOTLPInterval = 30 * time.Second
+	otlpTimeout         = 10 * time.Second
+	otlpServiceName     = "keda-external-scaler"
+	otlpMetricsPath     = "/v1/metrics"
+
+	otlpTemporalityCumulative = 2
+)
+
+// The subset of the OTLP metrics data model used for gauges and
+// histograms, see
+// opentelemetry/proto/collector/metrics/v1/metrics_service.proto
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Unit        string         `json:"unit,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+// otlpHistogram holds cumulative histograms. 64 bit counts are strings in
+// the JSON encoding of OTLP.
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes     []otlpAttribute `json:"attributes"`
+	TimeUnixNano   string          `json:"timeUnixNano"`
+	Count          string          `json:"count"`
+	Sum            float64         `json:"sum"`
+	BucketCounts   []string        `json:"bucketCounts"`
+	ExplicitBounds []float64       `json:"explicitBounds"`
+}
+
+type otlpDataPoint struct {
+	Attributes   []otlpAttribute `json:"attributes"`
+	TimeUnixNano string          `json:"timeUnixNano"`
+	AsDouble     float64         `json:"asDouble"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value otlpAttrString `json:"value"`
+}
+
+type otlpAttrString struct {
+	StringValue string `json:"stringValue"`
+}
+
+// runOTLPExporter pushes the self-metrics at the configured interval until
+// the context is done. The config is read on every tick so a reload can
+// enable, disable or redirect the export.
+func runOTLPExporter(ctx context.Context, scalerServer *RedisExternalScalerServer) {
+	client := &http.Client{Timeout: otlpTimeout}
+
+	for {
+		cfg := scalerServer.getConfig().OTLP
+
+		interval := cfg.Interval.Duration
+		if interval <= 0 {
+			interval = defaultOTLPInterval
+		}
+
+		if cfg.Endpoint != "" {
+			if err := exportOTLP(ctx, client, cfg, scalerServer.listScalers()); err != nil {
+				log.Printf("OTLP export to %s failed %s", cfg.Endpoint, err.Error())
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func exportOTLP(ctx context.Context, client *http.Client, cfg config.OTLPConfig, scalers []*Scaler) error {
+	body, err := json.Marshal(buildOTLPRequest(scalers, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(cfg.Endpoint, "/") + otlpMetricsPath
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range cfg.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+// buildOTLPRequest collects the same values as the Prometheus endpoint as
+// OTLP gauges
+func buildOTLPRequest(scalers []*Scaler, now time.Time) otlpRequest {
+	timestamp := strconv.FormatInt(now.UnixNano(), 10)
+
+	latency := otlpMetric{
+		Name:        "external_scaler.backend.latency",
+		Description: fmt.Sprintf("Latency quantiles of backend calls per scaler over the last %d calls.", latencyWindowSize),
+		Unit:        "s",
+		Gauge:       &otlpGauge{},
+	}
+	pressure := otlpMetric{
+		Name:        "external_scaler.cost.pressure",
+		Description: "Cost of the replicas the metric asks for relative to the scaler's budget.",
+		Gauge:       &otlpGauge{},
+	}
+	ready := otlpMetric{
+		Name:        "external_scaler.ready",
+		Description: "Whether the scaler's backend is warmed up, 1 when ready.",
+		Gauge:       &otlpGauge{},
+	}
+	degraded := otlpMetric{
+		Name:        "external_scaler.degraded",
+		Description: "Whether the scaler's poll error rate exceeds its error budget, 1 when degraded.",
+		Gauge:       &otlpGauge{},
+	}
+	bufferEntries := otlpMetric{
+		Name:        "external_scaler.buffer.entries",
+		Description: "Entries held in the scaler's history buffers.",
+		Gauge:       &otlpGauge{},
+	}
+	bufferCapacity := otlpMetric{
+		Name:        "external_scaler.buffer.capacity",
+		Description: "Capacity of the scaler's history buffers.",
+		Gauge:       &otlpGauge{},
+	}
+	sinceLastQuery := otlpMetric{
+		Name:        "external_scaler.seconds_since_last_query",
+		Description: "Seconds since KEDA last queried the scaler successfully, or since its registration when it was never queried.",
+		Unit:        "s",
+		Gauge:       &otlpGauge{},
+	}
+	shadowDivergence := otlpMetric{
+		Name:        "external_scaler.shadow.divergence",
+		Description: "Relative difference between the shadow backend's value and the metric value.",
+		Gauge:       &otlpGauge{},
+	}
+	valueChange := otlpMetric{
+		Name:        "external_scaler.value_change",
+		Description: "Absolute change of the backend value between consecutive polls.",
+		Histogram:   &otlpHistogram{AggregationTemporality: otlpTemporalityCumulative},
+	}
+	auxiliary := otlpMetric{
+		Name:        "external_scaler.auxiliary.value",
+		Description: "Auxiliary values collected by backends alongside their metric.",
+		Gauge:       &otlpGauge{},
+	}
+
+	point := func(attributes []otlpAttribute, value float64) otlpDataPoint {
+		return otlpDataPoint{Attributes: attributes, TimeUnixNano: timestamp, AsDouble: value}
+	}
+
+	quantiles := []float64{0.5, 0.95}
+	for _, scaler := range scalers {
+		attributes := scalerAttributes(scaler)
+
+		scaler.mu.Lock()
+		values := scaler.latencies.quantiles(quantiles...)
+		scaler.mu.Unlock()
+
+		for i, q := range quantiles {
+			withQuantile := append(attributes[:len(attributes):len(attributes)], otlpAttr("quantile", strconv.FormatFloat(q, 'g', -1, 64)))
+			latency.Gauge.DataPoints = append(latency.Gauge.DataPoints, point(withQuantile, values[i].Seconds()))
+		}
+
+		valueChange.Histogram.DataPoints = append(valueChange.Histogram.DataPoints, deltaHistogramDataPoint(scaler, attributes, timestamp))
+
+		if scaler.cost != nil {
+			pressure.Gauge.DataPoints = append(pressure.Gauge.DataPoints, point(attributes, scaler.cost.getPressure()))
+		}
+
+		readyValue := 0.0
+		if readiness, _ := scaler.getReadiness(); readiness == readinessReady {
+			readyValue = 1
+		}
+		ready.Gauge.DataPoints = append(ready.Gauge.DataPoints, point(attributes, readyValue))
+
+		if scaler.errorBudget != nil {
+			degradedValue := 0.0
+			if isDegraded, _ := scaler.getDegraded(); isDegraded {
+				degradedValue = 1
+			}
+			degraded.Gauge.DataPoints = append(degraded.Gauge.DataPoints, point(attributes, degradedValue))
+		}
+
+		sinceLastQuery.Gauge.DataPoints = append(sinceLastQuery.Gauge.DataPoints, point(attributes, scaler.sinceLastQuery().Seconds()))
+
+		if scaler.shadow != nil {
+			shadowDivergence.Gauge.DataPoints = append(shadowDivergence.Gauge.DataPoints, point(attributes, scaler.shadow.status().Divergence))
+		}
+
+		for buffer, usage := range scaler.bufferUsage() {
+			withBuffer := append(attributes[:len(attributes):len(attributes)], otlpAttr("buffer", buffer))
+			bufferEntries.Gauge.DataPoints = append(bufferEntries.Gauge.DataPoints, point(withBuffer, float64(usage.Entries)))
+			bufferCapacity.Gauge.DataPoints = append(bufferCapacity.Gauge.DataPoints, point(withBuffer, float64(usage.Capacity)))
+		}
+
+		if backend, ok := scaler.backend.(backends.AuxiliaryMetricsBackend); ok {
+			for key, value := range backend.AuxiliaryMetrics() {
+				withMetric := append(attributes[:len(attributes):len(attributes)], otlpAttr("metric", key))
+				auxiliary.Gauge.DataPoints = append(auxiliary.Gauge.DataPoints, point(withMetric, value))
+			}
+		}
+	}
+
+	return otlpRequest{
+		ResourceMetrics: []otlpResourceMetrics{
+			{
+				Resource: otlpResource{
+					Attributes: []otlpAttribute{otlpAttr("service.name", otlpServiceName)},
+				},
+				ScopeMetrics: []otlpScopeMetrics{
+					{
+						Scope:   otlpScope{Name: metricsNamespace},
+						Metrics: []otlpMetric{latency, valueChange, pressure, ready, degraded, sinceLastQuery, shadowDivergence, bufferEntries, bufferCapacity, auxiliary},
+					},
+				},
+			},
+		},
+	}
+}
+
+// scalerAttributes returns the scaler's self-metric labels as OTLP attributes
+func scalerAttributes(scaler *Scaler) []otlpAttribute {
+	endpoint := ""
+	if backend, ok := scaler.backend.(backends.EndpointBackend); ok {
+		endpoint = backend.Endpoint()
+	}
+
+	attributes := []otlpAttribute{
+		otlpAttr("scaler", scaler.name),
+		otlpAttr("type", scaler.scalerType),
+		otlpAttr("endpoint", endpoint),
+	}
+
+	names := make([]string, 0, len(scaler.labels))
+	for name := range scaler.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		attributes = append(attributes, otlpAttr(name, scaler.labels[name]))
+	}
+
+	return attributes
+}
+
+func otlpAttr(key string, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpAttrString{StringValue: value}}
+}
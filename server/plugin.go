@@ -0,0 +1,104 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"plugin"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+// Backend plugins are Go plugins (built with -buildmode=plugin) placed in the
+// plugin directory. Each plugin must export
+//
+//	var ScalerType string
+//	func NewBackend(metadata map[string]string) (interface{}, error)
+//
+// where the value returned by NewBackend implements the methods of
+// backends.Backend. Plugins require the server to be built with cgo enabled,
+// the default build without cgo rejects a plugin directory when the config is
+// loaded.
+const (
+	pluginScalerTypeSymbol = "ScalerType"
+	pluginNewBackendSymbol = "NewBackend"
+)
+
+// loadPlugins registers the backends of every plugin found in dir
+func loadPlugins(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".so") {
+			continue
+		}
+
+		path := filepath.Join(dir, file.Name())
+		scalerType, err := loadPlugin(path)
+		if err != nil {
+			return fmt.Errorf("Plugin %s loading error %s", path, err.Error())
+		}
+
+		log.Printf("Loaded backend %s from plugin %s", scalerType, path)
+	}
+
+	return nil
+}
+
+func loadPlugin(path string) (string, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	sym, err := p.Lookup(pluginScalerTypeSymbol)
+	if err != nil {
+		return "", err
+	}
+
+	scalerType, ok := sym.(*string)
+	if !ok || *scalerType == "" {
+		return "", fmt.Errorf("%s must be a non empty string", pluginScalerTypeSymbol)
+	}
+
+	if _, ok := backendFactories[*scalerType]; ok {
+		return "", fmt.Errorf("scaler type %s is already registered", *scalerType)
+	}
+
+	sym, err = p.Lookup(pluginNewBackendSymbol)
+	if err != nil {
+		return "", err
+	}
+
+	newBackend, ok := sym.(func(map[string]string) (interface{}, error))
+	if !ok {
+		return "", fmt.Errorf("%s has an unexpected signature", pluginNewBackendSymbol)
+	}
+
+	backendFactories[*scalerType] = func(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+		value, err := newBackend(metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		backend, ok := value.(backends.Backend)
+		if !ok {
+			return nil, fmt.Errorf("plugin backend %T does not implement backends.Backend", value)
+		}
+
+		return backend, nil
+	}
+
+	return *scalerType, nil
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	pushbackend "github.com/patnaikshekhar/keda_external_scaler/backends/push"
+)
+
+const (
+	maxPushBodyBytes = 4 * 1024
+	// pushRetention is how long a value is kept after its last scaler was
+	// closed, so a scaler registered again keeps serving it
+	pushRetention = 5 * time.Minute
+)
+
+// pushedValue is the last value an application pushed for a scaler
+type pushedValue struct {
+	value    int64
+	received time.Time
+}
+
+// pushExpiry is the pending drop of the value of a scaler without open
+// backends. generation tells a timer which already fired apart from the one
+// replacing it.
+type pushExpiry struct {
+	timer      *time.Timer
+	generation uint64
+}
+
+// pushedValueStore holds the pushed values by scaler name for the push
+// backends, which read it through the push.Values interface. It outlives the
+// backends, so a scaler registered again keeps serving the last value, but
+// a value is dropped retention after the last backend of its scaler was
+// closed. refs counts the open backends per scaler.
+type pushedValueStore struct {
+	retention time.Duration
+
+	mu         sync.RWMutex
+	values     map[string]pushedValue
+	refs       map[string]int
+	expiries   map[string]*pushExpiry
+	generation uint64
+}
+
+func newPushedValueStore(retention time.Duration) *pushedValueStore {
+	return &pushedValueStore{
+		retention: retention,
+		values:    make(map[string]pushedValue),
+		refs:      make(map[string]int),
+		expiries:  make(map[string]*pushExpiry),
+	}
+}
+
+var pushedValues = newPushedValueStore(pushRetention)
+
+// Acquire counts a backend opened for the scaler and keeps its value
+func (p *pushedValueStore) Acquire(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cancelExpiry(name)
+	p.refs[name]++
+}
+
+// Release drops the value after retention unless a backend for the scaler
+// is opened again in the meantime
+func (p *pushedValueStore) Release(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.refs[name]--
+	if p.refs[name] > 0 {
+		return
+	}
+	delete(p.refs, name)
+
+	p.scheduleExpiry(name)
+}
+
+// scheduleExpiry replaces the pending drop of the scaler's value with one
+// after retention, p.mu is held
+func (p *pushedValueStore) scheduleExpiry(name string) {
+	p.cancelExpiry(name)
+
+	p.generation++
+	generation := p.generation
+
+	p.expiries[name] = &pushExpiry{
+		generation: generation,
+		timer: time.AfterFunc(p.retention, func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			if expiry, ok := p.expiries[name]; ok && expiry.generation == generation {
+				delete(p.expiries, name)
+				delete(p.values, name)
+			}
+		}),
+	}
+}
+
+// cancelExpiry stops the pending drop of the scaler's value, p.mu is held
+func (p *pushedValueStore) cancelExpiry(name string) {
+	if expiry, ok := p.expiries[name]; ok {
+		expiry.timer.Stop()
+		delete(p.expiries, name)
+	}
+}
+
+func (p *pushedValueStore) set(name string, value int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.values[name] = pushedValue{value: value, received: time.Now()}
+
+	// A value pushed while no backend is open restarts the retention
+	if p.refs[name] == 0 {
+		p.scheduleExpiry(name)
+	}
+}
+
+// Value returns the last value pushed for the scaler and when it was
+// received
+func (p *pushedValueStore) Value(name string) (int64, time.Time, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	pushed, ok := p.values[name]
+	return pushed.value, pushed.received, ok
+}
+
+type pushRequest struct {
+	// ScaledObject is namespace/name of the ScaledObject
+	ScaledObject string `json:"scaledObject"`
+	Value        *int64 `json:"value"`
+}
+
+// handlePush accepts a POST of {"scaledObject": "namespace/name", "value": n}
+// for a scaler of the push backend. Unknown scalers and wrong tokens are
+// both rejected as unauthorized, so pushes cannot probe for ScaledObjects.
+func handlePush(scalerServer *RedisExternalScalerServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var request pushRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxPushBodyBytes)).Decode(&request); err != nil {
+			http.Error(w, fmt.Sprintf("Push request parsing error %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		if request.ScaledObject == "" || request.Value == nil {
+			http.Error(w, "scaledObject and value are required", http.StatusBadRequest)
+			return
+		}
+
+		if *request.Value < 0 {
+			http.Error(w, "value must not be negative", http.StatusBadRequest)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		var receiver pushbackend.Receiver
+		if scaler, ok := scalerServer.getScaler(request.ScaledObject); ok {
+			receiver, _ = scaler.backend.(pushbackend.Receiver)
+		}
+
+		if receiver == nil || !receiver.Authorized(token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		pushedValues.set(request.ScaledObject, *request.Value)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+const testPushRetention = 50 * time.Millisecond
+
+// pushedValueKept waits out the retention and reports whether the value of
+// name is still there
+func pushedValueKept(p *pushedValueStore, name string) bool {
+	time.Sleep(3 * testPushRetention)
+	_, _, ok := p.Value(name)
+	return ok
+}
+
+func TestPushedValueRetention(t *testing.T) {
+	p := newPushedValueStore(testPushRetention)
+
+	p.Acquire("test/scaler")
+	p.set("test/scaler", 5)
+	p.Release("test/scaler")
+
+	if pushedValueKept(p, "test/scaler") {
+		t.Errorf("expected the value to be dropped after the retention")
+	}
+}
+
+func TestPushedValueAcquiredAgain(t *testing.T) {
+	p := newPushedValueStore(testPushRetention)
+
+	p.Acquire("test/scaler")
+	p.set("test/scaler", 5)
+	p.Release("test/scaler")
+	p.Acquire("test/scaler")
+
+	if !pushedValueKept(p, "test/scaler") {
+		t.Fatalf("expected the value to be kept while a backend is open")
+	}
+
+	p.mu.RLock()
+	pending := len(p.expiries)
+	p.mu.RUnlock()
+	if pending != 0 {
+		t.Errorf("got %d pending expiries, want the timer stopped", pending)
+	}
+
+	p.Release("test/scaler")
+	if pushedValueKept(p, "test/scaler") {
+		t.Errorf("expected the value to be dropped after the second release")
+	}
+}
+
+func TestPushedValueReleasedAgain(t *testing.T) {
+	p := newPushedValueStore(testPushRetention)
+
+	p.Acquire("test/scaler")
+	p.set("test/scaler", 5)
+	p.Release("test/scaler")
+
+	// The first timer would drop the value half way through the retention of
+	// the second release
+	time.Sleep(testPushRetention / 2)
+	p.Acquire("test/scaler")
+	p.Release("test/scaler")
+	time.Sleep(3 * testPushRetention / 4)
+
+	if _, _, ok := p.Value("test/scaler"); !ok {
+		t.Fatalf("expected the first timer not to drop the value")
+	}
+
+	if pushedValueKept(p, "test/scaler") {
+		t.Errorf("expected the value to be dropped after the second retention")
+	}
+}
+
+func TestPushedValueStaleTimer(t *testing.T) {
+	p := newPushedValueStore(testPushRetention)
+
+	p.Acquire("test/scaler")
+	p.set("test/scaler", 5)
+	p.Release("test/scaler")
+
+	// The timer fires while the store is locked and its callback only runs
+	// once the drop was rescheduled, when its generation no longer matches
+	p.mu.Lock()
+	time.Sleep(2 * testPushRetention)
+	p.retention = time.Hour
+	p.scheduleExpiry("test/scaler")
+	p.mu.Unlock()
+
+	time.Sleep(testPushRetention)
+
+	if _, _, ok := p.Value("test/scaler"); !ok {
+		t.Errorf("expected the stale timer not to drop the value")
+	}
+
+	p.mu.Lock()
+	p.cancelExpiry("test/scaler")
+	p.mu.Unlock()
+}
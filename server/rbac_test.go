@@ -0,0 +1,164 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// rbacRule is a rule of a ClusterRole in manifests/rbac.yaml
+type rbacRule struct {
+	apiGroups []string
+	resources []string
+	verbs     []string
+}
+
+// readClusterRoles reads the rules of the ClusterRoles of a manifest. It only
+// knows the flow style lists the manifest uses.
+func readClusterRoles(manifest string) (map[string][]rbacRule, error) {
+	roles := make(map[string][]rbacRule)
+
+	for _, document := range strings.Split(manifest, "\n---\n") {
+		if !strings.Contains(document, "\nkind: ClusterRole\n") {
+			continue
+		}
+
+		var name string
+		var rules []rbacRule
+		for _, line := range strings.Split(document, "\n") {
+			key := strings.TrimPrefix(strings.TrimSpace(line), "- ")
+			colon := strings.Index(key, ":")
+			if strings.HasPrefix(key, "#") || colon < 0 {
+				continue
+			}
+
+			value := strings.TrimSpace(key[colon+1:])
+			key = key[:colon]
+
+			if key == "name" && name == "" {
+				name = value
+				continue
+			}
+
+			var list []string
+			if key == "apiGroups" || key == "resources" || key == "verbs" {
+				if err := json.Unmarshal([]byte(value), &list); err != nil {
+					return nil, fmt.Errorf("rule of %s has an unreadable %s %s", name, key, err.Error())
+				}
+			}
+
+			switch key {
+			case "apiGroups":
+				rules = append(rules, rbacRule{apiGroups: list})
+			case "resources":
+				rules[len(rules)-1].resources = list
+			case "verbs":
+				rules[len(rules)-1].verbs = list
+			}
+		}
+
+		roles[name] = rules
+	}
+
+	return roles, nil
+}
+
+// kubeRequestAttributes returns the API group, resource and verb the API
+// server authorizes a request of kubeClient.Do with
+func kubeRequestAttributes(method, path string) (string, string, string) {
+	segments := strings.Split(strings.SplitN(path, "?", 2)[0], "/")[1:]
+
+	group := ""
+	if segments[0] == "apis" {
+		group = segments[1]
+		segments = segments[3:]
+	} else {
+		segments = segments[2:]
+	}
+
+	// namespaces/<namespace>/<resource>[/<name>]
+	resource := segments[2]
+	named := len(segments) > 3
+
+	switch {
+	case method == http.MethodPost:
+		return group, resource, "create"
+	case named:
+		return group, resource, "get"
+	default:
+		return group, resource, "list"
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// TestRBACMatchesCallers checks that every request the scaler sends through
+// kubeClient.Do is granted by the role the manifest documents for it, and
+// that the roles grant nothing else
+func TestRBACMatchesCallers(t *testing.T) {
+	manifest, err := ioutil.ReadFile("../manifests/rbac.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles, err := readClusterRoles(string(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := []struct {
+		caller string
+		role   string
+		method string
+		path   string
+	}{
+		{"server/events.go", "keda-redis-external-scaler", http.MethodPost, "/api/v1/namespaces/ns/events"},
+		{"server/annotations.go", "keda-redis-external-scaler", http.MethodGet, fmt.Sprintf("/apis/%s/namespaces/ns/scaledobjects/so", scaledObjectAPIVersion)},
+		{"backends/kubejobs", "keda-redis-external-scaler-workloads", http.MethodGet, "/apis/batch/v1/namespaces/ns/jobs?limit=500"},
+		{"backends/kubejobs", "keda-redis-external-scaler-workloads", http.MethodGet, "/api/v1/namespaces/ns/pods?fieldSelector=status.phase%3DPending"},
+		{"backends/podmetrics", "keda-redis-external-scaler-workloads", http.MethodGet, "/apis/metrics.k8s.io/v1beta1/namespaces/ns/pods"},
+		{"backends/argo", "keda-redis-external-scaler-workloads", http.MethodGet, "/apis/argoproj.io/v1alpha1/namespaces/ns/workflows"},
+		{"server/secrets.go", "keda-redis-external-scaler-secrets", http.MethodGet, "/api/v1/namespaces/ns/secrets/name"},
+	}
+
+	needed := make(map[string]bool)
+	for _, request := range requests {
+		group, resource, verb := kubeRequestAttributes(request.method, request.path)
+		needed[strings.Join([]string{request.role, group, resource, verb}, "|")] = true
+
+		granted := false
+		for _, rule := range roles[request.role] {
+			if contains(rule.apiGroups, group) && contains(rule.resources, resource) && contains(rule.verbs, verb) {
+				granted = true
+			}
+		}
+
+		if !granted {
+			t.Errorf("%s: %s of %s in group %q is not granted by %s", request.caller, verb, resource, group, request.role)
+		}
+	}
+
+	for name, rules := range roles {
+		for _, rule := range rules {
+			for _, group := range rule.apiGroups {
+				for _, resource := range rule.resources {
+					for _, verb := range rule.verbs {
+						if !needed[strings.Join([]string{name, group, resource, verb}, "|")] {
+							t.Errorf("%s grants %s of %s in group %q, which no caller needs", name, verb, resource, group)
+						}
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"runtime"
+	"sort"
+	"syscall"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const maskedValue = "******"
+
+// logStartupBanner logs the version information and the effective config
+func logStartupBanner(cfg *config.Config) {
+	log.Printf("Starting external scaler pid=%d go=%s os=%s arch=%s", os.Getpid(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+	values := describeConfig(cfg)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		log.Printf("Config %s=%s", key, values[key])
+	}
+}
+
+// reloadConfigOnSignal reloads the config every time the process receives
+// SIGHUP. Settings used at startup only, such as listeners, are logged but
+// only take effect after a restart.
+func reloadConfigOnSignal(scalerServer *RedisExternalScalerServer, shedder *loadShedder) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		log.Println("Reloading config")
+
+		cfg, err := config.Load()
+		if err != nil {
+			log.Printf("Config reload failed, keeping the previous config %s", err.Error())
+			continue
+		}
+
+		previous := scalerServer.getConfig()
+		logConfigDiff(previous, cfg)
+
+		shedder.setLimits(cfg.MemorySoftLimitMB, cfg.MemoryHardLimitMB)
+		applyMaxProcs(cfg)
+		applyLogLevel(cfg)
+		scalerServer.setConfig(cfg)
+
+		log.Println("Config reloaded")
+	}
+}
+
+// logConfigDiff logs every setting which differs between two configs
+func logConfigDiff(previous *config.Config, current *config.Config) {
+	before := describeConfig(previous)
+	after := describeConfig(current)
+
+	keys := make(map[string]bool)
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	changed := false
+	for _, key := range sorted {
+		oldValue, hadOld := before[key]
+		newValue, hasNew := after[key]
+
+		switch {
+		case !hadOld:
+			log.Printf("Config %s added with %s", key, newValue)
+		case !hasNew:
+			log.Printf("Config %s removed, was %s", key, oldValue)
+		case oldValue != newValue:
+			log.Printf("Config %s changed from %s to %s", key, oldValue, newValue)
+		default:
+			continue
+		}
+		changed = true
+	}
+
+	if !changed {
+		log.Println("Config unchanged")
+	}
+}
+
+// describeConfig flattens the config into json paths and printable values.
+// Fields tagged with secret:"true" are masked.
+func describeConfig(cfg *config.Config) map[string]string {
+	values := make(map[string]string)
+	describeValue(values, "", reflect.ValueOf(*cfg))
+	return values
+}
+
+var durationType = reflect.TypeOf(config.Duration{})
+
+func describeValue(values map[string]string, path string, value reflect.Value) {
+	switch {
+	case value.Type() == durationType:
+		values[path] = value.Interface().(config.Duration).String()
+	case value.Kind() == reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			name := jsonName(field)
+			if name == "" {
+				continue
+			}
+
+			if path != "" {
+				name = path + "." + name
+			}
+
+			if field.Tag.Get("secret") == "true" {
+				if !value.Field(i).IsZero() {
+					values[name] = maskedValue
+				} else {
+					values[name] = ""
+				}
+				continue
+			}
+
+			describeValue(values, name, value.Field(i))
+		}
+	case value.Kind() == reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			describeValue(values, fmt.Sprintf("%s[%d]", path, i), value.Index(i))
+		}
+	case value.Kind() == reflect.Map:
+		for _, key := range value.MapKeys() {
+			describeValue(values, fmt.Sprintf("%s[%v]", path, key.Interface()), value.MapIndex(key))
+		}
+	default:
+		values[path] = fmt.Sprintf("%v", value.Interface())
+	}
+}
+
+func jsonName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+
+	for i, c := range tag {
+		if c == ',' {
+			tag = tag[:i]
+			break
+		}
+	}
+
+	if tag == "" {
+		return field.Name
+	}
+	return tag
+}
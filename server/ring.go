@@ -0,0 +1,83 @@
+package server
+
+import "time"
+
+// timedRing is a fixed capacity ring buffer of timestamped values. Once full
+// the oldest value is overwritten, so per-scaler history stays bounded no
+// matter how long the scaler lives or how wide its windows are.
+type timedRing struct {
+	samples []timedValue
+	start   int
+	size    int
+}
+
+type timedValue struct {
+	at    time.Time
+	value int64
+}
+
+func newTimedRing(capacity int) *timedRing {
+	return &timedRing{samples: make([]timedValue, capacity)}
+}
+
+func (t *timedRing) add(at time.Time, value int64) {
+	end := (t.start + t.size) % len(t.samples)
+	t.samples[end] = timedValue{at: at, value: value}
+
+	if t.size < len(t.samples) {
+		t.size++
+	} else {
+		t.start = (t.start + 1) % len(t.samples)
+	}
+}
+
+// trimBefore drops the values recorded before cutoff
+func (t *timedRing) trimBefore(cutoff time.Time) {
+	for t.size > 0 && t.samples[t.start].at.Before(cutoff) {
+		t.start = (t.start + 1) % len(t.samples)
+		t.size--
+	}
+}
+
+// values returns the values from oldest to newest
+func (t *timedRing) values() []int64 {
+	values := make([]int64, t.size)
+	for i := range values {
+		values[i] = t.samples[(t.start+i)%len(t.samples)].value
+	}
+	return values
+}
+
+func (t *timedRing) len() int {
+	return t.size
+}
+
+func (t *timedRing) capacity() int {
+	return len(t.samples)
+}
+
+// bufferUsage is the fill level of a per-scaler history buffer
+type bufferUsage struct {
+	Entries  int `json:"entries"`
+	Capacity int `json:"capacity"`
+}
+
+// bufferUsage returns the fill level of the scaler's history buffers by name
+func (r *Scaler) bufferUsage() map[string]bufferUsage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	usage := map[string]bufferUsage{
+		"latencies": {Entries: r.latencies.len(), Capacity: latencyWindowSize},
+	}
+
+	if r.aggregation != nil {
+		usage["aggregation"] = bufferUsage{Entries: r.aggregation.samples.len(), Capacity: r.aggregation.samples.capacity()}
+	}
+
+	if r.errorBudget != nil {
+		usage["errorBudget"] = bufferUsage{Entries: r.errorBudget.outcomes.len(), Capacity: r.errorBudget.outcomes.capacity()}
+	}
+
+	return usage
+}
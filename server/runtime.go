@@ -0,0 +1,123 @@
+package server
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const (
+	maxProcsEnv = "GOMAXPROCS"
+
+	cgroupV2CPUMax      = "/sys/fs/cgroup/cpu.max"
+	cgroupV1CPUQuota    = "/sys/fs/cgroup/cpu/cpu.cfs_quota_us"
+	cgroupV1CPUPeriod   = "/sys/fs/cgroup/cpu/cpu.cfs_period_us"
+	maxProcsSourceEnv   = "env"
+	maxProcsSourceCfg   = "config"
+	maxProcsSourceQuota = "cgroup"
+	maxProcsSourceHost  = "host"
+)
+
+// maxProcsSource records where the current GOMAXPROCS came from
+var maxProcsSource atomic.Value
+
+// applyMaxProcs sizes GOMAXPROCS to the CPUs the container may actually use.
+// The GOMAXPROCS environment variable wins, then the maxProcs setting, then
+// the cgroup CPU quota. Without any of them the runtime default of one
+// thread per host CPU is kept, which under a CPU limit leads to throttling.
+func applyMaxProcs(cfg *config.Config) {
+	if os.Getenv(maxProcsEnv) != "" {
+		maxProcsSource.Store(maxProcsSourceEnv)
+		return
+	}
+
+	procs, source := cfg.MaxProcs, maxProcsSourceCfg
+	if procs <= 0 {
+		procs, source = cgroupCPUQuota(), maxProcsSourceQuota
+	}
+
+	if procs <= 0 {
+		procs, source = runtime.NumCPU(), maxProcsSourceHost
+	}
+
+	if previous := runtime.GOMAXPROCS(procs); previous != procs {
+		log.Printf("GOMAXPROCS set to %d from %s, was %d", procs, source, previous)
+	}
+	maxProcsSource.Store(source)
+}
+
+// cgroupCPUQuota returns the CPU quota of the cgroup rounded up to whole
+// CPUs, or zero when there is no quota
+func cgroupCPUQuota() int {
+	var quota, period float64
+
+	if data, err := ioutil.ReadFile(cgroupV2CPUMax); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0
+		}
+
+		quota, _ = strconv.ParseFloat(fields[0], 64)
+		period, _ = strconv.ParseFloat(fields[1], 64)
+	} else {
+		quota = readCgroupValue(cgroupV1CPUQuota)
+		period = readCgroupValue(cgroupV1CPUPeriod)
+	}
+
+	if quota <= 0 || period <= 0 {
+		return 0
+	}
+
+	return int(math.Max(1, math.Ceil(quota/period)))
+}
+
+func readCgroupValue(path string) float64 {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	if err != nil {
+		return 0
+	}
+
+	return value
+}
+
+// runtimeSettings is the admin API view of the Go runtime
+type runtimeSettings struct {
+	GoVersion      string `json:"goVersion"`
+	GOMAXPROCS     int    `json:"gomaxprocs"`
+	MaxProcsSource string `json:"maxProcsSource"`
+	NumCPU         int    `json:"numCPU"`
+	CgroupCPUQuota int    `json:"cgroupCPUQuota"`
+	NumGoroutine   int    `json:"numGoroutine"`
+	GCPercent      int    `json:"gcPercent"`
+}
+
+func currentRuntimeSettings() runtimeSettings {
+	// SetGCPercent is the only way to read the setting, restore it right away
+	gcPercent := debug.SetGCPercent(100)
+	debug.SetGCPercent(gcPercent)
+
+	source, _ := maxProcsSource.Load().(string)
+
+	return runtimeSettings{
+		GoVersion:      runtime.Version(),
+		GOMAXPROCS:     runtime.GOMAXPROCS(0),
+		MaxProcsSource: source,
+		NumCPU:         runtime.NumCPU(),
+		CgroupCPUQuota: cgroupCPUQuota(),
+		NumGoroutine:   runtime.NumGoroutine(),
+		GCPercent:      gcPercent,
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// activeWindow is a weekly time window parsed from activeOnlyDuring metadata
+// such as "Mon-Fri 08:00-20:00 Europe/Berlin". Days are a range or a comma
+// separated list, the time zone defaults to UTC. A window ending before it
+// starts runs past midnight and belongs to the day it starts on.
+type activeWindow struct {
+	days     [7]bool
+	start    int
+	end      int
+	location *time.Location
+	spec     string
+}
+
+func parseActiveWindow(spec string) (*activeWindow, error) {
+	fields := strings.Fields(spec)
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("active window %q must be \"<days> <HH:MM-HH:MM> [time zone]\"", spec)
+	}
+
+	window := activeWindow{spec: spec}
+
+	if err := window.parseDays(fields[0]); err != nil {
+		return nil, err
+	}
+
+	times := strings.Split(fields[1], "-")
+	if len(times) != 2 {
+		return nil, fmt.Errorf("active window time range %q must be HH:MM-HH:MM", fields[1])
+	}
+
+	var err error
+	if window.start, err = parseClock(times[0]); err != nil {
+		return nil, err
+	}
+	if window.end, err = parseClock(times[1]); err != nil {
+		return nil, err
+	}
+	if window.start == window.end {
+		return nil, fmt.Errorf("active window %q is empty", spec)
+	}
+
+	window.location = time.UTC
+	if len(fields) == 3 {
+		window.location, err = time.LoadLocation(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("Active window time zone error %s", err.Error())
+		}
+	}
+
+	return &window, nil
+}
+
+func (w *activeWindow) parseDays(spec string) error {
+	for _, part := range strings.Split(strings.ToLower(spec), ",") {
+		bounds := strings.Split(part, "-")
+		if len(bounds) > 2 {
+			return fmt.Errorf("active window days %q are invalid", spec)
+		}
+
+		first, ok := weekdays[bounds[0]]
+		if !ok {
+			return fmt.Errorf("unknown day %s in active window", bounds[0])
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			last, ok = weekdays[bounds[1]]
+			if !ok {
+				return fmt.Errorf("unknown day %s in active window", bounds[1])
+			}
+		}
+
+		for day := first; ; day = (day + 1) % 7 {
+			w.days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseClock returns the minutes since midnight of a HH:MM time
+func parseClock(clock string) (int, error) {
+	parts := strings.Split(clock, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("time %q must be HH:MM", clock)
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 23 {
+		return 0, fmt.Errorf("time %q has an invalid hour", clock)
+	}
+
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("time %q has invalid minutes", clock)
+	}
+
+	return hours*60 + minutes, nil
+}
+
+// contains reports whether t falls inside the window
+func (w *activeWindow) contains(t time.Time) bool {
+	t = t.In(w.location)
+	minute := t.Hour()*60 + t.Minute()
+
+	if w.start < w.end {
+		return w.days[t.Weekday()] && minute >= w.start && minute < w.end
+	}
+
+	// The window runs past midnight
+	if minute >= w.start {
+		return w.days[t.Weekday()]
+	}
+	return minute < w.end && w.days[(t.Weekday()+6)%7]
+}
+
+// allowActive vetoes activity outside the window
+func (w *activeWindow) allowActive(ctx context.Context) (bool, string) {
+	if w.contains(time.Now()) {
+		return true, ""
+	}
+	return false, fmt.Sprintf("outside of active window %s", w.spec)
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseActiveWindowErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"empty", ""},
+		{"no time range", "Mon-Fri"},
+		{"too many fields", "Mon-Fri 08:00-20:00 UTC extra"},
+		{"unknown day", "Mon-Fry 08:00-20:00"},
+		{"day range of three", "Mon-Wed-Fri 08:00-20:00"},
+		{"open time range", "Mon 08:00"},
+		{"time without minutes", "Mon 8-20:00"},
+		{"hour out of range", "Mon 08:00-24:00"},
+		{"minutes out of range", "Mon 08:60-20:00"},
+		{"empty window", "Mon 08:00-08:00"},
+		{"unknown time zone", "Mon 08:00-20:00 Nowhere/Town"},
+	}
+
+	for _, test := range tests {
+		if _, err := parseActiveWindow(test.spec); err == nil {
+			t.Errorf("%s: expected an error for %q", test.name, test.spec)
+		}
+	}
+}
+
+func TestActiveWindowContains(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// 2024-01-01 is a Monday
+	tests := []struct {
+		spec string
+		at   time.Time
+		want bool
+	}{
+		{"Mon-Fri 08:00-20:00", time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC), true},
+		{"Mon-Fri 08:00-20:00", time.Date(2024, 1, 1, 19, 59, 0, 0, time.UTC), true},
+		{"Mon-Fri 08:00-20:00", time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC), false},
+		{"Mon-Fri 08:00-20:00", time.Date(2024, 1, 1, 7, 59, 0, 0, time.UTC), false},
+		{"Mon-Fri 08:00-20:00", time.Date(2024, 1, 6, 12, 0, 0, 0, time.UTC), false},
+		{"sat,sun 00:00-23:59", time.Date(2024, 1, 7, 12, 0, 0, 0, time.UTC), true},
+		{"sat,sun 00:00-23:59", time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC), false},
+		// A range wrapping around the week
+		{"Fri-Mon 08:00-20:00", time.Date(2024, 1, 7, 12, 0, 0, 0, time.UTC), true},
+		{"Fri-Mon 08:00-20:00", time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC), false},
+		// Past midnight the window belongs to the day it starts on
+		{"Fri 22:00-06:00", time.Date(2024, 1, 5, 23, 0, 0, 0, time.UTC), true},
+		{"Fri 22:00-06:00", time.Date(2024, 1, 6, 5, 59, 0, 0, time.UTC), true},
+		{"Fri 22:00-06:00", time.Date(2024, 1, 6, 6, 0, 0, 0, time.UTC), false},
+		{"Fri 22:00-06:00", time.Date(2024, 1, 5, 5, 0, 0, 0, time.UTC), false},
+		// 07:30 UTC is 08:30 in Berlin in winter
+		{"Mon 08:00-20:00 Europe/Berlin", time.Date(2024, 1, 1, 7, 30, 0, 0, time.UTC), true},
+		{"Mon 08:00-20:00 Europe/Berlin", time.Date(2024, 1, 1, 19, 30, 0, 0, time.UTC), false},
+		{"Mon 08:00-20:00 Europe/Berlin", time.Date(2024, 1, 1, 8, 30, 0, 0, berlin), true},
+	}
+
+	for _, test := range tests {
+		window, err := parseActiveWindow(test.spec)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.spec, err.Error())
+			continue
+		}
+		if got := window.contains(test.at); got != test.want {
+			t.Errorf("%s at %s: got %t, want %t", test.spec, test.at, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	secretRefSuffix       = "SecretRef"
+	secretRefreshInterval = 30 * time.Second
+)
+
+type kubeSecret struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Data map[string]string `json:"data"`
+}
+
+// secretRef names a key of a Secret in the namespace of the ScaledObject
+type secretRef struct {
+	namespace string
+	name      string
+	key       string
+}
+
+// parseSecretRef reads name/key or namespace/name/key. Secrets of other
+// namespaces than the ScaledObject's are off limits, so a ScaledObject
+// cannot read credentials it could not mount itself. Operators grant the
+// scaler access to Secrets one namespace at a time, see manifests/rbac.yaml.
+func parseSecretRef(ref *pb.ScaledObjectRef, val string) (secretRef, error) {
+	parts := strings.Split(val, "/")
+	if len(parts) == 2 {
+		parts = append([]string{ref.Namespace}, parts...)
+	}
+
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return secretRef{}, fmt.Errorf("secret reference %s must be name/key or namespace/name/key", val)
+	}
+
+	if parts[0] != ref.Namespace {
+		return secretRef{}, fmt.Errorf("secret reference %s must be in namespace %s", val, ref.Namespace)
+	}
+
+	return secretRef{namespace: parts[0], name: parts[1], key: parts[2]}, nil
+}
+
+// resolveSecretRefs replaces every metadata key ending in SecretRef, such as
+// passwordSecretRef: redis-auth/password, with the key without the suffix
+// holding the value read from the Secret, so credentials never appear in the
+// trigger metadata. It returns the resource versions of the Secrets read.
+func resolveSecretRefs(ctx context.Context, ref *pb.ScaledObjectRef, metadata map[string]string) (map[string]string, map[secretRef]string, error) {
+	var resolved map[string]string
+	versions := make(map[secretRef]string)
+
+	for key, val := range metadata {
+		if !strings.HasSuffix(key, secretRefSuffix) || len(key) == len(secretRefSuffix) || val == "" {
+			continue
+		}
+
+		target := strings.TrimSuffix(key, secretRefSuffix)
+		if existing, ok := metadata[target]; ok && existing != "" {
+			return nil, nil, fmt.Errorf("%s and %s are mutually exclusive", target, key)
+		}
+
+		secret, err := parseSecretRef(ref, val)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		value, version, err := readSecret(ctx, secret)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s %s", key, err.Error())
+		}
+
+		if resolved == nil {
+			resolved = make(map[string]string, len(metadata))
+			for k, v := range metadata {
+				resolved[k] = v
+			}
+		}
+		resolved[target] = value
+		delete(resolved, key)
+		versions[secret] = version
+	}
+
+	if resolved == nil {
+		return metadata, nil, nil
+	}
+	return resolved, versions, nil
+}
+
+// readSecret returns the decoded value of the key and the Secret's resource
+// version
+func readSecret(ctx context.Context, secret secretRef) (string, string, error) {
+	client, err := getKubeClient()
+	if err != nil {
+		return "", "", err
+	}
+
+	var object kubeSecret
+	path := fmt.Sprintf("/api/v1/namespaces/%s/secrets/%s", secret.namespace, secret.name)
+	if err := client.Do(ctx, http.MethodGet, path, nil, &object); err != nil {
+		return "", "", err
+	}
+
+	encoded, ok := object.Data[secret.key]
+	if !ok {
+		return "", "", fmt.Errorf("secret %s/%s has no key %s", secret.namespace, secret.name, secret.key)
+	}
+
+	value, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", fmt.Errorf("Secret value parsing error %s", err.Error())
+	}
+
+	return string(value), object.Metadata.ResourceVersion, nil
+}
+
+// secretWatch checks the Secrets a scaler's credentials were read from every
+// secretRefreshInterval and renews the scaler when one of them changed, so
+// rotated credentials are picked up without KEDA registering it again. A
+// failed renewal keeps the scaler and is retried on the next check.
+type secretWatch struct {
+	name     string
+	versions map[secretRef]string
+	renew    func() error
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+func newSecretWatch(name string, versions map[secretRef]string, renew func() error) *secretWatch {
+	w := &secretWatch{
+		name:     name,
+		versions: versions,
+		renew:    renew,
+		stop:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *secretWatch) run() {
+	ticker := time.NewTicker(secretRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+
+		if !w.changed() {
+			continue
+		}
+
+		log.Printf("Secrets of %s changed, renewing the scaler", w.name)
+		if err := w.renew(); err != nil {
+			log.Printf("Renewing %s with the changed secrets failed %s", w.name, err.Error())
+		}
+	}
+}
+
+// changed reports whether a Secret has a new resource version. Secrets which
+// cannot be read are assumed unchanged.
+func (w *secretWatch) changed() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), kubeTimeout)
+	defer cancel()
+
+	for secret, version := range w.versions {
+		_, current, err := readSecret(ctx, secret)
+		if err != nil {
+			log.Printf("Secret %s/%s of %s not checked %s", secret.namespace, secret.name, w.name, err.Error())
+			continue
+		}
+
+		if current != version {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *secretWatch) close() {
+	w.closeOnce.Do(func() {
+		close(w.stop)
+	})
+}
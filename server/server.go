@@ -0,0 +1,849 @@
+// Package server implements the external scaler gRPC service and its admin
+// API
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	empty "github.com/golang/protobuf/ptypes/empty"
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const defaultTargetListLength = 5
+
+// Server is the external scaler. It serves the scaler API on the listeners of
+// its config and the admin API on the admin port.
+type Server struct {
+	cfg     *config.Config
+	scalers *RedisExternalScalerServer
+	shedder *loadShedder
+	report  *startupReport
+}
+
+// NewServer creates a server for cfg. It checks the local dependencies, such
+// as the TLS material of the listeners, and loads the backend plugins. The
+// log level and GOMAXPROCS of the config are applied, they are process wide.
+func NewServer(cfg *config.Config) (*Server, error) {
+	applyLogLevel(cfg)
+	logStartupBanner(cfg)
+	applyMaxProcs(cfg)
+
+	report := &startupReport{}
+	if err := report.checkLocal(cfg); err != nil {
+		return nil, dependencyError(err)
+	}
+
+	if err := loadPlugins(cfg.PluginDir); err != nil {
+		return nil, ConfigError(err)
+	}
+
+	scalers := &RedisExternalScalerServer{
+		config:       cfg,
+		observations: newObservationStream(cfg.ObservationStream),
+	}
+
+	return &Server{
+		cfg:     cfg,
+		scalers: scalers,
+		shedder: newLoadShedder(cfg.MemorySoftLimitMB, cfg.MemoryHardLimitMB),
+		report:  report,
+	}, nil
+}
+
+// Serve binds the listeners and serves until ctx is done, then drains the
+// in-flight calls and closes every scaler. It returns an error when a
+// listener cannot be bound or every server stopped unexpectedly.
+func (s *Server) Serve(ctx context.Context) error {
+	go s.report.checkRemote(s.cfg)
+	go s.shedder.run(ctx)
+
+	bound := &boundAddresses{}
+
+	var adminServer *http.Server
+	if s.cfg.AdminPort != 0 {
+		lis, err := listenWithFallback("admin", s.cfg.AdminPort, s.cfg.AdminFallbackPort)
+		if err != nil {
+			log.Printf("Admin server not started %s", err.Error())
+		} else {
+			bound.set("admin", lis.Addr())
+			log.Printf("Starting admin server on %s", lis.Addr())
+
+			adminServer = newAdminServer(s.scalers, s.shedder, bound, s.report)
+			go func() {
+				if err := adminServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+					log.Printf("Admin server stopped %s", err.Error())
+				}
+			}()
+		}
+	}
+
+	go reloadConfigOnSignal(s.scalers, s.shedder)
+	go runOTLPExporter(ctx, s.scalers)
+
+	if s.cfg.StatsDPort != 0 {
+		go runStatsDListener(ctx, s.cfg.StatsDPort)
+	}
+
+	if s.scalers.observations != nil {
+		go s.scalers.observations.run(ctx)
+	}
+
+	tracker := &inFlightTracker{}
+	interceptor := chainUnaryInterceptors(
+		tracker.unaryInterceptor,
+		s.shedder.unaryInterceptor,
+		traceUnaryInterceptor,
+	)
+
+	var wg sync.WaitGroup
+	var servers []*grpc.Server
+	for _, listener := range s.cfg.Listeners {
+		server, lis, err := newListenerServer(listener, s.scalers, interceptor)
+		if err != nil {
+			for _, started := range servers {
+				started.Stop()
+			}
+			if adminServer != nil {
+				adminServer.Close()
+			}
+			return err
+		}
+		servers = append(servers, server)
+		bound.set(listener.Name, lis.Addr())
+
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			log.Printf("Starting server %s on %s", name, lis.Addr())
+			if err := server.Serve(lis); err != nil {
+				log.Printf("Server %s stopped %s", name, err.Error())
+			}
+		}(listener.Name)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+
+	var stopErr error
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down")
+	case <-stopped:
+		log.Println("All servers stopped, shutting down")
+		stopErr = fmt.Errorf("all servers stopped unexpectedly")
+	}
+
+	shutdown(servers, adminServer, s.scalers, tracker, s.scalers.getConfig().ShutdownTimeout.Duration)
+
+	return stopErr
+}
+
+// newListenerServer creates a gRPC server for a listener profile backed by the
+// shared scaler server
+func newListenerServer(listener config.ListenerConfig, scalerServer *RedisExternalScalerServer, interceptor grpc.UnaryServerInterceptor) (*grpc.Server, net.Listener, error) {
+	creds, err := listener.Credentials()
+	if err != nil {
+		return nil, nil, dependencyError(fmt.Errorf("listener %s TLS material %s", listener.Name, err.Error()))
+	}
+
+	lis, err := listenWithFallback(listener.Name, listener.Port, listener.FallbackPort)
+	if err != nil {
+		return nil, nil, listenError(fmt.Errorf("listener %s %s", listener.Name, err.Error()))
+	}
+
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(interceptor)}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	server := grpc.NewServer(opts...)
+	pb.RegisterExternalScalerServer(server, scalerServer)
+
+	return server, lis, nil
+}
+
+// chainUnaryInterceptors combines interceptors into one, the first interceptor
+// being the outermost
+func chainUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		chained := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], chained
+			chained = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return chained(ctx, req)
+	}
+}
+
+// RedisExternalScalerServer implements the redis scaler as a GRPC server
+type RedisExternalScalerServer struct {
+	mu      sync.RWMutex
+	scalers map[string]*Scaler
+
+	configMu sync.RWMutex
+	config   *config.Config
+
+	observations *observationStream
+}
+
+// Scaler is a single registered ScaledObject and the backend serving its metric
+type Scaler struct {
+	name            string
+	ref             *pb.ScaledObjectRef
+	scalerType      string
+	backend         backends.Backend
+	activation      backends.Backend
+	shadow          *shadowComparison
+	targetSize      int64
+	pollingInterval time.Duration
+	maxValue        int64
+	gates           []activationGate
+	cost            *costPolicy
+	warmUpPolicy    *warmUpPolicy
+	aggregation     *valueAggregation
+	labels          map[string]string
+	errorBudget     *errorBudget
+	overrides       *annotationOverrides
+	observations    *observationStream
+	secrets         *secretWatch
+
+	mu             sync.Mutex
+	registered     time.Time
+	lastQuery      time.Time
+	lastPoll       time.Time
+	lastValue      int64
+	lastActive     bool
+	clientPolls    map[string]time.Time
+	latencies      latencyWindow
+	deltas         deltaHistogram
+	readiness      string
+	readinessError string
+}
+
+func getScalerUniqueName(scaledObjectRef *pb.ScaledObjectRef) string {
+	return scaledObjectRef.Namespace + "/" + scaledObjectRef.Name
+}
+
+func (s *RedisExternalScalerServer) getScaler(name string) (*Scaler, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	scaler, ok := s.scalers[name]
+	return scaler, ok
+}
+
+func (s *RedisExternalScalerServer) getConfig() *config.Config {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+
+	return s.config
+}
+
+func (s *RedisExternalScalerServer) setConfig(cfg *config.Config) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	s.config = cfg
+}
+
+// listScalers returns the registered scalers sorted by name
+func (s *RedisExternalScalerServer) listScalers() []*Scaler {
+	s.mu.RLock()
+	scalers := make([]*Scaler, 0, len(s.scalers))
+	for _, scaler := range s.scalers {
+		scalers = append(scalers, scaler)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(scalers, func(i, j int) bool {
+		return scalers[i].name < scalers[j].name
+	})
+
+	return scalers
+}
+
+// New creates a new instance of a scaler
+func (s *RedisExternalScalerServer) New(ctx context.Context, request *pb.NewRequest) (*empty.Empty, error) {
+	return s.newScaler(ctx, request, nil)
+}
+
+// newScaler creates a scaler and registers it. When replaces is set the new
+// scaler is only registered while replaces still is, so a renewal racing
+// Close does not bring back a removed ScaledObject.
+func (s *RedisExternalScalerServer) newScaler(ctx context.Context, request *pb.NewRequest, replaces *Scaler) (*empty.Empty, error) {
+
+	name := getScalerUniqueName(request.ScaledObjectRef)
+	log.Printf("New() method called for %s", name)
+
+	cfg := s.getConfig()
+
+	metadata, secretVersions, err := resolveSecretRefs(ctx, request.ScaledObjectRef, request.Metadata)
+	if err != nil {
+		log.Printf("New() failed for %s %s", name, err.Error())
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	scaler, err := parseScalerMetadata(cfg, request.ScaledObjectRef, metadata)
+	if err != nil {
+		// Backends return a status when redis is unreachable, anything else
+		// is a problem with the trigger metadata
+		if _, ok := status.FromError(err); !ok {
+			err = status.Error(codes.InvalidArgument, err.Error())
+		}
+		log.Printf("New() failed for %s %s", name, err.Error())
+		return nil, err
+	}
+	scaler.name = name
+	scaler.ref = request.ScaledObjectRef
+	scaler.observations = s.observations
+	scaler.registered = time.Now()
+
+	if len(secretVersions) > 0 {
+		renewed := scaler
+		scaler.secrets = newSecretWatch(name, secretVersions, func() error {
+			_, err := s.newScaler(context.Background(), request, renewed)
+			return err
+		})
+	}
+
+	if cfg.ScaledObjectAnnotations {
+		scaler.overrides = newAnnotationOverrides(request.ScaledObjectRef)
+	}
+
+	if scaler.pollingInterval > 0 {
+		scaler.logger().Printf("Recommended polling interval for %s is %s", name, scaler.pollingInterval)
+		if minPollInterval := cfg.MinPollInterval.Duration; scaler.pollingInterval < minPollInterval {
+			scaler.logger().Printf("Polling interval for %s is below the server minimum of %s, results will be reused between polls", name, minPollInterval)
+		}
+	}
+
+	s.mu.Lock()
+	if replaces != nil && s.scalers[name] != replaces {
+		s.mu.Unlock()
+		scaler.close()
+		return nil, status.Errorf(codes.NotFound, "scaler %s was removed or replaced", name)
+	}
+
+	if err := s.checkEndpointBudget(scaler, cfg.MaxEndpointsPerNamespace); err != nil {
+		s.mu.Unlock()
+		scaler.close()
+		return nil, err
+	}
+
+	if s.scalers == nil {
+		s.scalers = make(map[string]*Scaler)
+	}
+	previous := s.scalers[name]
+	s.scalers[name] = scaler
+	s.mu.Unlock()
+
+	if previous != nil {
+		previous.close()
+	}
+
+	if scaler.warmUpPolicy != nil {
+		scaler.setReadiness(readinessWarming, nil)
+		go scaler.warmUp()
+	}
+
+	scaler.logger().Printf("New() method completed for %s", name)
+
+	return &empty.Empty{}, nil
+}
+
+// Close removes a scaler and releases its backend
+func (s *RedisExternalScalerServer) Close(ctx context.Context, request *pb.ScaledObjectRef) (*empty.Empty, error) {
+
+	name := getScalerUniqueName(request)
+	log.Printf("Close() method called for %s", name)
+
+	// The secret watch is stopped before the scaler is removed, so it does
+	// not renew it afterwards
+	s.mu.Lock()
+	scaler, ok := s.scalers[name]
+	if ok {
+		if scaler.secrets != nil {
+			scaler.secrets.close()
+		}
+		delete(s.scalers, name)
+	}
+	s.mu.Unlock()
+
+	if ok {
+		scaler.close()
+	}
+
+	log.Printf("Close() method completed for %s", name)
+
+	return &empty.Empty{}, nil
+}
+
+// closeAll unregisters every scaler and closes their backends. It returns the
+// number of scalers and how many of them failed to close a backend.
+func (s *RedisExternalScalerServer) closeAll() (int, int) {
+	s.mu.Lock()
+	scalers := s.scalers
+	s.scalers = nil
+	s.mu.Unlock()
+
+	closeErrors := 0
+	for _, scaler := range scalers {
+		if !scaler.close() {
+			closeErrors++
+		}
+	}
+
+	return len(scalers), closeErrors
+}
+
+// close closes the scaler's backends and reports whether that succeeded
+func (r *Scaler) close() bool {
+	if r.secrets != nil {
+		r.secrets.close()
+	}
+
+	closed := closeBackend(r.name, r.backend)
+	if r.activation != nil && !closeBackend(r.name, r.activation) {
+		closed = false
+	}
+	if r.shadow != nil && !closeBackend(r.name, r.shadow.backend) {
+		closed = false
+	}
+	return closed
+}
+
+func closeBackend(name string, backend backends.Backend) bool {
+	if err := backend.Close(); err != nil {
+		log.Printf("Error closing backend for %s %s", name, err.Error())
+		return false
+	}
+	return true
+}
+
+// parseScalerMetadata builds a scaler from the trigger metadata. scalerType
+// selects the backend and defaults to redis. The target is read from
+// targetValue, or listLength for compatibility with existing triggers. When
+// neither is set a backend may provide its own target.
+//
+// An optional pollingInterval (in seconds) mirrors the pollingInterval of the
+// ScaledObject. The external scaler protocol has no field to send it back to
+// KEDA, so it is only logged as the recommended interval for operators to
+// configure.
+func parseScalerMetadata(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (*Scaler, error) {
+	scaler := Scaler{}
+	scaler.targetSize = defaultTargetListLength
+
+	target, ok := metadata["targetValue"]
+	if !ok {
+		target, ok = metadata["listLength"]
+	}
+	hasTarget := ok
+	if hasTarget {
+		targetSize, err := strconv.ParseInt(target, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Target value parsing error %s", err.Error())
+		}
+
+		scaler.targetSize = targetSize
+	}
+
+	if val, ok := metadata["pollingInterval"]; ok && val != "" {
+		pollingInterval, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Polling interval parsing error %s", err.Error())
+		}
+
+		if pollingInterval < 0 {
+			return nil, fmt.Errorf("polling interval must not be negative")
+		}
+
+		scaler.pollingInterval = time.Duration(pollingInterval) * time.Second
+	}
+
+	scalerType := defaultScalerType
+	if val, ok := metadata["scalerType"]; ok && val != "" {
+		scalerType = val
+	}
+
+	factory, ok := backendFactories[scalerType]
+	if !ok {
+		return nil, fmt.Errorf("unknown scaler type %s", scalerType)
+	}
+
+	backend, err := factory(cfg, ref, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	scaler.scalerType = scalerType
+	scaler.backend = backend
+
+	if targetBackend, ok := backend.(backends.TargetSizeBackend); ok && !hasTarget {
+		scaler.targetSize = targetBackend.TargetSize()
+	}
+
+	if err := parseMetricCap(&scaler, metadata); err != nil {
+		backend.Close()
+		return nil, err
+	}
+
+	if val, ok := metadata["activeOnlyDuring"]; ok && val != "" {
+		window, err := parseActiveWindow(val)
+		if err != nil {
+			backend.Close()
+			return nil, err
+		}
+		scaler.gates = append(scaler.gates, window)
+	}
+
+	scaler.cost, err = parseCostPolicy(cfg, metadata)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+
+	dependency, err := parseDependencyCheck(cfg, metadata)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+	if dependency != nil {
+		scaler.gates = append(scaler.gates, dependency)
+	}
+
+	scaler.warmUpPolicy, err = parseWarmUpPolicy(metadata)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+
+	scaler.aggregation, err = parseValueAggregation(metadata)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+
+	scaler.labels, err = parseScalerLabels(metadata)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+
+	scaler.errorBudget, err = parseErrorBudget(metadata)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+
+	scaler.activation, err = parseActivationBackend(cfg, ref, metadata)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+
+	scaler.shadow, err = parseShadowComparison(cfg, ref, metadata)
+	if err != nil {
+		scaler.close()
+		return nil, err
+	}
+
+	return &scaler, nil
+}
+
+// parseMetricCap reads the optional caps on the reported metric value.
+// maxUsefulBacklog caps the value directly. podCapacityHint is the number of
+// replicas which can usefully work at the same time, for example because of
+// a rate limited downstream API, and caps the value at that many replicas
+// worth of the target. The lower cap wins.
+func parseMetricCap(scaler *Scaler, metadata map[string]string) error {
+	if val, ok := metadata["maxUsefulBacklog"]; ok && val != "" {
+		maxUsefulBacklog, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Max useful backlog parsing error %s", err.Error())
+		}
+
+		if maxUsefulBacklog <= 0 {
+			return fmt.Errorf("max useful backlog must be positive")
+		}
+
+		scaler.maxValue = maxUsefulBacklog
+	}
+
+	if val, ok := metadata["podCapacityHint"]; ok && val != "" {
+		podCapacity, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Pod capacity hint parsing error %s", err.Error())
+		}
+
+		if podCapacity <= 0 {
+			return fmt.Errorf("pod capacity hint must be positive")
+		}
+
+		if capacityValue := podCapacity * scaler.targetSize; scaler.maxValue == 0 || capacityValue < scaler.maxValue {
+			scaler.maxValue = capacityValue
+		}
+	}
+
+	return nil
+}
+
+// IsActive checks if the backend reports the scaler as active, which for most
+// backends means a metric value above zero
+func (s *RedisExternalScalerServer) IsActive(ctx context.Context, request *pb.ScaledObjectRef) (*pb.IsActiveResponse, error) {
+
+	name := getScalerUniqueName(request)
+	log.Printf("IsActive() method called for %s", name)
+
+	if scalerRef, ok := s.getScaler(name); ok {
+		cfg := s.getConfig()
+
+		backendCtx, cancel := withBackendTimeout(ctx, cfg, scalerRef.scalerType)
+		active, err := scalerRef.isActive(backendCtx, cfg.MinPollInterval.Duration)
+		cancel()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, gate := range scalerRef.gates {
+			if !active {
+				break
+			}
+
+			if ok, reason := gate.allowActive(ctx); !ok {
+				scalerRef.logger().Printf("IsActive() forced inactive for %s %s", name, reason)
+				active = false
+			}
+		}
+
+		scalerRef.markQueried()
+		scalerRef.logger().Printf("IsActive() method Completed for %s", name)
+
+		return &pb.IsActiveResponse{
+			Result: active,
+		}, nil
+
+	}
+
+	return nil, fmt.Errorf("Cannot find scaler %s", name)
+}
+
+// GetMetricSpec returns the metric name and target average value for the HPA spec
+func (s *RedisExternalScalerServer) GetMetricSpec(ctx context.Context, request *pb.ScaledObjectRef) (*pb.GetMetricSpecResponse, error) {
+
+	name := getScalerUniqueName(request)
+	log.Printf("GetMetricSpec() method called for %s", name)
+
+	if scalerRef, ok := s.getScaler(name); ok {
+		spec := pb.MetricSpec{
+			MetricName: scalerRef.backend.MetricName(),
+			TargetSize: scalerRef.effectiveTargetSize(),
+		}
+
+		scalerRef.logger().Printf("GetMetricSpec() method completed for %s", name)
+
+		return &pb.GetMetricSpecResponse{
+			MetricSpecs: []*pb.MetricSpec{&spec},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Cannot find scaler %s", name)
+}
+
+// GetMetrics returns the current state of metrics
+func (s *RedisExternalScalerServer) GetMetrics(ctx context.Context, request *pb.GetMetricsRequest) (*pb.GetMetricsResponse, error) {
+
+	name := getScalerUniqueName(request.ScaledObjectRef)
+	log.Printf("GetMetrics() method called for %s", name)
+
+	if scalerRef, ok := s.getScaler(name); ok {
+		cfg := s.getConfig()
+
+		backendCtx, cancel := withBackendTimeout(ctx, cfg, scalerRef.scalerType)
+		metricValue, _, err := scalerRef.poll(backendCtx, cfg.MinPollInterval.Duration)
+		cancel()
+
+		if err != nil {
+			return nil, err
+		}
+
+		value := pb.MetricValue{
+			MetricName:  scalerRef.backend.MetricName(),
+			MetricValue: metricValue,
+		}
+
+		scalerRef.markQueried()
+		scalerRef.logger().Printf("GetMetrics() method completed for %s", name)
+
+		return &pb.GetMetricsResponse{
+			MetricValues: []*pb.MetricValue{&value},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Cannot find scaler %s", name)
+}
+
+// withBackendTimeout bounds ctx by the backend timeout configured for
+// scalerType, if any
+func withBackendTimeout(ctx context.Context, cfg *config.Config, scalerType string) (context.Context, context.CancelFunc) {
+	if timeout, ok := cfg.BackendTimeouts[scalerType]; ok {
+		return context.WithTimeout(ctx, timeout.Duration)
+	}
+	return context.WithCancel(ctx)
+}
+
+// isActive reports whether the scaler is active, from the activation backend
+// when one is configured and from the metric backend otherwise
+func (r *Scaler) isActive(ctx context.Context, minInterval time.Duration) (bool, error) {
+	if r.activation != nil {
+		return checkActivity(ctx, r.activation)
+	}
+
+	_, active, err := r.poll(ctx, minInterval)
+	return active, err
+}
+
+// poll returns the backend's metric value and whether the scaler is active.
+// When the same client polls the scaler again within minInterval the
+// previously observed result is returned instead of querying the backend.
+//
+// The backend is queried without holding r.mu, so a slow backend does not
+// stall the self-metrics and the admin API reading the scaler meanwhile.
+func (r *Scaler) poll(ctx context.Context, minInterval time.Duration) (int64, bool, error) {
+	client := pollClient(ctx)
+
+	r.mu.Lock()
+	if minInterval > 0 && !r.lastPoll.IsZero() {
+		if last, ok := r.clientPolls[client]; ok && time.Since(last) < minInterval {
+			value, active := r.lastValue, r.lastActive
+			r.mu.Unlock()
+			return value, active, nil
+		}
+	}
+	r.mu.Unlock()
+
+	var value int64
+	var active bool
+	var err error
+
+	start := time.Now()
+	if backend, ok := r.backend.(backends.ActivityBackend); ok {
+		value, active, err = backend.GetMetricAndActivity(ctx)
+	} else {
+		value, err = r.backend.GetMetricValue(ctx)
+		active = value > 0
+	}
+	latency := time.Since(start)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.latencies.add(latency)
+
+	if r.errorBudget != nil && r.errorBudget.record(time.Now(), err != nil) {
+		r.reportDegraded(r.errorBudget.degraded, r.errorBudget.rate)
+	}
+
+	if err != nil {
+		return -1, false, err
+	}
+
+	r.deltas.observe(value)
+
+	if r.shadow != nil {
+		r.shadow.compare(r.name, value)
+	}
+
+	if r.aggregation != nil {
+		value = r.aggregation.add(time.Now(), value)
+	}
+
+	if maxValue := r.effectiveMaxValue(); maxValue > 0 && value > maxValue {
+		value = maxValue
+	}
+
+	if r.cost != nil {
+		value = r.cost.apply(ctx, value, r.effectiveTargetSize())
+	}
+
+	r.lastPoll = time.Now()
+	r.lastValue = value
+	r.lastActive = active
+
+	if minInterval > 0 {
+		r.recordClientPoll(client, r.lastPoll, minInterval)
+	}
+
+	r.observations.record(r.name, r.scalerType, value, active)
+
+	return value, active, nil
+}
+
+// recordClientPoll remembers when client polled, dropping the clients whose
+// minimum interval has passed. r.mu must be held.
+func (r *Scaler) recordClientPoll(client string, now time.Time, minInterval time.Duration) {
+	if r.clientPolls == nil {
+		r.clientPolls = make(map[string]time.Time)
+	}
+
+	for other, last := range r.clientPolls {
+		if now.Sub(last) >= minInterval {
+			delete(r.clientPolls, other)
+		}
+	}
+	r.clientPolls[client] = now
+}
+
+// pollClient identifies the client of a poll by its IP address, the port
+// changes with every connection. Polls of the server itself, such as the
+// warm-up, have no client and share the empty key.
+func pollClient(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+// markQueried records a successful IsActive or GetMetrics call, so a KEDA
+// which stopped polling the scaler shows in the self-metrics
+func (r *Scaler) markQueried() {
+	r.mu.Lock()
+	r.lastQuery = time.Now()
+	r.mu.Unlock()
+}
+
+// sinceLastQuery returns the time since the last successful query, or since
+// the registration when KEDA never queried the scaler
+func (r *Scaler) sinceLastQuery() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.lastQuery.IsZero() {
+		return time.Since(r.registered)
+	}
+	return time.Since(r.lastQuery)
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const benchScalers = 16
+
+// newBenchScalerServer registers static scalers, whose backend costs next to
+// nothing, so the benchmarks measure the server's own poll path
+func newBenchScalerServer(b *testing.B) (*RedisExternalScalerServer, []*pb.ScaledObjectRef) {
+	b.Helper()
+
+	// Every call logs, which would dominate the measurements
+	log.SetOutput(ioutil.Discard)
+
+	cfg := &config.Config{StaticBackend: true}
+	s := &RedisExternalScalerServer{
+		config:       cfg,
+		observations: newObservationStream(cfg.ObservationStream),
+	}
+
+	refs := make([]*pb.ScaledObjectRef, benchScalers)
+	for i := range refs {
+		refs[i] = &pb.ScaledObjectRef{Name: fmt.Sprintf("bench-%d", i), Namespace: "bench"}
+
+		_, err := s.New(context.Background(), &pb.NewRequest{
+			ScaledObjectRef: refs[i],
+			Metadata: map[string]string{
+				"scalerType": StaticScalerType,
+				"value":      strconv.Itoa(i),
+			},
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	return s, refs
+}
+
+func BenchmarkGetMetrics(b *testing.B) {
+	s, refs := newBenchScalerServer(b)
+	ctx := context.Background()
+
+	for i := 0; i < b.N; i++ {
+		request := &pb.GetMetricsRequest{ScaledObjectRef: refs[i%len(refs)], MetricName: StaticMetricName}
+		if _, err := s.GetMetrics(ctx, request); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetMetricsParallel(b *testing.B) {
+	s, refs := newBenchScalerServer(b)
+	ctx := context.Background()
+
+	b.RunParallel(func(p *testing.PB) {
+		i := 0
+		for p.Next() {
+			request := &pb.GetMetricsRequest{ScaledObjectRef: refs[i%len(refs)], MetricName: StaticMetricName}
+			if _, err := s.GetMetrics(ctx, request); err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkIsActive(b *testing.B) {
+	s, refs := newBenchScalerServer(b)
+	ctx := context.Background()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := s.IsActive(ctx, refs[i%len(refs)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPoll(b *testing.B) {
+	s, refs := newBenchScalerServer(b)
+	scaler, _ := s.getScaler(getScalerUniqueName(refs[0]))
+	ctx := context.Background()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := scaler.poll(ctx, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+	"google.golang.org/grpc/peer"
+)
+
+// countingBackend counts its queries and blocks each one until release is
+// closed, when it is set
+type countingBackend struct {
+	queries int32
+	release chan struct{}
+}
+
+func (c *countingBackend) MetricName() string {
+	return "Counting"
+}
+
+func (c *countingBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	atomic.AddInt32(&c.queries, 1)
+	if c.release != nil {
+		<-c.release
+	}
+	return 1, nil
+}
+
+func (c *countingBackend) Close() error {
+	return nil
+}
+
+func newTestScaler(backend *countingBackend) *Scaler {
+	return &Scaler{
+		name:         "test/scaler",
+		backend:      backend,
+		observations: newObservationStream(config.ObservationStreamConfig{}),
+	}
+}
+
+func clientContext(ip string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000 + len(ip)},
+	})
+}
+
+func TestPollMinIntervalPerClient(t *testing.T) {
+	backend := &countingBackend{}
+	scaler := newTestScaler(backend)
+
+	polls := []struct {
+		client  string
+		queries int32
+	}{
+		{"10.0.0.1", 1},
+		// Within the interval of the same client the last value is reused
+		{"10.0.0.1", 1},
+		// Another client gets its own interval
+		{"10.0.0.2", 2},
+		{"10.0.0.2", 2},
+		{"10.0.0.1", 2},
+	}
+
+	for i, p := range polls {
+		value, active, err := scaler.poll(clientContext(p.client), time.Hour)
+		if err != nil {
+			t.Fatalf("poll %d: unexpected error %s", i, err.Error())
+		}
+		if value != 1 || !active {
+			t.Errorf("poll %d: got value %d active %v", i, value, active)
+		}
+		if queries := atomic.LoadInt32(&backend.queries); queries != p.queries {
+			t.Errorf("poll %d from %s: backend queried %d times, want %d", i, p.client, queries, p.queries)
+		}
+	}
+
+	// Without a minimum interval every poll queries the backend
+	if _, _, err := scaler.poll(clientContext("10.0.0.1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if queries := atomic.LoadInt32(&backend.queries); queries != 3 {
+		t.Errorf("backend queried %d times, want 3", queries)
+	}
+}
+
+func TestPollReleasesLockDuringQuery(t *testing.T) {
+	backend := &countingBackend{release: make(chan struct{})}
+	scaler := newTestScaler(backend)
+
+	done := make(chan error)
+	go func() {
+		_, _, err := scaler.poll(context.Background(), 0)
+		done <- err
+	}()
+
+	for atomic.LoadInt32(&backend.queries) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The self-metrics read the scaler while its backend is queried
+	read := make(chan struct{})
+	go func() {
+		scaler.getDegraded()
+		scaler.sinceLastQuery()
+		close(read)
+	}()
+
+	select {
+	case <-read:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scaler state was locked during the backend query")
+	}
+
+	close(backend.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPollClient(t *testing.T) {
+	if client := pollClient(context.Background()); client != "" {
+		t.Errorf("got client %q without a peer", client)
+	}
+
+	if client := pollClient(clientContext("10.0.0.1")); client != "10.0.0.1" {
+		t.Errorf("got client %q, want 10.0.0.1", client)
+	}
+}
+
+func TestStaticBackendDisabled(t *testing.T) {
+	ref := &pb.ScaledObjectRef{Name: "scaler", Namespace: "test"}
+	metadata := map[string]string{"value": "3"}
+
+	if _, err := parseStaticMetadata(&config.Config{}, ref, metadata); err == nil {
+		t.Errorf("expected the static backend to be disabled by default")
+	}
+
+	backend, err := parseStaticMetadata(&config.Config{StaticBackend: true}, ref, metadata)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err.Error())
+	}
+	if value, _ := backend.GetMetricValue(context.Background()); value != 3 {
+		t.Errorf("got value %d, want 3", value)
+	}
+}
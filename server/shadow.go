@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	shadowMetadataPrefix       = "shadow"
+	defaultDivergenceTolerance = 0.1
+	shadowTimeout              = 10 * time.Second
+)
+
+// shadowComparison queries a shadow backend alongside the metric backend so
+// a migration to another metric source can be checked before it is made.
+// Only the metric backend's value is reported, the shadow's value and its
+// divergence are logged and exported.
+type shadowComparison struct {
+	backend   backends.Backend
+	tolerance float64
+
+	mu         sync.Mutex
+	comparing  bool
+	value      int64
+	divergence float64
+	diverged   bool
+	lastError  string
+}
+
+// shadowStatus is the admin API view of a shadow comparison
+type shadowStatus struct {
+	Value      int64   `json:"value"`
+	Divergence float64 `json:"divergence"`
+	Diverged   bool    `json:"diverged"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// parseShadowComparison creates the shadow backend from the metadata keys
+// starting with "shadow", like parseActivationBackend does for "activation".
+// divergenceTolerance is the relative difference to the metric value above
+// which the shadow is considered diverged. It returns nil when no shadow
+// backend is configured.
+func parseShadowComparison(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (*shadowComparison, error) {
+	tolerance := defaultDivergenceTolerance
+	if val, ok := metadata["divergenceTolerance"]; ok && val != "" {
+		var err error
+		tolerance, err = strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Divergence tolerance parsing error %s", err.Error())
+		}
+
+		if tolerance < 0 {
+			return nil, fmt.Errorf("divergence tolerance must not be negative")
+		}
+	}
+
+	backend, err := parsePrefixedBackend(cfg, ref, metadata, shadowMetadataPrefix)
+	if err != nil || backend == nil {
+		return nil, err
+	}
+
+	return &shadowComparison{
+		backend:   backend,
+		tolerance: tolerance,
+	}, nil
+}
+
+// compare queries the shadow backend in the background and compares its
+// value with the metric backend's. A comparison still running is not
+// started again.
+func (s *shadowComparison) compare(name string, primary int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.comparing {
+		return
+	}
+	s.comparing = true
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+		defer cancel()
+
+		value, err := s.backend.GetMetricValue(ctx)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.comparing = false
+
+		if err != nil {
+			s.lastError = err.Error()
+			log.Printf("Shadow backend of %s failed %s", name, err.Error())
+			return
+		}
+		s.lastError = ""
+
+		s.value = value
+		s.divergence = math.Abs(float64(value-primary)) / math.Max(math.Abs(float64(primary)), 1)
+
+		diverged := s.divergence > s.tolerance
+		if diverged != s.diverged {
+			if diverged {
+				log.Printf("Shadow backend of %s diverged, shadow %d, metric %d", name, value, primary)
+			} else {
+				log.Printf("Shadow backend of %s converged, shadow %d, metric %d", name, value, primary)
+			}
+		}
+		s.diverged = diverged
+	}()
+}
+
+func (s *shadowComparison) status() shadowStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return shadowStatus{
+		Value:      s.value,
+		Divergence: s.divergence,
+		Diverged:   s.diverged,
+		Error:      s.lastError,
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// inFlightTracker counts the RPCs being served
+type inFlightTracker struct {
+	inFlight int64
+	served   int64
+}
+
+func (t *inFlightTracker) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	atomic.AddInt64(&t.inFlight, 1)
+	defer atomic.AddInt64(&t.inFlight, -1)
+	defer atomic.AddInt64(&t.served, 1)
+
+	return handler(ctx, req)
+}
+
+// shutdown drains the servers, closes every scaler backend and logs what was
+// released and how long it took
+func shutdown(servers []*grpc.Server, adminServer *http.Server, scalerServer *RedisExternalScalerServer, tracker *inFlightTracker, timeout time.Duration) {
+	start := time.Now()
+	draining := atomic.LoadInt64(&tracker.inFlight)
+	servedBefore := atomic.LoadInt64(&tracker.served)
+
+	drained := make(chan struct{})
+	go func() {
+		for _, server := range servers {
+			server.GracefulStop()
+		}
+		close(drained)
+	}()
+
+	forced := false
+	select {
+	case <-drained:
+	case <-time.After(timeout):
+		log.Printf("RPCs not drained within %s, stopping servers", timeout)
+		forced = true
+		for _, server := range servers {
+			server.Stop()
+		}
+		<-drained
+	}
+
+	if adminServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := adminServer.Shutdown(ctx); err != nil {
+			log.Printf("Admin server shutdown error %s", err.Error())
+		}
+		cancel()
+	}
+
+	registered, closeErrors := scalerServer.closeAll()
+
+	log.Printf("Shutdown summary: servers=%d scalers=%d backendsClosed=%d backendCloseErrors=%d inFlightAtSignal=%d completedWhileDraining=%d forced=%t took=%s",
+		len(servers), registered, registered-closeErrors, closeErrors, draining,
+		atomic.LoadInt64(&tracker.served)-servedBefore, forced, time.Since(start))
+}
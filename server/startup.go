@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/go-redis/redis"
+	redisbackend "github.com/patnaikshekhar/keda_external_scaler/backends/redis"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+)
+
+const dependencyProbeTimeout = 5 * time.Second
+
+// dependencyStatus is the result of probing one dependency at startup. A
+// failed required dependency prevents the scaler from serving, a failed
+// optional one only disables the features relying on it.
+type dependencyStatus struct {
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+	OK       bool   `json:"ok"`
+	Message  string `json:"message"`
+	Hint     string `json:"hint,omitempty"`
+}
+
+// startupReport collects the dependency statuses, reported in the logs and
+// on /readyz
+type startupReport struct {
+	mu           sync.RWMutex
+	dependencies []dependencyStatus
+}
+
+func (s *startupReport) add(status dependencyStatus) {
+	s.mu.Lock()
+	s.dependencies = append(s.dependencies, status)
+	s.mu.Unlock()
+
+	switch {
+	case status.OK:
+		log.Printf("Dependency %s ok %s", status.Name, status.Message)
+	case status.Required:
+		log.Errorf("Dependency %s failed %s. %s", status.Name, status.Message, status.Hint)
+	default:
+		log.Warnf("Dependency %s unavailable %s. %s", status.Name, status.Message, status.Hint)
+	}
+}
+
+// list returns the statuses and whether every required dependency is ok
+func (s *startupReport) list() ([]dependencyStatus, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ready := true
+	for _, status := range s.dependencies {
+		if status.Required && !status.OK {
+			ready = false
+		}
+	}
+
+	return append([]dependencyStatus(nil), s.dependencies...), ready
+}
+
+// checkLocal probes the dependencies on local files, which the servers
+// cannot start without. It returns the first failed required dependency.
+func (s *startupReport) checkLocal(cfg *config.Config) error {
+	var failed error
+
+	for _, listener := range cfg.Listeners {
+		status := dependencyStatus{
+			Name:     "tls/" + listener.Name,
+			Required: true,
+			OK:       true,
+		}
+
+		if listener.TLS == config.TLSModeNone {
+			status.Message = "plaintext listener"
+		} else if _, err := listener.Credentials(); err != nil {
+			status.OK = false
+			status.Message = err.Error()
+			status.Hint = fmt.Sprintf("Mount server.crt and server.key in %s (set with %s or certPath)", listener.CertPath, config.CertPathEnv)
+			if listener.TLS == config.TLSModeMutual {
+				status.Hint += fmt.Sprintf(" and the client CA at %s", listener.ClientCAFile)
+			}
+		} else {
+			status.Message = fmt.Sprintf("%s certificate loaded from %s", listener.TLS, listener.CertPath)
+		}
+
+		s.add(status)
+		if !status.OK && failed == nil {
+			failed = fmt.Errorf("listener %s TLS material %s", listener.Name, status.Message)
+		}
+	}
+
+	for _, dir := range []struct {
+		name     string
+		path     string
+		env      string
+		required bool
+	}{
+		{"plugins", cfg.PluginDir, config.PluginDirEnv, true},
+		{"exec", cfg.ExecDir, config.ExecDirEnv, false},
+		{"secret", cfg.SecretDir, config.SecretDirEnv, false},
+	} {
+		if dir.path == "" {
+			continue
+		}
+
+		status := dependencyStatus{Name: dir.name + "-dir", Required: dir.required, OK: true}
+		if info, err := os.Stat(dir.path); err != nil {
+			status.OK = false
+			status.Message = err.Error()
+			status.Hint = fmt.Sprintf("Mount the directory or unset %s", dir.env)
+		} else if !info.IsDir() {
+			status.OK = false
+			status.Message = dir.path + " is not a directory"
+			status.Hint = fmt.Sprintf("Point %s at a directory", dir.env)
+		} else {
+			status.Message = dir.path
+		}
+
+		s.add(status)
+		if !status.OK && dir.required && failed == nil {
+			failed = fmt.Errorf("%s directory %s", dir.name, status.Message)
+		}
+	}
+
+	return failed
+}
+
+// checkRemote probes the optional network dependencies. It runs in the
+// background so an unreachable service does not delay startup.
+func (s *startupReport) checkRemote(cfg *config.Config) {
+	var wg sync.WaitGroup
+	for _, check := range []func() dependencyStatus{checkDefaultRedis, checkKubernetesAPI} {
+		wg.Add(1)
+		go func(check func() dependencyStatus) {
+			defer wg.Done()
+			s.add(check())
+		}(check)
+	}
+	wg.Wait()
+}
+
+func checkDefaultRedis() dependencyStatus {
+	status := dependencyStatus{Name: "redis/default"}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     redisbackend.DefaultAddress,
+		Password: redisbackend.DefaultPassword,
+	})
+	defer client.Close()
+
+	if err := client.Ping().Err(); err != nil {
+		status.Message = err.Error()
+		status.Hint = fmt.Sprintf("Triggers without an address use %s, set address in their metadata", redisbackend.DefaultAddress)
+		return status
+	}
+
+	status.OK = true
+	status.Message = redisbackend.DefaultAddress
+	return status
+}
+
+func checkKubernetesAPI() dependencyStatus {
+	status := dependencyStatus{Name: "kubernetes"}
+
+	client, err := getKubeClient()
+	if err != nil {
+		status.Message = err.Error()
+		status.Hint = "Events are only logged outside a cluster"
+		return status
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dependencyProbeTimeout)
+	defer cancel()
+
+	var version struct {
+		GitVersion string `json:"gitVersion"`
+	}
+	if err := client.Do(ctx, http.MethodGet, "/version", nil, &version); err != nil {
+		status.Message = err.Error()
+		status.Hint = "Check the network policy and the service account of the pod"
+		return status
+	}
+
+	status.OK = true
+	status.Message = version.GitVersion
+	return status
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+// StaticScalerType and StaticMetricName identify the static backend, which
+// the bench command registers on servers with staticBackend enabled
+const (
+	StaticScalerType = "static"
+	StaticMetricName = "StaticValue"
+)
+
+// staticBackend always reports the value from its metadata. It is meant for
+// testing and benchmarking the server without a real backend.
+type staticBackend struct {
+	value int64
+}
+
+func parseStaticMetadata(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	if !cfg.StaticBackend {
+		return nil, fmt.Errorf("static backend is disabled, staticBackend is not set in the server config")
+	}
+
+	backend := staticBackend{}
+
+	if val, ok := metadata["value"]; ok {
+		value, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Value parsing error %s", err.Error())
+		}
+
+		backend.value = value
+	}
+
+	return &backend, nil
+}
+
+// MetricName returns the name of the static metric
+func (s *staticBackend) MetricName() string {
+	return StaticMetricName
+}
+
+// GetMetricValue returns the configured value
+func (s *staticBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	return s.value, nil
+}
+
+// Close is a no-op
+func (s *staticBackend) Close() error {
+	return nil
+}
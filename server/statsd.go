@@ -0,0 +1,168 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+)
+
+const (
+	maxStatsDPacketBytes = 65535
+	// maxStatsDGauges bounds the gauges kept, as any client reaching the
+	// port can push arbitrary names. The least recently updated gauge is
+	// dropped for a new one.
+	maxStatsDGauges = 10000
+)
+
+// statsdGauge is the last value pushed for a gauge
+type statsdGauge struct {
+	key   string
+	value float64
+}
+
+// statsdGaugeStore holds the gauges received by the StatsD listener, keyed
+// by name and sorted tags. recent orders them from the most to the least
+// recently updated.
+type statsdGaugeStore struct {
+	mu     sync.RWMutex
+	gauges map[string]*list.Element
+	recent *list.List
+}
+
+func newStatsdGaugeStore() *statsdGaugeStore {
+	return &statsdGaugeStore{
+		gauges: make(map[string]*list.Element),
+		recent: list.New(),
+	}
+}
+
+// statsdGauges is shared by the listener and the statsd backends, which
+// read it through the statsd.Gauges interface
+var statsdGauges = newStatsdGaugeStore()
+
+// statsdGaugeKey identifies a gauge by its name and DogStatsD tags, which
+// are matched regardless of their order
+func statsdGaugeKey(name string, tags []string) string {
+	if len(tags) == 0 {
+		return name
+	}
+
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	return name + "|#" + strings.Join(sorted, ",")
+}
+
+// set stores a gauge value, a value with an explicit sign changes the
+// current value as in StatsD
+func (s *statsdGaugeStore) set(key string, value float64, delta bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.gauges[key]
+	if !ok {
+		if s.recent.Len() >= maxStatsDGauges {
+			oldest := s.recent.Back()
+			s.recent.Remove(oldest)
+			delete(s.gauges, oldest.Value.(*statsdGauge).key)
+		}
+
+		element = s.recent.PushFront(&statsdGauge{key: key})
+		s.gauges[key] = element
+	} else {
+		s.recent.MoveToFront(element)
+	}
+
+	gauge := element.Value.(*statsdGauge)
+	if delta {
+		gauge.value += value
+	} else {
+		gauge.value = value
+	}
+}
+
+// Gauge returns the last value of the gauge named name with tags
+func (s *statsdGaugeStore) Gauge(name string, tags []string) (float64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	element, ok := s.gauges[statsdGaugeKey(name, tags)]
+	if !ok {
+		return 0, false
+	}
+	return element.Value.(*statsdGauge).value, true
+}
+
+// ingest parses the StatsD lines of a packet and stores the gauges among
+// them. Other metric types and malformed lines are skipped.
+func (s *statsdGaugeStore) ingest(packet []byte) {
+	for _, line := range strings.Split(string(packet), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		// name:value|type followed by optional |@rate and |#tags sections
+		colon := strings.LastIndex(strings.SplitN(line, "|", 2)[0], ":")
+		if colon <= 0 {
+			continue
+		}
+
+		sections := strings.Split(line[colon+1:], "|")
+		if len(sections) < 2 || sections[1] != "g" {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(sections[0], 64)
+		if err != nil {
+			continue
+		}
+
+		var tags []string
+		for _, section := range sections[2:] {
+			if strings.HasPrefix(section, "#") {
+				tags = backends.SplitItems(section[1:])
+			}
+		}
+
+		delta := strings.HasPrefix(sections[0], "+") || strings.HasPrefix(sections[0], "-")
+		s.set(statsdGaugeKey(line[:colon], tags), value, delta)
+	}
+}
+
+// runStatsDListener receives StatsD and DogStatsD packets on the UDP port
+// until the context is done. StatsD has no authentication, the port should
+// only be reachable from the applications pushing gauges.
+func runStatsDListener(ctx context.Context, port int) {
+	conn, err := net.ListenPacket("udp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		log.Printf("StatsD listener not started %s", err.Error())
+		return
+	}
+	log.Printf("Starting StatsD listener on %s", conn.LocalAddr())
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, maxStatsDPacketBytes)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("StatsD listener stopped %s", err.Error())
+			}
+			return
+		}
+
+		statsdGauges.ingest(buf[:n])
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStatsDGaugeEviction(t *testing.T) {
+	store := newStatsdGaugeStore()
+
+	store.ingest([]byte("queue.depth:3|g|#env:prod"))
+	for i := 0; i < maxStatsDGauges; i++ {
+		store.ingest([]byte(fmt.Sprintf("junk.%d:1|g", i)))
+	}
+
+	// The least recently updated gauge made room for the last junk one
+	if _, ok := store.Gauge("queue.depth", []string{"env:prod"}); ok {
+		t.Errorf("expected the oldest gauge to be evicted")
+	}
+	if len(store.gauges) != maxStatsDGauges || store.recent.Len() != maxStatsDGauges {
+		t.Fatalf("got %d gauges and %d ordered, want %d", len(store.gauges), store.recent.Len(), maxStatsDGauges)
+	}
+
+	store.ingest([]byte("queue.depth:7|g|#env:prod"))
+	if value, ok := store.Gauge("queue.depth", []string{"env:prod"}); !ok || value != 7 {
+		t.Errorf("got gauge %v %t, want 7 after the store filled up", value, ok)
+	}
+
+	// Updating a gauge keeps it from being evicted
+	store.ingest([]byte("junk.1:+1|g"))
+	store.ingest([]byte("other:1|g"))
+	if value, ok := store.Gauge("junk.1", nil); !ok || value != 2 {
+		t.Errorf("got gauge %v %t, want the recently updated junk.1 to be kept with 2", value, ok)
+	}
+	if _, ok := store.Gauge("junk.2", nil); ok {
+		t.Errorf("expected the least recently updated junk.2 to be evicted")
+	}
+}
+
+func TestStatsDIngest(t *testing.T) {
+	store := newStatsdGaugeStore()
+	store.ingest([]byte("queue.depth:5|g|@0.5|#b:2,a:1\nqueue.depth:-2|g|#a:1,b:2\nrequests:1|c\nbroken\n"))
+
+	if value, ok := store.Gauge("queue.depth", []string{"a:1", "b:2"}); !ok || value != 3 {
+		t.Errorf("got gauge %v %t, want 3", value, ok)
+	}
+	if _, ok := store.Gauge("requests", nil); ok {
+		t.Errorf("expected counters to be skipped")
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+)
+
+// traceparentPattern matches a W3C traceparent of a known or future version,
+// see https://www.w3.org/TR/trace-context/#traceparent-header
+var traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}(-.*)?$`)
+
+type traceContextKey struct{}
+
+// traceContext is the W3C trace context of the RPC being served
+type traceContext struct {
+	traceparent string
+	tracestate  string
+}
+
+// traceUnaryInterceptor reads the W3C trace context sent by KEDA so backend
+// calls made while serving the RPC carry it downstream. The scaler records
+// no spans of its own, so it passes the context on unchanged.
+func traceUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	traceparents := md.Get(traceparentHeader)
+	if len(traceparents) != 1 || !traceparentPattern.MatchString(traceparents[0]) {
+		return handler(ctx, req)
+	}
+
+	trace := traceContext{traceparent: traceparents[0]}
+	if tracestates := md.Get(tracestateHeader); len(tracestates) > 0 {
+		trace.tracestate = tracestates[0]
+	}
+
+	return handler(context.WithValue(ctx, traceContextKey{}, trace), req)
+}
+
+func traceContextFrom(ctx context.Context) (traceContext, bool) {
+	trace, ok := ctx.Value(traceContextKey{}).(traceContext)
+	return trace, ok
+}
+
+// injectTraceHTTP adds the trace context of ctx to an outgoing HTTP request
+func injectTraceHTTP(ctx context.Context, req *http.Request) {
+	trace, ok := traceContextFrom(ctx)
+	if !ok {
+		return
+	}
+
+	req.Header.Set(traceparentHeader, trace.traceparent)
+	if trace.tracestate != "" {
+		req.Header.Set(tracestateHeader, trace.tracestate)
+	}
+}
+
+// injectTraceGRPC returns a context sending the trace context of ctx with
+// outgoing gRPC calls
+func injectTraceGRPC(ctx context.Context) context.Context {
+	trace, ok := traceContextFrom(ctx)
+	if !ok {
+		return ctx
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, traceparentHeader, trace.traceparent)
+	if trace.tracestate != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, tracestateHeader, trace.tracestate)
+	}
+	return ctx
+}
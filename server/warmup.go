@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+)
+
+const defaultWarmUpTimeout = 10 * time.Second
+
+// Readiness states of a scaler. Scalers without warm-up are ready right away.
+const (
+	readinessReady   = "ready"
+	readinessWarming = "warming"
+	readinessFailed  = "failed"
+)
+
+// warmUpPolicy asks for a scaler's backend to be connected right after New()
+// instead of on the first poll from KEDA
+type warmUpPolicy struct {
+	timeout time.Duration
+}
+
+// parseWarmUpPolicy reads warmUp and warmUpTimeout (in seconds). It returns
+// nil when warm-up is not enabled.
+func parseWarmUpPolicy(metadata map[string]string) (*warmUpPolicy, error) {
+	val, ok := metadata["warmUp"]
+	if !ok || val == "" {
+		return nil, nil
+	}
+
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		return nil, fmt.Errorf("Warm up parsing error %s", err.Error())
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+
+	policy := warmUpPolicy{timeout: defaultWarmUpTimeout}
+	if val, ok := metadata["warmUpTimeout"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Warm up timeout parsing error %s", err.Error())
+		}
+
+		if seconds <= 0 {
+			return nil, fmt.Errorf("warm up timeout must be positive")
+		}
+
+		policy.timeout = time.Duration(seconds) * time.Second
+	}
+
+	return &policy, nil
+}
+
+// warmUp connects the backend in the background and records the readiness of
+// the scaler. Backends implementing WarmUpBackend are asked to connect, any
+// other backend is polled once, which also fills the cached result.
+func (r *Scaler) warmUp() {
+	ctx, cancel := context.WithTimeout(context.Background(), r.warmUpPolicy.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	var err error
+	if backend, ok := r.backend.(backends.WarmUpBackend); ok {
+		err = backend.WarmUp(ctx)
+	} else {
+		_, _, err = r.poll(ctx, 0)
+	}
+
+	if err != nil {
+		r.logger().Printf("Warm up failed for %s %s", r.name, err.Error())
+		r.setReadiness(readinessFailed, err)
+		return
+	}
+
+	r.logger().Printf("Warm up completed for %s in %s", r.name, time.Since(start))
+	r.setReadiness(readinessReady, nil)
+}
+
+func (r *Scaler) setReadiness(readiness string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.readiness = readiness
+	r.readinessError = ""
+	if err != nil {
+		r.readinessError = err.Error()
+	}
+}
+
+// getReadiness returns the readiness state and the error of a failed warm-up
+func (r *Scaler) getReadiness() (string, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.readiness == "" {
+		return readinessReady, ""
+	}
+	return r.readiness, r.readinessError
+}
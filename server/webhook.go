@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patnaikshekhar/keda_external_scaler/backends"
+	"github.com/patnaikshekhar/keda_external_scaler/config"
+	pb "github.com/patnaikshekhar/keda_external_scaler/externalscaler"
+)
+
+const (
+	webhookScalerType     = "webhook"
+	webhookMetricName     = "WebhookValue"
+	maxWebhookRedirects   = 10
+	defaultWebhookTimeout = 5 * time.Second
+	maxWebhookBodyBytes   = 64 * 1024
+
+	webhookSignatureHeader = "X-Scaler-Signature"
+	webhookTimestampHeader = "X-Scaler-Timestamp"
+)
+
+// webhookBackend POSTs the scaled object and its metadata to a user supplied
+// URL and reads the metric value and activity from the JSON response. Only
+// the metadata keys listed in forwardMetadata, such as "queue,tenant", are
+// sent, so credentials of the trigger, including those resolved from
+// secretRefs, never leave the scaler.
+//
+// When a secret is configured every request carries a timestamp header and
+// a signature header holding "sha256=" followed by the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret.
+type webhookBackend struct {
+	url     string
+	secret  []byte
+	payload []byte
+	client  *http.Client
+}
+
+type webhookRequest struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Metadata  map[string]string `json:"metadata"`
+}
+
+type webhookResponse struct {
+	Value  *int64 `json:"value"`
+	Active *bool  `json:"active"`
+}
+
+func parseWebhookMetadata(cfg *config.Config, ref *pb.ScaledObjectRef, metadata map[string]string) (backends.Backend, error) {
+	backend := webhookBackend{}
+
+	val, ok := metadata["url"]
+	if !ok || val == "" {
+		return nil, fmt.Errorf("no webhook url given")
+	}
+
+	parsed, err := url.Parse(val)
+	if err != nil {
+		return nil, fmt.Errorf("Webhook url parsing error %s", err.Error())
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("webhook url must use http or https")
+	}
+
+	if err := cfg.AllowEgressURL(parsed); err != nil {
+		return nil, err
+	}
+	backend.url = val
+
+	if val, ok := metadata["secret"]; ok && val != "" {
+		backend.secret = []byte(val)
+	}
+
+	timeout := defaultWebhookTimeout
+	if val, ok := metadata["timeout"]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Timeout parsing error %s", err.Error())
+		}
+
+		if seconds <= 0 {
+			return nil, fmt.Errorf("timeout must be positive")
+		}
+
+		timeout = time.Duration(seconds) * time.Second
+	}
+	backend.client = &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxWebhookRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxWebhookRedirects)
+			}
+			return cfg.AllowEgressURL(req.URL)
+		},
+	}
+
+	forwarded := make(map[string]string)
+	if val, ok := metadata["forwardMetadata"]; ok && val != "" {
+		for _, key := range strings.Split(val, ",") {
+			key = strings.TrimSpace(key)
+			if key == "secret" {
+				return nil, fmt.Errorf("the webhook secret cannot be forwarded")
+			}
+
+			if value, ok := metadata[key]; ok && key != "" {
+				forwarded[key] = value
+			}
+		}
+	}
+
+	backend.payload, err = json.Marshal(webhookRequest{
+		Name:      ref.GetName(),
+		Namespace: ref.GetNamespace(),
+		Metadata:  forwarded,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &backend, nil
+}
+
+// Endpoint returns the host of the webhook url, leaving out any credentials
+// or tokens in the path and query
+func (w *webhookBackend) Endpoint() string {
+	parsed, err := url.Parse(w.url)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// MetricName returns the name of the webhook metric
+func (w *webhookBackend) MetricName() string {
+	return webhookMetricName
+}
+
+// GetMetricValue returns the value reported by the webhook
+func (w *webhookBackend) GetMetricValue(ctx context.Context) (int64, error) {
+	value, _, err := w.GetMetricAndActivity(ctx)
+	return value, err
+}
+
+// GetMetricAndActivity calls the webhook. When the response has no active
+// field the scaler is active if the value is above zero.
+func (w *webhookBackend) GetMetricAndActivity(ctx context.Context) (int64, bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(w.payload))
+	if err != nil {
+		return -1, false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	injectTraceHTTP(ctx, req)
+
+	if w.secret != nil {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(webhookTimestampHeader, timestamp)
+		req.Header.Set(webhookSignatureHeader, "sha256="+signWebhookPayload(w.secret, timestamp, w.payload))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return -1, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return -1, false, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxWebhookBodyBytes))
+	if err != nil {
+		return -1, false, err
+	}
+
+	var result webhookResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return -1, false, fmt.Errorf("Webhook response parsing error %s", err.Error())
+	}
+
+	if result.Value == nil {
+		return -1, false, fmt.Errorf("webhook response has no value")
+	}
+
+	active := *result.Value > 0
+	if result.Active != nil {
+		active = *result.Active
+	}
+
+	return *result.Value, active, nil
+}
+
+// Close is a no-op as the http client is shared through the default transport
+func (w *webhookBackend) Close() error {
+	return nil
+}
+
+func signWebhookPayload(secret []byte, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}